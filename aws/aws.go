@@ -42,7 +42,7 @@ var USEast = Region{
 	"https://sns.us-east-1.amazonaws.com",
 	"https://sqs.us-east-1.amazonaws.com",
 	"https://iam.amazonaws.com",
-	"https://elasticloadbalancing.amazonaws.com",
+	"https://elasticloadbalancing.us-east-1.amazonaws.com",
 }
 
 var USWest = Region{
@@ -56,7 +56,7 @@ var USWest = Region{
 	"https://sns.us-west-1.amazonaws.com",
 	"https://sqs.us-west-1.amazonaws.com",
 	"https://iam.amazonaws.com",
-	"https://elasticloadbalancing.amazonaws.com",
+	"https://elasticloadbalancing.us-west-1.amazonaws.com",
 }
 
 var USWest2 = Region{
@@ -70,7 +70,7 @@ var USWest2 = Region{
 	"https://sns.us-west-2.amazonaws.com",
 	"https://sqs.us-west-2.amazonaws.com",
 	"https://iam.amazonaws.com",
-	"https://elasticloadbalancing.amazonaws.com",
+	"https://elasticloadbalancing.us-west-2.amazonaws.com",
 }
 
 var EUWest = Region{
@@ -84,7 +84,7 @@ var EUWest = Region{
 	"https://sns.eu-west-1.amazonaws.com",
 	"https://sqs.eu-west-1.amazonaws.com",
 	"https://iam.amazonaws.com",
-	"https://elasticloadbalancing.amazonaws.com",
+	"https://elasticloadbalancing.eu-west-1.amazonaws.com",
 }
 
 var APSoutheast = Region{
@@ -98,7 +98,7 @@ var APSoutheast = Region{
 	"https://sns.ap-southeast-1.amazonaws.com",
 	"https://sqs.ap-southeast-1.amazonaws.com",
 	"https://iam.amazonaws.com",
-	"https://elasticloadbalancing.amazonaws.com",
+	"https://elasticloadbalancing.ap-southeast-1.amazonaws.com",
 }
 
 var APSoutheast2 = Region{
@@ -112,7 +112,7 @@ var APSoutheast2 = Region{
 	"https://sns.ap-southeast-2.amazonaws.com",
 	"https://sqs.ap-southeast-2.amazonaws.com",
 	"https://iam.amazonaws.com",
-	"https://elasticloadbalancing.amazonaws.com",
+	"https://elasticloadbalancing.ap-southeast-2.amazonaws.com",
 }
 
 var APNortheast = Region{
@@ -126,7 +126,7 @@ var APNortheast = Region{
 	"https://sns.ap-northeast-1.amazonaws.com",
 	"https://sqs.ap-northeast-1.amazonaws.com",
 	"https://iam.amazonaws.com",
-	"https://elasticloadbalancing.amazonaws.com",
+	"https://elasticloadbalancing.ap-northeast-1.amazonaws.com",
 }
 
 var SAEast = Region{
@@ -140,7 +140,7 @@ var SAEast = Region{
 	"https://sns.sa-east-1.amazonaws.com",
 	"https://sqs.sa-east-1.amazonaws.com",
 	"https://iam.amazonaws.com",
-	"https://elasticloadbalancing.amazonaws.com",
+	"https://elasticloadbalancing.sa-east-1.amazonaws.com",
 }
 
 var Regions = map[string]Region{
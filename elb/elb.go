@@ -1,23 +1,105 @@
 // This package provides types and functions to interact Elastic Load Balancing service
+//
+// Responses are decoded leniently: elements of a response that the package
+// does not know about are ignored, and known elements missing from a response
+// are left at their zero values. Fields that AWS adds to the API in the
+// future don't break the decoding of the fields already supported.
 package elb
 
 import (
+	"bytes"
+	"context"
 	"encoding/xml"
 	"fmt"
 	"github.com/flaviamissi/go-elb/aws"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
 type ELB struct {
 	aws.Auth
 	aws.Region
+	unsigned bool
+	raw      rawResponse
+}
+
+// rawResponse holds the body of the last response received by an ELB client,
+// when capturing is enabled.
+type rawResponse struct {
+	sync.Mutex
+	enabled bool
+	body    []byte
 }
 
+// New returns an ELB client for the given region.
+//
+// When the region has no ELBEndpoint, the endpoint is computed from the name
+// of the region with EndpointForRegion.
 func New(auth aws.Auth, region aws.Region) *ELB {
-	return &ELB{auth, region}
+	if region.ELBEndpoint == "" {
+		region.ELBEndpoint = EndpointForRegion(region.Name)
+	}
+	return &ELB{Auth: auth, Region: region}
+}
+
+// EndpointForRegion returns the ELB API endpoint of the given region, taking
+// the partition of the region into account: the China regions (cn-*) live
+// under amazonaws.com.cn, and the isolated US regions (us-iso-* and
+// us-isob-*) under their own domains. GovCloud regions (us-gov-*) use the
+// standard domain. It returns an empty string for an empty region name.
+func EndpointForRegion(region string) string {
+	if region == "" {
+		return ""
+	}
+	domain := "amazonaws.com"
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		domain = "amazonaws.com.cn"
+	case strings.HasPrefix(region, "us-isob-"):
+		domain = "sc2s.sgov.gov"
+	case strings.HasPrefix(region, "us-iso-"):
+		domain = "c2s.ic.gov"
+	}
+	return fmt.Sprintf("https://elasticloadbalancing.%s.%s", region, domain)
+}
+
+// NewForTesting returns an ELB client that sends its requests to the given
+// URL, usually the URL of an elbtest.Server, without signing them.
+//
+// It is meant to be used only in tests, never against AWS.
+func NewForTesting(url string) *ELB {
+	return &ELB{Region: aws.Region{ELBEndpoint: url}, unsigned: true}
+}
+
+// CaptureRawResponses enables or disables capturing the raw XML body of the
+// responses, to be inspected with LastRawResponse. Capturing is disabled by
+// default, so the client doesn't retain response bodies. Disabling it
+// discards the captured body.
+func (elb *ELB) CaptureRawResponses(enabled bool) {
+	elb.raw.Lock()
+	defer elb.raw.Unlock()
+	elb.raw.enabled = enabled
+	elb.raw.body = nil
+}
+
+// LastRawResponse returns the raw XML body of the last response received, be
+// it a success or an error, or nil if capturing is disabled or no response
+// was received since it was enabled. When the client is used concurrently,
+// the last response is the one that was received last.
+func (elb *ELB) LastRawResponse() []byte {
+	elb.raw.Lock()
+	defer elb.raw.Unlock()
+	if elb.raw.body == nil {
+		return nil
+	}
+	return append([]byte(nil), elb.raw.body...)
 }
 
 // The CreateLoadBalancer type encapsulates options for the respective request in AWS.
@@ -57,8 +139,19 @@ type SimpleResp struct {
 
 // Creates a Load Balancer in Amazon.
 //
+// The name and the listeners of the Load Balancer are validated before the
+// request is sent, see ValidateLoadBalancerName and ValidateListener.
+//
 // See http://goo.gl/4QFKi for more details.
 func (elb *ELB) CreateLoadBalancer(options *CreateLoadBalancer) (resp *CreateLoadBalancerResp, err error) {
+	if err := ValidateLoadBalancerName(options.Name); err != nil {
+		return nil, err
+	}
+	for i := range options.Listeners {
+		if err := ValidateListener(&options.Listeners[i]); err != nil {
+			return nil, err
+		}
+	}
 	params := makeCreateParams(options)
 	resp = new(CreateLoadBalancerResp)
 	if err := elb.query(params, resp); err != nil {
@@ -67,6 +160,59 @@ func (elb *ELB) CreateLoadBalancer(options *CreateLoadBalancer) (resp *CreateLoa
 	return
 }
 
+var lbNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?$`)
+
+// ValidateLoadBalancerName checks the name of a Load Balancer against the
+// rules enforced by AWS: it must have at most 32 characters, contain only
+// alphanumeric characters and hyphens, and cannot begin or end with a hyphen.
+//
+// The returned error is an *Error with the same code and message returned by
+// AWS.
+func ValidateLoadBalancerName(name string) error {
+	if len(name) > 32 {
+		return &Error{
+			Code:    "ValidationError",
+			Message: fmt.Sprintf("1 validation error detected: Value '%s' at 'loadBalancerName' failed to satisfy constraint: Member must have length less than or equal to 32", name),
+		}
+	}
+	if !lbNameRegexp.MatchString(name) {
+		return &Error{
+			Code:    "ValidationError",
+			Message: "LoadBalancerName must contain only alphanumeric characters or hyphens, and cannot begin or end with hyphen",
+		}
+	}
+	return nil
+}
+
+var certificateIdRegexps = []*regexp.Regexp{
+	regexp.MustCompile(`^arn:aws(-cn|-us-gov)?:iam::\d{12}:server-certificate/.+$`),
+	regexp.MustCompile(`^arn:aws(-cn|-us-gov)?:acm:[a-z]{2}(-gov)?-[a-z]+-\d:\d{12}:certificate/[0-9a-f-]+$`),
+}
+
+// ValidateListener checks the SSL certificate of HTTPS and SSL listeners.
+// Their SSLCertificateId must be the ARN of an IAM server certificate, like
+// arn:aws:iam::123456789012:server-certificate/mycert, or the ARN of an ACM
+// certificate, like
+// arn:aws:acm:us-east-1:123456789012:certificate/12345678-1234-1234-1234-123456789012.
+//
+// The returned error is an *Error with the CertificateNotFound code, the same
+// returned by AWS for malformed certificate ids.
+func ValidateListener(l *Listener) error {
+	protocol := strings.ToUpper(l.Protocol)
+	if protocol != "HTTPS" && protocol != "SSL" {
+		return nil
+	}
+	for _, r := range certificateIdRegexps {
+		if r.MatchString(l.SSLCertificateId) {
+			return nil
+		}
+	}
+	return &Error{
+		Code:    "CertificateNotFound",
+		Message: fmt.Sprintf("SSLCertificateId '%s' of the %s listener on port %d is not an IAM server certificate ARN or an ACM certificate ARN", l.SSLCertificateId, protocol, l.LoadBalancerPort),
+	}
+}
+
 // Deletes a Load Balancer.
 //
 // See http://goo.gl/sDmPp for more details.
@@ -128,49 +274,230 @@ func (elb *ELB) DeregisterInstancesFromLoadBalancer(instanceIds []string, lbName
 
 type DescribeLoadBalancerResp struct {
 	LoadBalancerDescriptions []LoadBalancerDescription `xml:"DescribeLoadBalancersResult>LoadBalancerDescriptions>member"`
+	// NextMarker is the marker of the next page of descriptions, see
+	// DescribeLoadBalancersPage. It is empty in the last page.
+	NextMarker string `xml:"DescribeLoadBalancersResult>NextMarker"`
+	byName     map[string]*LoadBalancerDescription
 }
 
+// LoadBalancersByName returns the descriptions in the response keyed by the
+// name of the Load Balancer. If a name appears more than once, the last
+// description with that name wins.
+//
+// The map is built on the first call and reused afterwards, so changes made
+// to LoadBalancerDescriptions after that are not reflected in it. It is not
+// safe to call LoadBalancersByName from multiple goroutines at the same time.
+func (resp *DescribeLoadBalancerResp) LoadBalancersByName() map[string]*LoadBalancerDescription {
+	if resp.byName == nil {
+		resp.byName = make(map[string]*LoadBalancerDescription, len(resp.LoadBalancerDescriptions))
+		for i := range resp.LoadBalancerDescriptions {
+			desc := &resp.LoadBalancerDescriptions[i]
+			resp.byName[desc.LoadBalancerName] = desc
+		}
+	}
+	return resp.byName
+}
+
+// LoadBalancerDescription describes a Load Balancer.
+//
+// Instances lists the instances registered with the Load Balancer, but not
+// their state, use DescribeInstanceHealth to get it.
 type LoadBalancerDescription struct {
-	AvailZones                []string                    `xml:"AvailabilityZones>member"`
-	BackendServerDescriptions []BackendServerDescriptions `xml:"BackendServerDescriptions>member"`
-	CanonicalHostedZoneName   string                      `xml:"CanonicalHostedZoneName"`
-	CanonicalHostedZoneNameId string                      `xml:"CanonicalHostedZoneNameID"`
-	CreatedTime               time.Time                   `xml:"CreatedTime"`
-	DNSName                   string                      `xml:"DNSName"`
-	HealthCheck               HealthCheck                 `xml:"HealthCheck"`
-	Instances                 []Instance                  `xml:"Instances>member"`
-	ListenerDescriptions      []ListenerDescription       `xml:"ListenerDescriptions>member"`
-	LoadBalancerName          string                      `xml:"LoadBalancerName"`
-	Policies                  Policies                    `xml:"Policies"`
-	Scheme                    string                      `xml:"Scheme"`
-	SecurityGroups            []string                    `xml:"SecurityGroups>member"` //vpc only
-	SourceSecurityGroup       SourceSecurityGroup         `xml:"SourceSecurityGroup"`
-	Subnets                   []string                    `xml:"Subnets>member"`
-	VPCId                     string                      `xml:"VPCId"`
+	AvailZones                []string                   `xml:"AvailabilityZones>member"`
+	BackendServerDescriptions []BackendServerDescription `xml:"BackendServerDescriptions>member"`
+	CanonicalHostedZoneName   string                     `xml:"CanonicalHostedZoneName"`
+	CanonicalHostedZoneNameId string                     `xml:"CanonicalHostedZoneNameID"`
+	CreatedTime               time.Time                  `xml:"CreatedTime"`
+	DNSName                   string                     `xml:"DNSName"`
+	HealthCheck               HealthCheck                `xml:"HealthCheck"`
+	Instances                 []Instance                 `xml:"Instances>member"`
+	ListenerDescriptions      []ListenerDescription      `xml:"ListenerDescriptions>member"`
+	LoadBalancerName          string                     `xml:"LoadBalancerName"`
+	Policies                  Policies                   `xml:"Policies"`
+	Scheme                    string                     `xml:"Scheme"`
+	SecurityGroups            []string                   `xml:"SecurityGroups>member"` //vpc only
+	SourceSecurityGroup       SourceSecurityGroup        `xml:"SourceSecurityGroup"`
+	Subnets                   []string                   `xml:"Subnets>member"`
+	VPCId                     string                     `xml:"VPCId"`
 }
 
 // Describe Load Balancers.
-// It can be used to describe all Load Balancers or specific ones.
+// It can be used to describe all Load Balancers or specific ones. When there
+// are no Load Balancers to describe, the response holds an empty list.
 //
 // See http://goo.gl/wofJA for more details.
 func (elb *ELB) DescribeLoadBalancers(names ...string) (*DescribeLoadBalancerResp, error) {
+	return elb.describeLoadBalancers(IncludeAll, names, "", 0)
+}
+
+// Include selects the sections of a LoadBalancerDescription that
+// DescribeLoadBalancersInclude decodes. Attributes aren't part of the
+// description; use DescribeLoadBalancerAttributes to get them.
+type Include struct {
+	Listeners      bool
+	Instances      bool
+	HealthCheck    bool
+	Policies       bool
+	BackendServers bool
+}
+
+// IncludeAll decodes every section of the descriptions, which is what
+// DescribeLoadBalancers does.
+var IncludeAll = Include{
+	Listeners:      true,
+	Instances:      true,
+	HealthCheck:    true,
+	Policies:       true,
+	BackendServers: true,
+}
+
+// excluded returns the names of the elements of a description that must not
+// be decoded.
+func (include Include) excluded() map[string]bool {
+	excluded := make(map[string]bool)
+	if !include.Listeners {
+		excluded["ListenerDescriptions"] = true
+	}
+	if !include.Instances {
+		excluded["Instances"] = true
+	}
+	if !include.HealthCheck {
+		excluded["HealthCheck"] = true
+	}
+	if !include.Policies {
+		excluded["Policies"] = true
+	}
+	if !include.BackendServers {
+		excluded["BackendServerDescriptions"] = true
+	}
+	return excluded
+}
+
+// DescribeLoadBalancersInclude works like DescribeLoadBalancers, but only
+// decodes the sections of the descriptions selected by include. AWS still
+// sends the whole description; the sections left out are skipped while
+// reading the response and are left at their zero values. The other fields
+// of the descriptions, like the name and the DNS name, are always decoded.
+func (elb *ELB) DescribeLoadBalancersInclude(include Include, names ...string) (*DescribeLoadBalancerResp, error) {
+	return elb.describeLoadBalancers(include, names, "", 0)
+}
+
+// DescribeLoadBalancersPage describes a page of at most pageSize Load
+// Balancers, starting at the given marker. The first page is described with
+// an empty marker, and the following ones with the NextMarker of the
+// previous page, until it is empty. A pageSize of zero uses the default of
+// AWS, which is also the maximum: 400 Load Balancers.
+//
+// See http://docs.aws.amazon.com/ElasticLoadBalancing/latest/APIReference/API_DescribeLoadBalancers.html
+// for more details.
+func (elb *ELB) DescribeLoadBalancersPage(marker string, pageSize int, names ...string) (*DescribeLoadBalancerResp, error) {
+	return elb.describeLoadBalancers(IncludeAll, names, marker, pageSize)
+}
+
+func (elb *ELB) describeLoadBalancers(include Include, names []string, marker string, pageSize int) (*DescribeLoadBalancerResp, error) {
 	params := map[string]string{"Action": "DescribeLoadBalancers"}
+	if marker != "" {
+		params["Marker"] = marker
+	}
+	if pageSize != 0 {
+		params["PageSize"] = strconv.Itoa(pageSize)
+	}
 	for i, name := range names {
 		index := fmt.Sprintf("LoadBalancerNames.member.%d", i+1)
 		params[index] = name
 	}
 	resp := new(DescribeLoadBalancerResp)
-	if err := elb.query(params, resp); err != nil {
+	var dest interface{} = resp
+	if include != IncludeAll {
+		dest = &projectedResp{resp: resp, excluded: include.excluded()}
+	}
+	if err := elb.query(params, dest); err != nil {
 		return nil, err
 	}
+	if resp.LoadBalancerDescriptions == nil {
+		resp.LoadBalancerDescriptions = []LoadBalancerDescription{}
+	}
+	if include.BackendServers {
+		for i := range resp.LoadBalancerDescriptions {
+			lb := &resp.LoadBalancerDescriptions[i]
+			if lb.BackendServerDescriptions == nil {
+				lb.BackendServerDescriptions = []BackendServerDescription{}
+			}
+		}
+	}
 	return resp, nil
 }
 
-type BackendServerDescriptions struct {
+// projectedResp decodes a DescribeLoadBalancers response, skipping the
+// excluded elements of each description.
+type projectedResp struct {
+	resp     *DescribeLoadBalancerResp
+	excluded map[string]bool
+}
+
+func (p *projectedResp) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	f := &sectionFilter{d: d, start: &start, excluded: p.excluded}
+	return xml.NewTokenDecoder(f).Decode(p.resp)
+}
+
+// descriptionSectionDepth is the depth of the sections of a description in
+// a DescribeLoadBalancers response:
+// DescribeLoadBalancersResponse > DescribeLoadBalancersResult >
+// LoadBalancerDescriptions > member > section.
+const descriptionSectionDepth = 5
+
+// sectionFilter passes the tokens of a DescribeLoadBalancers response
+// through, dropping the excluded sections of the descriptions.
+type sectionFilter struct {
+	d        *xml.Decoder
+	start    *xml.StartElement
+	excluded map[string]bool
+	depth    int
+}
+
+func (f *sectionFilter) Token() (xml.Token, error) {
+	if f.start != nil {
+		start := *f.start
+		f.start = nil
+		f.depth = 1
+		return start, nil
+	}
+	if f.depth == 0 {
+		return nil, io.EOF
+	}
+	for {
+		t, err := f.d.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := t.(type) {
+		case xml.StartElement:
+			if f.depth+1 == descriptionSectionDepth && f.excluded[t.Name.Local] {
+				if err := f.d.Skip(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			f.depth++
+		case xml.EndElement:
+			f.depth--
+		}
+		return xml.CopyToken(t), nil
+	}
+}
+
+// BackendServerDescription holds the policies applied to the connections
+// from a Load Balancer to an instance port, such as the ProxyProtocol policy.
+type BackendServerDescription struct {
 	InstancePort int      `xml:"InstancePort"`
 	PolicyNames  []string `xml:"PolicyNames>member"`
 }
 
+// BackendServerDescriptions is the former name of BackendServerDescription.
+//
+// Deprecated: use BackendServerDescription.
+type BackendServerDescriptions = BackendServerDescription
+
 type HealthCheck struct {
 	HealthyThreshold   int    `xml:"HealthyThreshold"`
 	Interval           int    `xml:"Interval"`
@@ -229,16 +556,20 @@ type InstanceState struct {
 //
 // See http://goo.gl/ovIB1 for more information.
 func (elb *ELB) DescribeInstanceHealth(lbName string, instanceIds ...string) (*DescribeInstanceHealthResp, error) {
+	return elb.describeInstanceHealth(context.Background(), lbName, instanceIds...)
+}
+
+func (elb *ELB) describeInstanceHealth(ctx context.Context, lbName string, instanceIds ...string) (*DescribeInstanceHealthResp, error) {
 	params := map[string]string{
 		"Action":           "DescribeInstanceHealth",
 		"LoadBalancerName": lbName,
 	}
-	for _, iId := range instanceIds {
-		key := fmt.Sprintf("Instances.member.1.InstanceId")
+	for i, iId := range instanceIds {
+		key := fmt.Sprintf("Instances.member.%d.InstanceId", i+1)
 		params[key] = iId
 	}
 	resp := new(DescribeInstanceHealthResp)
-	if err := elb.query(params, resp); err != nil {
+	if err := elb.queryContext(ctx, params, resp); err != nil {
 		return nil, err
 	}
 	return resp, nil
@@ -268,7 +599,626 @@ func (elb *ELB) ConfigureHealthCheck(lbName string, healthCheck *HealthCheck) (*
 	return resp, nil
 }
 
+// Creates listeners in a Load Balancer, validating them before the request
+// is sent, see ValidateListener.
+//
+// See http://docs.aws.amazon.com/ElasticLoadBalancing/latest/APIReference/API_CreateLoadBalancerListeners.html
+// for more details.
+func (elb *ELB) CreateLoadBalancerListeners(lbName string, listeners []Listener) (*SimpleResp, error) {
+	for i := range listeners {
+		if err := ValidateListener(&listeners[i]); err != nil {
+			return nil, err
+		}
+	}
+	params := map[string]string{
+		"Action":           "CreateLoadBalancerListeners",
+		"LoadBalancerName": lbName,
+	}
+	addListenerParams(params, listeners)
+	resp := new(SimpleResp)
+	if err := elb.query(params, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Deletes the listeners of a Load Balancer on the given Load Balancer ports.
+//
+// See http://docs.aws.amazon.com/ElasticLoadBalancing/latest/APIReference/API_DeleteLoadBalancerListeners.html
+// for more details.
+func (elb *ELB) DeleteLoadBalancerListeners(lbName string, ports ...int) (*SimpleResp, error) {
+	params := map[string]string{
+		"Action":           "DeleteLoadBalancerListeners",
+		"LoadBalancerName": lbName,
+	}
+	for i, port := range ports {
+		key := fmt.Sprintf("LoadBalancerPorts.member.%d", i+1)
+		params[key] = strconv.Itoa(port)
+	}
+	resp := new(SimpleResp)
+	if err := elb.query(params, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+type EnableAvailabilityZonesForLoadBalancerResp struct {
+	AvailabilityZones []string `xml:"EnableAvailabilityZonesForLoadBalancerResult>AvailabilityZones>member"`
+	RequestId         string   `xml:"ResponseMetadata>RequestId"`
+}
+
+// Adds availability zones to a Load Balancer in EC2-Classic. The response
+// holds all the availability zones of the Load Balancer.
+//
+// See http://docs.aws.amazon.com/ElasticLoadBalancing/latest/APIReference/API_EnableAvailabilityZonesForLoadBalancer.html
+// for more details.
+func (elb *ELB) EnableAvailabilityZonesForLoadBalancer(lbName string, zones []string) (*EnableAvailabilityZonesForLoadBalancerResp, error) {
+	params := map[string]string{
+		"Action":           "EnableAvailabilityZonesForLoadBalancer",
+		"LoadBalancerName": lbName,
+	}
+	for i, zone := range zones {
+		key := fmt.Sprintf("AvailabilityZones.member.%d", i+1)
+		params[key] = zone
+	}
+	resp := new(EnableAvailabilityZonesForLoadBalancerResp)
+	if err := elb.query(params, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+type DisableAvailabilityZonesForLoadBalancerResp struct {
+	AvailabilityZones []string `xml:"DisableAvailabilityZonesForLoadBalancerResult>AvailabilityZones>member"`
+	RequestId         string   `xml:"ResponseMetadata>RequestId"`
+}
+
+// Removes availability zones from a Load Balancer in EC2-Classic. The
+// response holds the remaining availability zones of the Load Balancer. ELB
+// refuses to remove all the availability zones of a Load Balancer.
+//
+// See http://docs.aws.amazon.com/ElasticLoadBalancing/latest/APIReference/API_DisableAvailabilityZonesForLoadBalancer.html
+// for more details.
+func (elb *ELB) DisableAvailabilityZonesForLoadBalancer(lbName string, zones []string) (*DisableAvailabilityZonesForLoadBalancerResp, error) {
+	params := map[string]string{
+		"Action":           "DisableAvailabilityZonesForLoadBalancer",
+		"LoadBalancerName": lbName,
+	}
+	for i, zone := range zones {
+		key := fmt.Sprintf("AvailabilityZones.member.%d", i+1)
+		params[key] = zone
+	}
+	resp := new(DisableAvailabilityZonesForLoadBalancerResp)
+	if err := elb.query(params, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+type AttachLoadBalancerToSubnetsResp struct {
+	Subnets   []string `xml:"AttachLoadBalancerToSubnetsResult>Subnets>member"`
+	RequestId string   `xml:"ResponseMetadata>RequestId"`
+}
+
+// Adds subnets to a Load Balancer in a VPC.
+//
+// See http://docs.aws.amazon.com/ElasticLoadBalancing/latest/APIReference/API_AttachLoadBalancerToSubnets.html
+// for more details.
+func (elb *ELB) AttachLoadBalancerToSubnets(lbName string, subnets []string) (*AttachLoadBalancerToSubnetsResp, error) {
+	params := map[string]string{
+		"Action":           "AttachLoadBalancerToSubnets",
+		"LoadBalancerName": lbName,
+	}
+	for i, subnet := range subnets {
+		key := fmt.Sprintf("Subnets.member.%d", i+1)
+		params[key] = subnet
+	}
+	resp := new(AttachLoadBalancerToSubnetsResp)
+	if err := elb.query(params, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+type DetachLoadBalancerFromSubnetsResp struct {
+	Subnets   []string `xml:"DetachLoadBalancerFromSubnetsResult>Subnets>member"`
+	RequestId string   `xml:"ResponseMetadata>RequestId"`
+}
+
+// Removes subnets from a Load Balancer in a VPC. A Load Balancer can't be
+// removed from all its subnets.
+//
+// See http://docs.aws.amazon.com/ElasticLoadBalancing/latest/APIReference/API_DetachLoadBalancerFromSubnets.html
+// for more details.
+func (elb *ELB) DetachLoadBalancerFromSubnets(lbName string, subnets []string) (*DetachLoadBalancerFromSubnetsResp, error) {
+	params := map[string]string{
+		"Action":           "DetachLoadBalancerFromSubnets",
+		"LoadBalancerName": lbName,
+	}
+	for i, subnet := range subnets {
+		key := fmt.Sprintf("Subnets.member.%d", i+1)
+		params[key] = subnet
+	}
+	resp := new(DetachLoadBalancerFromSubnetsResp)
+	if err := elb.query(params, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+type ApplySecurityGroupsToLoadBalancerResp struct {
+	SecurityGroups []string `xml:"ApplySecurityGroupsToLoadBalancerResult>SecurityGroups>member"`
+	RequestId      string   `xml:"ResponseMetadata>RequestId"`
+}
+
+// Replaces the security groups of a Load Balancer in a VPC.
+//
+// See http://docs.aws.amazon.com/ElasticLoadBalancing/latest/APIReference/API_ApplySecurityGroupsToLoadBalancer.html
+// for more details.
+func (elb *ELB) ApplySecurityGroupsToLoadBalancer(lbName string, securityGroups []string) (*ApplySecurityGroupsToLoadBalancerResp, error) {
+	params := map[string]string{
+		"Action":           "ApplySecurityGroupsToLoadBalancer",
+		"LoadBalancerName": lbName,
+	}
+	for i, group := range securityGroups {
+		key := fmt.Sprintf("SecurityGroups.member.%d", i+1)
+		params[key] = group
+	}
+	resp := new(ApplySecurityGroupsToLoadBalancerResp)
+	if err := elb.query(params, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Replaces the policies applied to the connections from a Load Balancer to
+// the given instance port. An empty list of policies removes all of them.
+//
+// See http://docs.aws.amazon.com/ElasticLoadBalancing/latest/APIReference/API_SetLoadBalancerPoliciesForBackendServer.html
+// for more details.
+func (elb *ELB) SetLoadBalancerPoliciesForBackendServer(lbName string, instancePort int, policyNames []string) (*SimpleResp, error) {
+	params := map[string]string{
+		"Action":           "SetLoadBalancerPoliciesForBackendServer",
+		"LoadBalancerName": lbName,
+		"InstancePort":     strconv.Itoa(instancePort),
+	}
+	if len(policyNames) == 0 {
+		params["PolicyNames"] = ""
+	}
+	for i, name := range policyNames {
+		key := fmt.Sprintf("PolicyNames.member.%d", i+1)
+		params[key] = name
+	}
+	resp := new(SimpleResp)
+	if err := elb.query(params, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Replaces the policies of the listener on the given Load Balancer port. An
+// empty list of policies removes all of them.
+//
+// See http://docs.aws.amazon.com/ElasticLoadBalancing/latest/APIReference/API_SetLoadBalancerPoliciesOfListener.html
+// for more details.
+func (elb *ELB) SetLoadBalancerPoliciesOfListener(lbName string, lbPort int, policyNames []string) (*SimpleResp, error) {
+	params := map[string]string{
+		"Action":           "SetLoadBalancerPoliciesOfListener",
+		"LoadBalancerName": lbName,
+		"LoadBalancerPort": strconv.Itoa(lbPort),
+	}
+	if len(policyNames) == 0 {
+		params["PolicyNames"] = ""
+	}
+	for i, name := range policyNames {
+		key := fmt.Sprintf("PolicyNames.member.%d", i+1)
+		params[key] = name
+	}
+	resp := new(SimpleResp)
+	if err := elb.query(params, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Replaces the certificate of the HTTPS or SSL listener on the given Load
+// Balancer port.
+//
+// See http://docs.aws.amazon.com/ElasticLoadBalancing/latest/APIReference/API_SetLoadBalancerListenerSSLCertificate.html
+// for more details.
+func (elb *ELB) SetLoadBalancerListenerSSLCertificate(lbName string, lbPort int, certificateId string) (*SimpleResp, error) {
+	params := map[string]string{
+		"Action":           "SetLoadBalancerListenerSSLCertificate",
+		"LoadBalancerName": lbName,
+		"LoadBalancerPort": strconv.Itoa(lbPort),
+		"SSLCertificateId": certificateId,
+	}
+	resp := new(SimpleResp)
+	if err := elb.query(params, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Creates a stickiness policy that binds user sessions to instances with a
+// cookie generated by the Load Balancer. The cookie expires after
+// cookieExpirationPeriod seconds, or with the browser session when it's 0.
+//
+// See http://docs.aws.amazon.com/ElasticLoadBalancing/latest/APIReference/API_CreateLBCookieStickinessPolicy.html
+// for more details.
+func (elb *ELB) CreateLBCookieStickinessPolicy(lbName, policyName string, cookieExpirationPeriod int) (*SimpleResp, error) {
+	params := map[string]string{
+		"Action":           "CreateLBCookieStickinessPolicy",
+		"LoadBalancerName": lbName,
+		"PolicyName":       policyName,
+	}
+	if cookieExpirationPeriod > 0 {
+		params["CookieExpirationPeriod"] = strconv.Itoa(cookieExpirationPeriod)
+	}
+	resp := new(SimpleResp)
+	if err := elb.query(params, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Creates a stickiness policy that binds user sessions to instances with a
+// cookie generated by the application, named cookieName.
+//
+// See http://docs.aws.amazon.com/ElasticLoadBalancing/latest/APIReference/API_CreateAppCookieStickinessPolicy.html
+// for more details.
+func (elb *ELB) CreateAppCookieStickinessPolicy(lbName, policyName, cookieName string) (*SimpleResp, error) {
+	params := map[string]string{
+		"Action":           "CreateAppCookieStickinessPolicy",
+		"LoadBalancerName": lbName,
+		"PolicyName":       policyName,
+		"CookieName":       cookieName,
+	}
+	resp := new(SimpleResp)
+	if err := elb.query(params, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// PolicyAttribute is an attribute of a policy, given as a name and a value.
+type PolicyAttribute struct {
+	AttributeName  string `xml:"AttributeName"`
+	AttributeValue string `xml:"AttributeValue"`
+}
+
+// Creates a policy of the given type with the given attributes, like a
+// ProxyProtocolPolicyType policy with the attribute ProxyProtocol set to
+// true, or a SSLNegotiationPolicyType policy with a
+// Reference-Security-Policy. The policy takes effect once it's applied to a
+// listener or to a backend server.
+//
+// See http://docs.aws.amazon.com/ElasticLoadBalancing/latest/APIReference/API_CreateLoadBalancerPolicy.html
+// for more details.
+func (elb *ELB) CreateLoadBalancerPolicy(lbName, policyName, policyTypeName string, attrs []PolicyAttribute) (*SimpleResp, error) {
+	params := map[string]string{
+		"Action":           "CreateLoadBalancerPolicy",
+		"LoadBalancerName": lbName,
+		"PolicyName":       policyName,
+		"PolicyTypeName":   policyTypeName,
+	}
+	for i, attr := range attrs {
+		key := fmt.Sprintf("PolicyAttributes.member.%d.", i+1)
+		params[key+"AttributeName"] = attr.AttributeName
+		params[key+"AttributeValue"] = attr.AttributeValue
+	}
+	resp := new(SimpleResp)
+	if err := elb.query(params, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Deletes a policy from a Load Balancer. ELB refuses to delete a policy that
+// is applied to a listener or to a backend server.
+//
+// See http://docs.aws.amazon.com/ElasticLoadBalancing/latest/APIReference/API_DeleteLoadBalancerPolicy.html
+// for more details.
+func (elb *ELB) DeleteLoadBalancerPolicy(lbName, policyName string) (*SimpleResp, error) {
+	params := map[string]string{
+		"Action":           "DeleteLoadBalancerPolicy",
+		"LoadBalancerName": lbName,
+		"PolicyName":       policyName,
+	}
+	resp := new(SimpleResp)
+	if err := elb.query(params, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// PolicyDescription describes a policy and its attributes.
+type PolicyDescription struct {
+	PolicyName                  string            `xml:"PolicyName"`
+	PolicyTypeName              string            `xml:"PolicyTypeName"`
+	PolicyAttributeDescriptions []PolicyAttribute `xml:"PolicyAttributeDescriptions>member"`
+}
+
+type DescribeLoadBalancerPoliciesResp struct {
+	PolicyDescriptions []PolicyDescription `xml:"DescribeLoadBalancerPoliciesResult>PolicyDescriptions>member"`
+	RequestId          string              `xml:"ResponseMetadata>RequestId"`
+}
+
+// Describes the policies of a Load Balancer, or only the given ones. When
+// lbName is empty, the sample policies provided by AWS are described
+// instead. When there are no policies to describe, the response holds an
+// empty list.
+//
+// See http://docs.aws.amazon.com/ElasticLoadBalancing/latest/APIReference/API_DescribeLoadBalancerPolicies.html
+// for more details.
+func (elb *ELB) DescribeLoadBalancerPolicies(lbName string, policyNames ...string) (*DescribeLoadBalancerPoliciesResp, error) {
+	params := map[string]string{"Action": "DescribeLoadBalancerPolicies"}
+	if lbName != "" {
+		params["LoadBalancerName"] = lbName
+	}
+	for i, name := range policyNames {
+		key := fmt.Sprintf("PolicyNames.member.%d", i+1)
+		params[key] = name
+	}
+	resp := new(DescribeLoadBalancerPoliciesResp)
+	if err := elb.query(params, resp); err != nil {
+		return nil, err
+	}
+	if resp.PolicyDescriptions == nil {
+		resp.PolicyDescriptions = []PolicyDescription{}
+	}
+	return resp, nil
+}
+
+// PolicyAttributeTypeDescription describes an attribute of a policy type.
+type PolicyAttributeTypeDescription struct {
+	AttributeName string `xml:"AttributeName"`
+	AttributeType string `xml:"AttributeType"`
+	Description   string `xml:"Description"`
+	DefaultValue  string `xml:"DefaultValue"`
+	Cardinality   string `xml:"Cardinality"`
+}
+
+// PolicyTypeDescription describes a policy type and the attributes that
+// policies of this type take.
+type PolicyTypeDescription struct {
+	PolicyTypeName                  string                           `xml:"PolicyTypeName"`
+	Description                     string                           `xml:"Description"`
+	PolicyAttributeTypeDescriptions []PolicyAttributeTypeDescription `xml:"PolicyAttributeTypeDescriptions>member"`
+}
+
+type DescribeLoadBalancerPolicyTypesResp struct {
+	PolicyTypeDescriptions []PolicyTypeDescription `xml:"DescribeLoadBalancerPolicyTypesResult>PolicyTypeDescriptions>member"`
+	RequestId              string                  `xml:"ResponseMetadata>RequestId"`
+}
+
+// Describes the given policy types, or all the policy types when no name is
+// given.
+//
+// See http://docs.aws.amazon.com/ElasticLoadBalancing/latest/APIReference/API_DescribeLoadBalancerPolicyTypes.html
+// for more details.
+func (elb *ELB) DescribeLoadBalancerPolicyTypes(typeNames ...string) (*DescribeLoadBalancerPolicyTypesResp, error) {
+	params := map[string]string{"Action": "DescribeLoadBalancerPolicyTypes"}
+	for i, name := range typeNames {
+		key := fmt.Sprintf("PolicyTypeNames.member.%d", i+1)
+		params[key] = name
+	}
+	resp := new(DescribeLoadBalancerPolicyTypesResp)
+	if err := elb.query(params, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Tag is a key-value pair assigned to a Load Balancer.
+type Tag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+// Adds tags to the given Load Balancers. The value of a tag that the Load
+// Balancer already has is replaced.
+//
+// See http://docs.aws.amazon.com/ElasticLoadBalancing/latest/APIReference/API_AddTags.html
+// for more details.
+func (elb *ELB) AddTags(lbNames []string, tags []Tag) (*SimpleResp, error) {
+	params := map[string]string{"Action": "AddTags"}
+	for i, name := range lbNames {
+		key := fmt.Sprintf("LoadBalancerNames.member.%d", i+1)
+		params[key] = name
+	}
+	for i, tag := range tags {
+		key := fmt.Sprintf("Tags.member.%d.", i+1)
+		params[key+"Key"] = tag.Key
+		params[key+"Value"] = tag.Value
+	}
+	resp := new(SimpleResp)
+	if err := elb.query(params, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Removes the tags with the given keys from the given Load Balancers.
+//
+// See http://docs.aws.amazon.com/ElasticLoadBalancing/latest/APIReference/API_RemoveTags.html
+// for more details.
+func (elb *ELB) RemoveTags(lbNames []string, keys []string) (*SimpleResp, error) {
+	params := map[string]string{"Action": "RemoveTags"}
+	for i, name := range lbNames {
+		key := fmt.Sprintf("LoadBalancerNames.member.%d", i+1)
+		params[key] = name
+	}
+	for i, k := range keys {
+		key := fmt.Sprintf("Tags.member.%d.Key", i+1)
+		params[key] = k
+	}
+	resp := new(SimpleResp)
+	if err := elb.query(params, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// TagDescription holds the tags of a Load Balancer.
+type TagDescription struct {
+	LoadBalancerName string `xml:"LoadBalancerName"`
+	Tags             []Tag  `xml:"Tags>member"`
+}
+
+type DescribeTagsResp struct {
+	TagDescriptions []TagDescription `xml:"DescribeTagsResult>TagDescriptions>member"`
+	RequestId       string           `xml:"ResponseMetadata>RequestId"`
+}
+
+// Describes the tags of the given Load Balancers, up to 20 of them.
+//
+// See http://docs.aws.amazon.com/ElasticLoadBalancing/latest/APIReference/API_DescribeTags.html
+// for more details.
+func (elb *ELB) DescribeTags(lbNames ...string) (*DescribeTagsResp, error) {
+	params := map[string]string{"Action": "DescribeTags"}
+	for i, name := range lbNames {
+		key := fmt.Sprintf("LoadBalancerNames.member.%d", i+1)
+		params[key] = name
+	}
+	resp := new(DescribeTagsResp)
+	if err := elb.query(params, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Limit is a limit of the resources of an account, such as
+// "classic-load-balancers". Max is the maximum value of the resource.
+type Limit struct {
+	Name string `xml:"Name"`
+	Max  string `xml:"Max"`
+}
+
+type DescribeAccountLimitsResp struct {
+	Limits    []Limit `xml:"DescribeAccountLimitsResult>Limits>member"`
+	RequestId string  `xml:"ResponseMetadata>RequestId"`
+}
+
+// Describes the limits of the resources of the account.
+//
+// See http://docs.aws.amazon.com/ElasticLoadBalancing/latest/APIReference/API_DescribeAccountLimits.html
+// for more details.
+func (elb *ELB) DescribeAccountLimits() (*DescribeAccountLimitsResp, error) {
+	params := map[string]string{"Action": "DescribeAccountLimits"}
+	resp := new(DescribeAccountLimitsResp)
+	if err := elb.query(params, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// LoadBalancerAttributes holds the attributes of a Load Balancer. Only the
+// attributes that are not nil are modified by ModifyLoadBalancerAttributes.
+//
+// See http://docs.aws.amazon.com/ElasticLoadBalancing/latest/APIReference/API_LoadBalancerAttributes.html
+// for more information.
+type LoadBalancerAttributes struct {
+	AccessLog              *AccessLog              `xml:"AccessLog,omitempty"`
+	ConnectionDraining     *ConnectionDraining     `xml:"ConnectionDraining,omitempty"`
+	ConnectionSettings     *ConnectionSettings     `xml:"ConnectionSettings,omitempty"`
+	CrossZoneLoadBalancing *CrossZoneLoadBalancing `xml:"CrossZoneLoadBalancing,omitempty"`
+}
+
+type AccessLog struct {
+	Enabled        bool   `xml:"Enabled"`
+	S3BucketName   string `xml:"S3BucketName,omitempty"`
+	S3BucketPrefix string `xml:"S3BucketPrefix,omitempty"`
+	EmitInterval   int    `xml:"EmitInterval,omitempty"`
+}
+
+type ConnectionDraining struct {
+	Enabled bool `xml:"Enabled"`
+	Timeout int  `xml:"Timeout,omitempty"`
+}
+
+type ConnectionSettings struct {
+	IdleTimeout int `xml:"IdleTimeout"`
+}
+
+type CrossZoneLoadBalancing struct {
+	Enabled bool `xml:"Enabled"`
+}
+
+type ModifyLoadBalancerAttributesResp struct {
+	LoadBalancerName       string                 `xml:"ModifyLoadBalancerAttributesResult>LoadBalancerName"`
+	LoadBalancerAttributes LoadBalancerAttributes `xml:"ModifyLoadBalancerAttributesResult>LoadBalancerAttributes"`
+	RequestId              string                 `xml:"ResponseMetadata>RequestId"`
+}
+
+// Modifies the attributes of a Load Balancer.
+//
+// See http://docs.aws.amazon.com/ElasticLoadBalancing/latest/APIReference/API_ModifyLoadBalancerAttributes.html
+// for more details.
+func (elb *ELB) ModifyLoadBalancerAttributes(lbName string, attrs *LoadBalancerAttributes) (*ModifyLoadBalancerAttributesResp, error) {
+	params := map[string]string{
+		"Action":           "ModifyLoadBalancerAttributes",
+		"LoadBalancerName": lbName,
+	}
+	key := "LoadBalancerAttributes.%s"
+	if l := attrs.AccessLog; l != nil {
+		params[fmt.Sprintf(key, "AccessLog.Enabled")] = strconv.FormatBool(l.Enabled)
+		if l.S3BucketName != "" {
+			params[fmt.Sprintf(key, "AccessLog.S3BucketName")] = l.S3BucketName
+		}
+		if l.S3BucketPrefix != "" {
+			params[fmt.Sprintf(key, "AccessLog.S3BucketPrefix")] = l.S3BucketPrefix
+		}
+		if l.EmitInterval != 0 {
+			params[fmt.Sprintf(key, "AccessLog.EmitInterval")] = strconv.Itoa(l.EmitInterval)
+		}
+	}
+	if d := attrs.ConnectionDraining; d != nil {
+		params[fmt.Sprintf(key, "ConnectionDraining.Enabled")] = strconv.FormatBool(d.Enabled)
+		if d.Timeout != 0 {
+			params[fmt.Sprintf(key, "ConnectionDraining.Timeout")] = strconv.Itoa(d.Timeout)
+		}
+	}
+	if cs := attrs.ConnectionSettings; cs != nil {
+		params[fmt.Sprintf(key, "ConnectionSettings.IdleTimeout")] = strconv.Itoa(cs.IdleTimeout)
+	}
+	if cz := attrs.CrossZoneLoadBalancing; cz != nil {
+		params[fmt.Sprintf(key, "CrossZoneLoadBalancing.Enabled")] = strconv.FormatBool(cz.Enabled)
+	}
+	resp := new(ModifyLoadBalancerAttributesResp)
+	if err := elb.query(params, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+type DescribeLoadBalancerAttributesResp struct {
+	LoadBalancerAttributes LoadBalancerAttributes `xml:"DescribeLoadBalancerAttributesResult>LoadBalancerAttributes"`
+	RequestId              string                 `xml:"ResponseMetadata>RequestId"`
+}
+
+// Describes the attributes of a Load Balancer.
+//
+// See http://docs.aws.amazon.com/ElasticLoadBalancing/latest/APIReference/API_DescribeLoadBalancerAttributes.html
+// for more details.
+func (elb *ELB) DescribeLoadBalancerAttributes(lbName string) (*DescribeLoadBalancerAttributesResp, error) {
+	params := map[string]string{
+		"Action":           "DescribeLoadBalancerAttributes",
+		"LoadBalancerName": lbName,
+	}
+	resp := new(DescribeLoadBalancerAttributesResp)
+	if err := elb.query(params, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
 func (elb *ELB) query(params map[string]string, resp interface{}) error {
+	return elb.queryContext(context.Background(), params, resp)
+}
+
+// queryContext works like query, giving up on the request when ctx is done.
+func (elb *ELB) queryContext(ctx context.Context, params map[string]string, resp interface{}) error {
 	params["Version"] = "2012-06-01"
 	params["Timestamp"] = time.Now().In(time.UTC).Format(time.RFC3339)
 	endpoint, err := url.Parse(elb.Region.ELBEndpoint)
@@ -278,17 +1228,41 @@ func (elb *ELB) query(params map[string]string, resp interface{}) error {
 	if endpoint.Path == "" {
 		endpoint.Path = "/"
 	}
-	sign(elb.Auth, "GET", endpoint.Path, params, endpoint.Host)
+	if !elb.unsigned {
+		sign(elb.Auth, "GET", endpoint.Path, params, endpoint.Host)
+	}
 	endpoint.RawQuery = multimap(params).Encode()
-	r, err := http.Get(endpoint.String())
+	req, err := http.NewRequest("GET", endpoint.String(), nil)
+	if err != nil {
+		return err
+	}
+	r, err := http.DefaultClient.Do(req.WithContext(ctx))
 	if err != nil {
 		return err
 	}
 	defer r.Body.Close()
+	elb.raw.Lock()
+	capture := elb.raw.enabled
+	elb.raw.Unlock()
+	if capture {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return err
+		}
+		elb.raw.Lock()
+		if elb.raw.enabled {
+			elb.raw.body = body
+		}
+		elb.raw.Unlock()
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
 	if r.StatusCode != 200 {
 		return buildError(r)
 	}
-	return xml.NewDecoder(r.Body).Decode(resp)
+	if err := xml.NewDecoder(r.Body).Decode(resp); err != nil {
+		return fmt.Errorf("cannot decode the %s response: %v", params["Action"], err)
+	}
+	return nil
 }
 
 // Error encapsulates an error returned by ELB.
@@ -299,6 +1273,8 @@ type Error struct {
 	Code string
 	// The human-oriented error message
 	Message string
+	// The id of the request, to be referenced in support requests
+	RequestId string
 }
 
 func (err *Error) Error() string {
@@ -310,7 +1286,54 @@ func (err *Error) Error() string {
 }
 
 type xmlErrors struct {
-	Errors []Error `xml:"Error"`
+	RequestId string  `xml:"RequestId"`
+	Errors    []Error `xml:"Error"`
+}
+
+// defaultRetryAfter is the delay suggested by a ThrottleError when ELB does
+// not send a Retry-After header.
+const defaultRetryAfter = time.Second
+
+// ThrottleError is the error returned instead of an *Error when ELB
+// throttles a request, which it reports with the Throttling error code.
+// RetryAfter is the delay suggested before sending the request again, taken
+// from the Retry-After header of the response or defaulting to one second.
+//
+// Callers that manage their own backoff can detect it with errors.As:
+//
+//	var throttled *elb.ThrottleError
+//	if errors.As(err, &throttled) {
+//	    time.Sleep(throttled.RetryAfter)
+//	}
+//
+// The underlying *Error is returned by Unwrap, so errors.As also finds it.
+type ThrottleError struct {
+	Err        *Error
+	RetryAfter time.Duration
+}
+
+func (err *ThrottleError) Error() string {
+	return err.Err.Error()
+}
+
+func (err *ThrottleError) Unwrap() error {
+	return err.Err
+}
+
+// retryAfter parses the Retry-After header of a response, which holds either
+// a number of seconds or an HTTP date.
+func retryAfter(r *http.Response) time.Duration {
+	value := r.Header.Get("Retry-After")
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := t.Sub(time.Now()); d > 0 {
+			return d
+		}
+		return 0
+	}
+	return defaultRetryAfter
 }
 
 func buildError(r *http.Response) error {
@@ -322,10 +1345,14 @@ func buildError(r *http.Response) error {
 	if len(errors.Errors) > 0 {
 		err = errors.Errors[0]
 	}
+	err.RequestId = errors.RequestId
 	err.StatusCode = r.StatusCode
 	if err.Message == "" {
 		err.Message = r.Status
 	}
+	if err.Code == "Throttling" {
+		return &ThrottleError{Err: &err, RetryAfter: retryAfter(r)}
+	}
 	return &err
 }
 
@@ -352,17 +1379,24 @@ func makeCreateParams(createLB *CreateLoadBalancer) map[string]string {
 		key := fmt.Sprintf("Subnets.member.%d", i+1)
 		params[key] = s
 	}
-	for i, l := range createLB.Listeners {
+	addListenerParams(params, createLB.Listeners)
+	for i, az := range createLB.AvailZones {
+		key := fmt.Sprintf("AvailabilityZones.member.%d", i+1)
+		params[key] = az
+	}
+	return params
+}
+
+func addListenerParams(params map[string]string, listeners []Listener) {
+	for i, l := range listeners {
 		key := "Listeners.member.%d.%s"
 		index := i + 1
 		params[fmt.Sprintf(key, index, "InstancePort")] = strconv.Itoa(l.InstancePort)
 		params[fmt.Sprintf(key, index, "InstanceProtocol")] = l.InstanceProtocol
 		params[fmt.Sprintf(key, index, "Protocol")] = l.Protocol
 		params[fmt.Sprintf(key, index, "LoadBalancerPort")] = strconv.Itoa(l.LoadBalancerPort)
+		if l.SSLCertificateId != "" {
+			params[fmt.Sprintf(key, index, "SSLCertificateId")] = l.SSLCertificateId
+		}
 	}
-	for i, az := range createLB.AvailZones {
-		key := fmt.Sprintf("AvailabilityZones.member.%d", i+1)
-		params[key] = az
-	}
-	return params
 }
@@ -1,9 +1,11 @@
 package elb_test
 
 import (
+	"errors"
 	"github.com/flaviamissi/go-elb/aws"
 	"github.com/flaviamissi/go-elb/elb"
 	. "launchpad.net/gocheck"
+	"strings"
 	"time"
 )
 
@@ -20,6 +22,42 @@ func (s *S) SetUpSuite(c *C) {
 	s.elb = elb.New(auth, aws.Region{ELBEndpoint: testServer.URL})
 }
 
+func (s *S) TestNewForTestingDoesNotSignRequests(c *C) {
+	testServer.PrepareResponse(200, nil, DeleteLoadBalancer)
+	client := elb.NewForTesting(testServer.URL)
+	_, err := client.DeleteLoadBalancer("testlb")
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	c.Assert(values.Get("Action"), Equals, "DeleteLoadBalancer")
+	c.Assert(values.Get("Version"), Equals, "2012-06-01")
+	c.Assert(values.Get("Signature"), Equals, "")
+	c.Assert(values.Get("AWSAccessKeyId"), Equals, "")
+}
+
+func (s *S) TestEndpointForRegion(c *C) {
+	c.Assert(elb.EndpointForRegion("us-east-1"), Equals, "https://elasticloadbalancing.us-east-1.amazonaws.com")
+	c.Assert(elb.EndpointForRegion("eu-west-1"), Equals, "https://elasticloadbalancing.eu-west-1.amazonaws.com")
+	c.Assert(elb.EndpointForRegion("us-gov-west-1"), Equals, "https://elasticloadbalancing.us-gov-west-1.amazonaws.com")
+	c.Assert(elb.EndpointForRegion("cn-north-1"), Equals, "https://elasticloadbalancing.cn-north-1.amazonaws.com.cn")
+	c.Assert(elb.EndpointForRegion("cn-northwest-1"), Equals, "https://elasticloadbalancing.cn-northwest-1.amazonaws.com.cn")
+	c.Assert(elb.EndpointForRegion("us-iso-east-1"), Equals, "https://elasticloadbalancing.us-iso-east-1.c2s.ic.gov")
+	c.Assert(elb.EndpointForRegion("us-isob-east-1"), Equals, "https://elasticloadbalancing.us-isob-east-1.sc2s.sgov.gov")
+	c.Assert(elb.EndpointForRegion(""), Equals, "")
+}
+
+func (s *S) TestEndpointForRegionMatchesTheKnownRegions(c *C) {
+	for name, region := range aws.Regions {
+		c.Assert(elb.EndpointForRegion(name), Equals, region.ELBEndpoint)
+	}
+}
+
+func (s *S) TestNewComputesTheMissingEndpoint(c *C) {
+	client := elb.New(aws.Auth{"abc", "123"}, aws.Region{Name: "cn-north-1"})
+	c.Assert(client.ELBEndpoint, Equals, "https://elasticloadbalancing.cn-north-1.amazonaws.com.cn")
+	client = elb.New(aws.Auth{"abc", "123"}, aws.Region{Name: "cn-north-1", ELBEndpoint: testServer.URL})
+	c.Assert(client.ELBEndpoint, Equals, testServer.URL)
+}
+
 func (s *S) TestCreateLoadBalancer(c *C) {
 	testServer.PrepareResponse(200, nil, CreateLoadBalancer)
 	createLB := &elb.CreateLoadBalancer{
@@ -109,6 +147,123 @@ func (s *S) TestCreateLoadBalancerWithWrongParamsCombination(c *C) {
 	c.Assert(ok, Equals, true)
 	c.Assert(e.Message, Equals, "Only one of SubnetIds or AvailabilityZones may be specified")
 	c.Assert(e.Code, Equals, "ValidationError")
+	c.Assert(e.RequestId, Equals, "159253fc-49dc-11e2-a47d-cde463c91a3c")
+}
+
+func (s *S) TestCreateLoadBalancerValidatesNameBeforeRequest(c *C) {
+	names := map[string]string{
+		"a12345678901234567890123456789012": "1 validation error detected: Value 'a12345678901234567890123456789012' at 'loadBalancerName' failed to satisfy constraint: Member must have length less than or equal to 32",
+		"test_lb":                           "LoadBalancerName must contain only alphanumeric characters or hyphens, and cannot begin or end with hyphen",
+		"-testlb":                           "LoadBalancerName must contain only alphanumeric characters or hyphens, and cannot begin or end with hyphen",
+		"testlb-":                           "LoadBalancerName must contain only alphanumeric characters or hyphens, and cannot begin or end with hyphen",
+		"":                                  "LoadBalancerName must contain only alphanumeric characters or hyphens, and cannot begin or end with hyphen",
+	}
+	for name, message := range names {
+		createLB := &elb.CreateLoadBalancer{
+			Name:       name,
+			AvailZones: []string{"us-east-1a"},
+			Listeners: []elb.Listener{
+				{
+					InstancePort:     80,
+					InstanceProtocol: "http",
+					Protocol:         "http",
+					LoadBalancerPort: 80,
+				},
+			},
+		}
+		resp, err := s.elb.CreateLoadBalancer(createLB)
+		c.Check(resp, IsNil)
+		e, ok := err.(*elb.Error)
+		c.Assert(ok, Equals, true)
+		c.Check(e.Code, Equals, "ValidationError")
+		c.Check(e.Message, Equals, message)
+		c.Check(e.StatusCode, Equals, 0)
+	}
+	c.Assert(testServer.request, HasLen, 0)
+}
+
+func (s *S) TestValidateLoadBalancerName(c *C) {
+	c.Assert(elb.ValidateLoadBalancerName("a1234567890123456789012345678901"), IsNil)
+	c.Assert(elb.ValidateLoadBalancerName("test-lb"), IsNil)
+	c.Assert(elb.ValidateLoadBalancerName("1"), IsNil)
+	c.Assert(elb.ValidateLoadBalancerName("a12345678901234567890123456789012"), ErrorMatches, ".*length less than or equal to 32.*")
+	c.Assert(elb.ValidateLoadBalancerName("test lb"), ErrorMatches, ".*only alphanumeric characters or hyphens.*")
+	c.Assert(elb.ValidateLoadBalancerName("-testlb"), ErrorMatches, ".*cannot begin or end with hyphen.*")
+	c.Assert(elb.ValidateLoadBalancerName("testlb-"), ErrorMatches, ".*cannot begin or end with hyphen.*")
+}
+
+func (s *S) TestCreateLoadBalancerWithSSLCertificate(c *C) {
+	testServer.PrepareResponse(200, nil, CreateLoadBalancer)
+	createLB := &elb.CreateLoadBalancer{
+		Name:       "testlb",
+		AvailZones: []string{"us-east-1a"},
+		Listeners: []elb.Listener{
+			{
+				InstancePort:     80,
+				InstanceProtocol: "http",
+				Protocol:         "https",
+				LoadBalancerPort: 443,
+				SSLCertificateId: "arn:aws:iam::123456789012:server-certificate/mycert",
+			},
+		},
+	}
+	_, err := s.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	c.Assert(values.Get("Listeners.member.1.Protocol"), Equals, "https")
+	c.Assert(values.Get("Listeners.member.1.SSLCertificateId"), Equals, "arn:aws:iam::123456789012:server-certificate/mycert")
+}
+
+func (s *S) TestCreateLoadBalancerValidatesSSLCertificateBeforeRequest(c *C) {
+	createLB := &elb.CreateLoadBalancer{
+		Name:       "testlb",
+		AvailZones: []string{"us-east-1a"},
+		Listeners: []elb.Listener{
+			{
+				InstancePort:     443,
+				InstanceProtocol: "ssl",
+				Protocol:         "ssl",
+				LoadBalancerPort: 443,
+				SSLCertificateId: "mycert",
+			},
+		},
+	}
+	resp, err := s.elb.CreateLoadBalancer(createLB)
+	c.Assert(resp, IsNil)
+	e, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.Code, Equals, "CertificateNotFound")
+	c.Assert(e.Message, Equals, "SSLCertificateId 'mycert' of the SSL listener on port 443 is not an IAM server certificate ARN or an ACM certificate ARN")
+	c.Assert(testServer.request, HasLen, 0)
+}
+
+func (s *S) TestValidateListener(c *C) {
+	valid := []string{
+		"arn:aws:iam::123456789012:server-certificate/mycert",
+		"arn:aws:iam::123456789012:server-certificate/path/to/mycert",
+		"arn:aws-cn:iam::123456789012:server-certificate/mycert",
+		"arn:aws:acm:us-east-1:123456789012:certificate/12345678-1234-1234-1234-123456789012",
+		"arn:aws-us-gov:acm:us-gov-west-1:123456789012:certificate/12345678-1234-1234-1234-123456789012",
+	}
+	for _, id := range valid {
+		l := elb.Listener{Protocol: "HTTPS", LoadBalancerPort: 443, SSLCertificateId: id}
+		c.Check(elb.ValidateListener(&l), IsNil)
+	}
+	invalid := []string{
+		"",
+		"mycert",
+		"arn:aws:iam::123456789012:mycert",
+		"arn:aws:iam::1234:server-certificate/mycert",
+		"arn:aws:acm:us-east-1:123456789012:certificate/",
+		"arn:aws:s3:::mybucket",
+	}
+	for _, id := range invalid {
+		l := elb.Listener{Protocol: "https", LoadBalancerPort: 443, SSLCertificateId: id}
+		c.Check(elb.ValidateListener(&l), ErrorMatches, ".*is not an IAM server certificate ARN or an ACM certificate ARN.*")
+	}
+	// certificates are only required by HTTPS and SSL listeners
+	l := elb.Listener{Protocol: "HTTP", LoadBalancerPort: 80, SSLCertificateId: "mycert"}
+	c.Check(elb.ValidateListener(&l), IsNil)
 }
 
 func (s *S) TestDeleteLoadBalancer(c *C) {
@@ -187,10 +342,10 @@ func (s *S) TestDescribeLoadBalancers(c *C) {
 	c.Assert(values.Get("Action"), Equals, "DescribeLoadBalancers")
 	t, _ := time.Parse(time.RFC3339, "2012-12-27T11:51:52.970Z")
 	expected := &elb.DescribeLoadBalancerResp{
-		[]elb.LoadBalancerDescription{
+		LoadBalancerDescriptions: []elb.LoadBalancerDescription{
 			{
 				AvailZones:                []string{"us-east-1a"},
-				BackendServerDescriptions: []elb.BackendServerDescriptions(nil),
+				BackendServerDescriptions: []elb.BackendServerDescription{},
 				CanonicalHostedZoneName:   "testlb-2087227216.us-east-1.elb.amazonaws.com",
 				CanonicalHostedZoneNameId: "Z3DZXE0Q79N41H",
 				CreatedTime:               t,
@@ -228,6 +383,81 @@ func (s *S) TestDescribeLoadBalancers(c *C) {
 	c.Assert(resp, DeepEquals, expected)
 }
 
+func (s *S) TestDescribeLoadBalancersParsesHTTPHealthCheck(c *C) {
+	body := strings.Replace(DescribeLoadBalancers, "<Target>TCP:80</Target>", "<Target>HTTP:8080/ping</Target>", 1)
+	body = strings.Replace(body, "<Interval>30</Interval>", "<Interval>15</Interval>", 1)
+	testServer.PrepareResponse(200, nil, body)
+	resp, err := s.elb.DescribeLoadBalancers()
+	c.Assert(err, IsNil)
+	expected := elb.HealthCheck{
+		HealthyThreshold:   10,
+		Interval:           15,
+		Target:             "HTTP:8080/ping",
+		Timeout:            5,
+		UnhealthyThreshold: 2,
+	}
+	c.Assert(resp.LoadBalancerDescriptions[0].HealthCheck, DeepEquals, expected)
+}
+
+func (s *S) TestDescribeLoadBalancersByNameMap(c *C) {
+	resp := &elb.DescribeLoadBalancerResp{
+		LoadBalancerDescriptions: []elb.LoadBalancerDescription{
+			{LoadBalancerName: "lb1", DNSName: "lb1-1.us-east-1.elb.amazonaws.com"},
+			{LoadBalancerName: "lb2", DNSName: "lb2.us-east-1.elb.amazonaws.com"},
+			{LoadBalancerName: "lb1", DNSName: "lb1-2.us-east-1.elb.amazonaws.com"},
+		},
+	}
+	byName := resp.LoadBalancersByName()
+	c.Assert(byName, HasLen, 2)
+	c.Assert(byName["lb1"].DNSName, Equals, "lb1-2.us-east-1.elb.amazonaws.com")
+	c.Assert(byName["lb2"], Equals, &resp.LoadBalancerDescriptions[1])
+	c.Assert(byName["lb3"], IsNil)
+	c.Assert(resp.LoadBalancersByName()["lb2"], Equals, byName["lb2"])
+}
+
+func (s *S) TestDescribeLoadBalancersByNameMapOfEmptyResponse(c *C) {
+	testServer.PrepareResponse(200, nil, DescribeLoadBalancersEmpty)
+	resp, err := s.elb.DescribeLoadBalancers()
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancersByName(), HasLen, 0)
+}
+
+func (s *S) TestDescribeLoadBalancersWithInstances(c *C) {
+	instances := `<Instances>
+                    <member><InstanceId>i-b44db8ca</InstanceId></member>
+                    <member><InstanceId>i-461ecf38</InstanceId></member>
+                </Instances>`
+	testServer.PrepareResponse(200, nil, strings.Replace(DescribeLoadBalancers, "<Instances/>", instances, 1))
+	resp, err := s.elb.DescribeLoadBalancers()
+	c.Assert(err, IsNil)
+	expected := []elb.Instance{{InstanceId: "i-b44db8ca"}, {InstanceId: "i-461ecf38"}}
+	c.Assert(resp.LoadBalancerDescriptions[0].Instances, DeepEquals, expected)
+}
+
+func (s *S) TestDescribeLoadBalancersWithSubnetsAndAvailabilityZones(c *C) {
+	subnets := `<Subnets>
+                    <member>subnet-1</member>
+                    <member>subnet-2</member>
+                </Subnets>
+                <VPCId>vpc-1</VPCId>`
+	body := strings.Replace(DescribeLoadBalancers, "<Subnets/>", subnets, 1)
+	body = strings.Replace(body, "<member>us-east-1a</member>", "<member>us-east-1a</member><member>us-east-1b</member>", 1)
+	testServer.PrepareResponse(200, nil, body)
+	resp, err := s.elb.DescribeLoadBalancers()
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].Subnets, DeepEquals, []string{"subnet-1", "subnet-2"})
+	c.Assert(resp.LoadBalancerDescriptions[0].AvailZones, DeepEquals, []string{"us-east-1a", "us-east-1b"})
+	c.Assert(resp.LoadBalancerDescriptions[0].VPCId, Equals, "vpc-1")
+}
+
+func (s *S) TestDescribeLoadBalancersWithoutLoadBalancers(c *C) {
+	testServer.PrepareResponse(200, nil, DescribeLoadBalancersEmpty)
+	resp, err := s.elb.DescribeLoadBalancers()
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions, NotNil)
+	c.Assert(resp.LoadBalancerDescriptions, HasLen, 0)
+}
+
 func (s *S) TestDescribeLoadBalancersByName(c *C) {
 	testServer.PrepareResponse(200, nil, DescribeLoadBalancers)
 	s.elb.DescribeLoadBalancers("somelb")
@@ -239,6 +469,45 @@ func (s *S) TestDescribeLoadBalancersByName(c *C) {
 	c.Assert(values.Get("LoadBalancerNames.member.1"), Equals, "somelb")
 }
 
+func (s *S) TestDescribeLoadBalancersIncludeSkipsUnrequestedSections(c *C) {
+	instances := `<Instances>
+                    <member><InstanceId>i-b44db8ca</InstanceId></member>
+                </Instances>`
+	testServer.PrepareResponse(200, nil, strings.Replace(DescribeLoadBalancers, "<Instances/>", instances, 1))
+	resp, err := s.elb.DescribeLoadBalancersInclude(elb.Include{Instances: true}, "testlb")
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	c.Assert(values.Get("Action"), Equals, "DescribeLoadBalancers")
+	c.Assert(values.Get("LoadBalancerNames.member.1"), Equals, "testlb")
+	c.Assert(resp.LoadBalancerDescriptions, HasLen, 1)
+	lb := resp.LoadBalancerDescriptions[0]
+	c.Assert(lb.LoadBalancerName, Equals, "testlb")
+	c.Assert(lb.DNSName, Equals, "testlb-2087227216.us-east-1.elb.amazonaws.com")
+	c.Assert(lb.AvailZones, DeepEquals, []string{"us-east-1a"})
+	c.Assert(lb.Instances, DeepEquals, []elb.Instance{{InstanceId: "i-b44db8ca"}})
+	c.Assert(lb.ListenerDescriptions, IsNil)
+	c.Assert(lb.HealthCheck, DeepEquals, elb.HealthCheck{})
+	c.Assert(lb.BackendServerDescriptions, IsNil)
+}
+
+func (s *S) TestDescribeLoadBalancersIncludeAllDecodesEverything(c *C) {
+	testServer.PrepareResponse(200, nil, DescribeLoadBalancers)
+	full, err := s.elb.DescribeLoadBalancers()
+	c.Assert(err, IsNil)
+	testServer.PrepareResponse(200, nil, DescribeLoadBalancers)
+	resp, err := s.elb.DescribeLoadBalancersInclude(elb.IncludeAll)
+	c.Assert(err, IsNil)
+	c.Assert(resp, DeepEquals, full)
+}
+
+func (s *S) TestDescribeLoadBalancersIncludeWithoutLoadBalancers(c *C) {
+	testServer.PrepareResponse(200, nil, DescribeLoadBalancersEmpty)
+	resp, err := s.elb.DescribeLoadBalancersInclude(elb.Include{})
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions, NotNil)
+	c.Assert(resp.LoadBalancerDescriptions, HasLen, 0)
+}
+
 func (s *S) TestDescribeLoadBalancersBadRequest(c *C) {
 	testServer.PrepareResponse(400, nil, DescribeLoadBalancersBadRequest)
 	resp, err := s.elb.DescribeLoadBalancers()
@@ -265,6 +534,15 @@ func (s *S) TestDescribeInstanceHealth(c *C) {
 	c.Assert(resp.InstanceStates[0].ReasonCode, Equals, "ELB")
 }
 
+func (s *S) TestDescribeInstanceHealthOfSeveralInstances(c *C) {
+	testServer.PrepareResponse(200, nil, DescribeInstanceHealth)
+	_, err := s.elb.DescribeInstanceHealth("testlb", "i-b44db8ca", "i-461ecf38")
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	c.Assert(values.Get("Instances.member.1.InstanceId"), Equals, "i-b44db8ca")
+	c.Assert(values.Get("Instances.member.2.InstanceId"), Equals, "i-461ecf38")
+}
+
 func (s *S) TestDescribeInstanceHealthBadRequest(c *C) {
 	testServer.PrepareResponse(400, nil, DescribeInstanceHealthBadRequest)
 	resp, err := s.elb.DescribeInstanceHealth("testlb", "i-foooo")
@@ -316,3 +594,625 @@ func (s *S) TestConfigureHealthCheckBadRequest(c *C) {
 	c.Assert(err, NotNil)
 	c.Assert(err, ErrorMatches, ".*foolb.*(LoadBalancerNotFound).*")
 }
+
+func (s *S) TestCreateLoadBalancerListeners(c *C) {
+	testServer.PrepareResponse(200, nil, CreateLoadBalancerListeners)
+	listeners := []elb.Listener{
+		{
+			InstancePort:     443,
+			InstanceProtocol: "HTTPS",
+			Protocol:         "HTTPS",
+			LoadBalancerPort: 443,
+			SSLCertificateId: "arn:aws:iam::123456789012:server-certificate/mycert",
+		},
+		{
+			InstancePort:     8080,
+			InstanceProtocol: "HTTP",
+			Protocol:         "HTTP",
+			LoadBalancerPort: 8080,
+		},
+	}
+	resp, err := s.elb.CreateLoadBalancerListeners("testlb", listeners)
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	c.Assert(values.Get("Version"), Equals, "2012-06-01")
+	c.Assert(values.Get("Signature"), Not(Equals), "")
+	c.Assert(values.Get("Timestamp"), Not(Equals), "")
+	c.Assert(values.Get("Action"), Equals, "CreateLoadBalancerListeners")
+	c.Assert(values.Get("LoadBalancerName"), Equals, "testlb")
+	c.Assert(values.Get("Listeners.member.1.InstancePort"), Equals, "443")
+	c.Assert(values.Get("Listeners.member.1.InstanceProtocol"), Equals, "HTTPS")
+	c.Assert(values.Get("Listeners.member.1.Protocol"), Equals, "HTTPS")
+	c.Assert(values.Get("Listeners.member.1.LoadBalancerPort"), Equals, "443")
+	c.Assert(values.Get("Listeners.member.1.SSLCertificateId"), Equals, "arn:aws:iam::123456789012:server-certificate/mycert")
+	c.Assert(values.Get("Listeners.member.2.InstancePort"), Equals, "8080")
+	c.Assert(values.Get("Listeners.member.2.LoadBalancerPort"), Equals, "8080")
+	c.Assert(values.Get("Listeners.member.2.SSLCertificateId"), Equals, "")
+	c.Assert(resp.RequestId, Equals, "1549581b-12b7-11e3-895e-1334aEXAMPLE")
+}
+
+func (s *S) TestDeleteLoadBalancerListeners(c *C) {
+	testServer.PrepareResponse(200, nil, DeleteLoadBalancerListeners)
+	resp, err := s.elb.DeleteLoadBalancerListeners("testlb", 80, 443)
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	c.Assert(values.Get("Version"), Equals, "2012-06-01")
+	c.Assert(values.Get("Signature"), Not(Equals), "")
+	c.Assert(values.Get("Timestamp"), Not(Equals), "")
+	c.Assert(values.Get("Action"), Equals, "DeleteLoadBalancerListeners")
+	c.Assert(values.Get("LoadBalancerName"), Equals, "testlb")
+	c.Assert(values.Get("LoadBalancerPorts.member.1"), Equals, "80")
+	c.Assert(values.Get("LoadBalancerPorts.member.2"), Equals, "443")
+	c.Assert(resp.RequestId, Equals, "83c88b9d-12b7-11e3-8b82-87b12EXAMPLE")
+}
+
+func (s *S) TestThrottlingWithRetryAfter(c *C) {
+	testServer.PrepareResponse(400, map[string]string{"Retry-After": "3"}, Throttling)
+	_, err := s.elb.DescribeLoadBalancers()
+	c.Assert(err, NotNil)
+	var throttled *elb.ThrottleError
+	c.Assert(errors.As(err, &throttled), Equals, true)
+	c.Assert(throttled.RetryAfter, Equals, 3*time.Second)
+	c.Assert(throttled.Err.StatusCode, Equals, 400)
+	c.Assert(throttled.Err.Code, Equals, "Throttling")
+	c.Assert(err, ErrorMatches, `^Rate exceeded \(Throttling\)$`)
+	var e *elb.Error
+	c.Assert(errors.As(err, &e), Equals, true)
+	c.Assert(e.Code, Equals, "Throttling")
+}
+
+func (s *S) TestThrottlingWithoutRetryAfter(c *C) {
+	testServer.PrepareResponse(400, nil, Throttling)
+	_, err := s.elb.DescribeLoadBalancers()
+	c.Assert(err, NotNil)
+	var throttled *elb.ThrottleError
+	c.Assert(errors.As(err, &throttled), Equals, true)
+	c.Assert(throttled.RetryAfter, Equals, time.Second)
+}
+
+func (s *S) TestErrorsOtherThanThrottlingAreNotThrottleErrors(c *C) {
+	testServer.PrepareResponse(400, nil, CreateLoadBalancerBadRequest)
+	_, err := s.elb.DescribeLoadBalancers()
+	var throttled *elb.ThrottleError
+	c.Assert(errors.As(err, &throttled), Equals, false)
+	_, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *S) TestThrottleErrorKeepsRequestId(c *C) {
+	testServer.PrepareResponse(400, nil, Throttling)
+	_, err := s.elb.DescribeLoadBalancers()
+	throttled, ok := err.(*elb.ThrottleError)
+	c.Assert(ok, Equals, true)
+	c.Assert(throttled.Err.RequestId, Equals, "4bd7a2f0-12b8-11e3-8c3d-a1b2cEXAMPLE")
+}
+
+func (s *S) TestModifyLoadBalancerAttributes(c *C) {
+	testServer.PrepareResponse(200, nil, ModifyLoadBalancerAttributes)
+	attrs := elb.LoadBalancerAttributes{
+		AccessLog: &elb.AccessLog{
+			Enabled:        true,
+			S3BucketName:   "my-loadbalancer-logs",
+			S3BucketPrefix: "my-app/prod",
+			EmitInterval:   60,
+		},
+		ConnectionSettings: &elb.ConnectionSettings{IdleTimeout: 120},
+	}
+	resp, err := s.elb.ModifyLoadBalancerAttributes("testlb", &attrs)
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	c.Assert(values.Get("Version"), Equals, "2012-06-01")
+	c.Assert(values.Get("Signature"), Not(Equals), "")
+	c.Assert(values.Get("Timestamp"), Not(Equals), "")
+	c.Assert(values.Get("Action"), Equals, "ModifyLoadBalancerAttributes")
+	c.Assert(values.Get("LoadBalancerName"), Equals, "testlb")
+	c.Assert(values.Get("LoadBalancerAttributes.AccessLog.Enabled"), Equals, "true")
+	c.Assert(values.Get("LoadBalancerAttributes.AccessLog.S3BucketName"), Equals, "my-loadbalancer-logs")
+	c.Assert(values.Get("LoadBalancerAttributes.AccessLog.S3BucketPrefix"), Equals, "my-app/prod")
+	c.Assert(values.Get("LoadBalancerAttributes.AccessLog.EmitInterval"), Equals, "60")
+	c.Assert(values.Get("LoadBalancerAttributes.ConnectionSettings.IdleTimeout"), Equals, "120")
+	_, ok := values["LoadBalancerAttributes.ConnectionDraining.Enabled"]
+	c.Assert(ok, Equals, false)
+	_, ok = values["LoadBalancerAttributes.CrossZoneLoadBalancing.Enabled"]
+	c.Assert(ok, Equals, false)
+	c.Assert(resp.LoadBalancerName, Equals, "testlb")
+	c.Assert(resp.LoadBalancerAttributes, DeepEquals, attrs)
+	c.Assert(resp.RequestId, Equals, "83c88b9d-12b7-11e3-8b82-87b12EXAMPLE")
+}
+
+func (s *S) TestLoadBalancerSetAttributes(c *C) {
+	testServer.PrepareResponse(200, nil, ModifyLoadBalancerAttributes)
+	lb := s.elb.LoadBalancer("testlb")
+	c.Assert(lb.Name, Equals, "testlb")
+	attrs := elb.LoadBalancerAttributes{
+		CrossZoneLoadBalancing: &elb.CrossZoneLoadBalancing{Enabled: false},
+	}
+	_, err := lb.SetAttributes(&attrs)
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	c.Assert(values.Get("Action"), Equals, "ModifyLoadBalancerAttributes")
+	c.Assert(values.Get("LoadBalancerName"), Equals, "testlb")
+	c.Assert(values.Get("LoadBalancerAttributes.CrossZoneLoadBalancing.Enabled"), Equals, "false")
+}
+
+func (s *S) TestDescribeLoadBalancerAttributes(c *C) {
+	testServer.PrepareResponse(200, nil, DescribeLoadBalancerAttributes)
+	resp, err := s.elb.DescribeLoadBalancerAttributes("testlb")
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	c.Assert(values.Get("Version"), Equals, "2012-06-01")
+	c.Assert(values.Get("Signature"), Not(Equals), "")
+	c.Assert(values.Get("Timestamp"), Not(Equals), "")
+	c.Assert(values.Get("Action"), Equals, "DescribeLoadBalancerAttributes")
+	c.Assert(values.Get("LoadBalancerName"), Equals, "testlb")
+	expected := elb.LoadBalancerAttributes{
+		AccessLog:              &elb.AccessLog{Enabled: false},
+		ConnectionDraining:     &elb.ConnectionDraining{Enabled: true, Timeout: 300},
+		ConnectionSettings:     &elb.ConnectionSettings{IdleTimeout: 60},
+		CrossZoneLoadBalancing: &elb.CrossZoneLoadBalancing{Enabled: true},
+	}
+	c.Assert(resp.LoadBalancerAttributes, DeepEquals, expected)
+	c.Assert(resp.RequestId, Equals, "83c88b9d-12b7-11e3-8b82-87b12EXAMPLE")
+}
+
+func (s *S) TestDeleteLoadBalancersIgnoresLoadBalancerNotFound(c *C) {
+	testServer.PrepareResponse(400, nil, ConfigureHealthCheckBadRequest)
+	err := s.elb.DeleteLoadBalancers([]string{"foolb"})
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	c.Assert(values.Get("Action"), Equals, "DeleteLoadBalancer")
+	c.Assert(values.Get("LoadBalancerName"), Equals, "foolb")
+}
+
+func (s *S) TestEnableAvailabilityZonesForLoadBalancer(c *C) {
+	testServer.PrepareResponse(200, nil, EnableAvailabilityZonesForLoadBalancer)
+	resp, err := s.elb.EnableAvailabilityZonesForLoadBalancer("testlb", []string{"us-east-1b"})
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	c.Assert(values.Get("Version"), Equals, "2012-06-01")
+	c.Assert(values.Get("Signature"), Not(Equals), "")
+	c.Assert(values.Get("Timestamp"), Not(Equals), "")
+	c.Assert(values.Get("Action"), Equals, "EnableAvailabilityZonesForLoadBalancer")
+	c.Assert(values.Get("LoadBalancerName"), Equals, "testlb")
+	c.Assert(values.Get("AvailabilityZones.member.1"), Equals, "us-east-1b")
+	c.Assert(resp.AvailabilityZones, DeepEquals, []string{"us-east-1a", "us-east-1b"})
+	c.Assert(resp.RequestId, Equals, "83c88b9d-12b7-11e3-8b82-87b12EXAMPLE")
+}
+
+func (s *S) TestDisableAvailabilityZonesForLoadBalancer(c *C) {
+	testServer.PrepareResponse(200, nil, DisableAvailabilityZonesForLoadBalancer)
+	resp, err := s.elb.DisableAvailabilityZonesForLoadBalancer("testlb", []string{"us-east-1a"})
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	c.Assert(values.Get("Version"), Equals, "2012-06-01")
+	c.Assert(values.Get("Signature"), Not(Equals), "")
+	c.Assert(values.Get("Timestamp"), Not(Equals), "")
+	c.Assert(values.Get("Action"), Equals, "DisableAvailabilityZonesForLoadBalancer")
+	c.Assert(values.Get("LoadBalancerName"), Equals, "testlb")
+	c.Assert(values.Get("AvailabilityZones.member.1"), Equals, "us-east-1a")
+	c.Assert(resp.AvailabilityZones, DeepEquals, []string{"us-east-1b"})
+	c.Assert(resp.RequestId, Equals, "ba6267d5-2566-11e3-9c6d-eb728EXAMPLE")
+}
+
+func (s *S) TestAttachLoadBalancerToSubnets(c *C) {
+	testServer.PrepareResponse(200, nil, AttachLoadBalancerToSubnets)
+	resp, err := s.elb.AttachLoadBalancerToSubnets("testlb", []string{"subnet-3561b05e"})
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	c.Assert(values.Get("Version"), Equals, "2012-06-01")
+	c.Assert(values.Get("Signature"), Not(Equals), "")
+	c.Assert(values.Get("Timestamp"), Not(Equals), "")
+	c.Assert(values.Get("Action"), Equals, "AttachLoadBalancerToSubnets")
+	c.Assert(values.Get("LoadBalancerName"), Equals, "testlb")
+	c.Assert(values.Get("Subnets.member.1"), Equals, "subnet-3561b05e")
+	c.Assert(resp.Subnets, DeepEquals, []string{"subnet-119f0078", "subnet-3561b05e"})
+	c.Assert(resp.RequestId, Equals, "07b1ecbc-1100-11e3-acaf-dd7edEXAMPLE")
+}
+
+func (s *S) TestDetachLoadBalancerFromSubnets(c *C) {
+	testServer.PrepareResponse(200, nil, DetachLoadBalancerFromSubnets)
+	resp, err := s.elb.DetachLoadBalancerFromSubnets("testlb", []string{"subnet-3561b05e", "subnet-119f0078"})
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	c.Assert(values.Get("Version"), Equals, "2012-06-01")
+	c.Assert(values.Get("Signature"), Not(Equals), "")
+	c.Assert(values.Get("Timestamp"), Not(Equals), "")
+	c.Assert(values.Get("Action"), Equals, "DetachLoadBalancerFromSubnets")
+	c.Assert(values.Get("LoadBalancerName"), Equals, "testlb")
+	c.Assert(values.Get("Subnets.member.1"), Equals, "subnet-3561b05e")
+	c.Assert(values.Get("Subnets.member.2"), Equals, "subnet-119f0078")
+	c.Assert(resp.Subnets, DeepEquals, []string{"subnet-159f007c"})
+	c.Assert(resp.RequestId, Equals, "07b1ecbc-1100-11e3-acaf-dd7edEXAMPLE")
+}
+
+func (s *S) TestApplySecurityGroupsToLoadBalancer(c *C) {
+	testServer.PrepareResponse(200, nil, ApplySecurityGroupsToLoadBalancer)
+	resp, err := s.elb.ApplySecurityGroupsToLoadBalancer("testlb", []string{"sg-fc448899"})
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	c.Assert(values.Get("Version"), Equals, "2012-06-01")
+	c.Assert(values.Get("Signature"), Not(Equals), "")
+	c.Assert(values.Get("Timestamp"), Not(Equals), "")
+	c.Assert(values.Get("Action"), Equals, "ApplySecurityGroupsToLoadBalancer")
+	c.Assert(values.Get("LoadBalancerName"), Equals, "testlb")
+	c.Assert(values.Get("SecurityGroups.member.1"), Equals, "sg-fc448899")
+	c.Assert(resp.SecurityGroups, DeepEquals, []string{"sg-fc448899"})
+	c.Assert(resp.RequestId, Equals, "06b5decc-102a-11e3-9ad6-bf3e4EXAMPLE")
+}
+
+func (s *S) TestDescribeLoadBalancersWithBackendServerDescriptions(c *C) {
+	testServer.PrepareResponse(200, nil, DescribeLoadBalancersWithBackendServerDescriptions)
+	resp, err := s.elb.DescribeLoadBalancers("testlb")
+	c.Assert(err, IsNil)
+	expected := []elb.BackendServerDescription{
+		{InstancePort: 80, PolicyNames: []string{"EnableProxyProtocol"}},
+		{InstancePort: 443, PolicyNames: []string{"EnableProxyProtocol", "BackendAuth"}},
+	}
+	c.Assert(resp.LoadBalancerDescriptions[0].BackendServerDescriptions, DeepEquals, expected)
+}
+
+func (s *S) TestDescribeLoadBalancersPage(c *C) {
+	testServer.PrepareResponse(200, nil, DescribeLoadBalancersWithNextMarker)
+	resp, err := s.elb.DescribeLoadBalancersPage("bWFya2Vy", 1)
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	c.Assert(values.Get("Action"), Equals, "DescribeLoadBalancers")
+	c.Assert(values.Get("Marker"), Equals, "bWFya2Vy")
+	c.Assert(values.Get("PageSize"), Equals, "1")
+	c.Assert(resp.LoadBalancerDescriptions, HasLen, 1)
+	c.Assert(resp.NextMarker, Equals, "cG9zaXRpb246MQ==")
+}
+
+func (s *S) TestDescribeLoadBalancersPageWithoutMarker(c *C) {
+	testServer.PrepareResponse(200, nil, DescribeLoadBalancersWithNextMarker)
+	_, err := s.elb.DescribeLoadBalancersPage("", 0)
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	_, ok := values["Marker"]
+	c.Assert(ok, Equals, false)
+	_, ok = values["PageSize"]
+	c.Assert(ok, Equals, false)
+}
+
+func (s *S) TestSetLoadBalancerPoliciesOfListener(c *C) {
+	testServer.PrepareResponse(200, nil, SetLoadBalancerPoliciesOfListener)
+	resp, err := s.elb.SetLoadBalancerPoliciesOfListener("testlb", 80, []string{"sticky", "other"})
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	c.Assert(values.Get("Version"), Equals, "2012-06-01")
+	c.Assert(values.Get("Signature"), Not(Equals), "")
+	c.Assert(values.Get("Timestamp"), Not(Equals), "")
+	c.Assert(values.Get("Action"), Equals, "SetLoadBalancerPoliciesOfListener")
+	c.Assert(values.Get("LoadBalancerName"), Equals, "testlb")
+	c.Assert(values.Get("LoadBalancerPort"), Equals, "80")
+	c.Assert(values.Get("PolicyNames.member.1"), Equals, "sticky")
+	c.Assert(values.Get("PolicyNames.member.2"), Equals, "other")
+	c.Assert(resp.RequestId, Equals, "83c88b9d-12b7-11e3-8b82-87b12EXAMPLE")
+}
+
+func (s *S) TestSetLoadBalancerPoliciesOfListenerWithoutPolicies(c *C) {
+	testServer.PrepareResponse(200, nil, SetLoadBalancerPoliciesOfListener)
+	_, err := s.elb.SetLoadBalancerPoliciesOfListener("testlb", 80, nil)
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	_, ok := values["PolicyNames"]
+	c.Assert(ok, Equals, true)
+	c.Assert(values.Get("PolicyNames"), Equals, "")
+}
+
+func (s *S) TestSetLoadBalancerListenerSSLCertificate(c *C) {
+	testServer.PrepareResponse(200, nil, SetLoadBalancerListenerSSLCertificate)
+	certId := "arn:aws:iam::123456789012:server-certificate/newcert"
+	resp, err := s.elb.SetLoadBalancerListenerSSLCertificate("testlb", 443, certId)
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	c.Assert(values.Get("Version"), Equals, "2012-06-01")
+	c.Assert(values.Get("Signature"), Not(Equals), "")
+	c.Assert(values.Get("Timestamp"), Not(Equals), "")
+	c.Assert(values.Get("Action"), Equals, "SetLoadBalancerListenerSSLCertificate")
+	c.Assert(values.Get("LoadBalancerName"), Equals, "testlb")
+	c.Assert(values.Get("LoadBalancerPort"), Equals, "443")
+	c.Assert(values.Get("SSLCertificateId"), Equals, certId)
+	c.Assert(resp.RequestId, Equals, "83c88b9d-12b7-11e3-8b82-87b12EXAMPLE")
+}
+
+func (s *S) TestCreateLBCookieStickinessPolicy(c *C) {
+	testServer.PrepareResponse(200, nil, CreateLBCookieStickinessPolicy)
+	resp, err := s.elb.CreateLBCookieStickinessPolicy("testlb", "MyLoadBalancerPolicy", 60)
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	c.Assert(values.Get("Version"), Equals, "2012-06-01")
+	c.Assert(values.Get("Signature"), Not(Equals), "")
+	c.Assert(values.Get("Timestamp"), Not(Equals), "")
+	c.Assert(values.Get("Action"), Equals, "CreateLBCookieStickinessPolicy")
+	c.Assert(values.Get("LoadBalancerName"), Equals, "testlb")
+	c.Assert(values.Get("PolicyName"), Equals, "MyLoadBalancerPolicy")
+	c.Assert(values.Get("CookieExpirationPeriod"), Equals, "60")
+	c.Assert(resp.RequestId, Equals, "99a693e9-12b8-11e3-9ad6-bf3e4EXAMPLE")
+}
+
+func (s *S) TestCreateLBCookieStickinessPolicyWithoutExpiration(c *C) {
+	testServer.PrepareResponse(200, nil, CreateLBCookieStickinessPolicy)
+	_, err := s.elb.CreateLBCookieStickinessPolicy("testlb", "MyLoadBalancerPolicy", 0)
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	_, ok := values["CookieExpirationPeriod"]
+	c.Assert(ok, Equals, false)
+}
+
+func (s *S) TestCreateAppCookieStickinessPolicy(c *C) {
+	testServer.PrepareResponse(200, nil, CreateAppCookieStickinessPolicy)
+	resp, err := s.elb.CreateAppCookieStickinessPolicy("testlb", "MyAppCookiePolicy", "MyAppCookie")
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	c.Assert(values.Get("Version"), Equals, "2012-06-01")
+	c.Assert(values.Get("Signature"), Not(Equals), "")
+	c.Assert(values.Get("Timestamp"), Not(Equals), "")
+	c.Assert(values.Get("Action"), Equals, "CreateAppCookieStickinessPolicy")
+	c.Assert(values.Get("LoadBalancerName"), Equals, "testlb")
+	c.Assert(values.Get("PolicyName"), Equals, "MyAppCookiePolicy")
+	c.Assert(values.Get("CookieName"), Equals, "MyAppCookie")
+	c.Assert(resp.RequestId, Equals, "99a693e9-12b8-11e3-9ad6-bf3e4EXAMPLE")
+}
+
+func (s *S) TestCreateLoadBalancerPolicy(c *C) {
+	testServer.PrepareResponse(200, nil, CreateLoadBalancerPolicy)
+	attrs := []elb.PolicyAttribute{
+		{AttributeName: "Reference-Security-Policy", AttributeValue: "ELBSecurityPolicy-2016-08"},
+		{AttributeName: "Protocol-TLSv1.2", AttributeValue: "true"},
+	}
+	resp, err := s.elb.CreateLoadBalancerPolicy("testlb", "MySSLNegotiationPolicy", "SSLNegotiationPolicyType", attrs)
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	c.Assert(values.Get("Version"), Equals, "2012-06-01")
+	c.Assert(values.Get("Signature"), Not(Equals), "")
+	c.Assert(values.Get("Timestamp"), Not(Equals), "")
+	c.Assert(values.Get("Action"), Equals, "CreateLoadBalancerPolicy")
+	c.Assert(values.Get("LoadBalancerName"), Equals, "testlb")
+	c.Assert(values.Get("PolicyName"), Equals, "MySSLNegotiationPolicy")
+	c.Assert(values.Get("PolicyTypeName"), Equals, "SSLNegotiationPolicyType")
+	c.Assert(values.Get("PolicyAttributes.member.1.AttributeName"), Equals, "Reference-Security-Policy")
+	c.Assert(values.Get("PolicyAttributes.member.1.AttributeValue"), Equals, "ELBSecurityPolicy-2016-08")
+	c.Assert(values.Get("PolicyAttributes.member.2.AttributeName"), Equals, "Protocol-TLSv1.2")
+	c.Assert(values.Get("PolicyAttributes.member.2.AttributeValue"), Equals, "true")
+	c.Assert(resp.RequestId, Equals, "83c88b9d-12b7-11e3-8b82-87b12EXAMPLE")
+}
+
+func (s *S) TestDeleteLoadBalancerPolicy(c *C) {
+	testServer.PrepareResponse(200, nil, DeleteLoadBalancerPolicy)
+	resp, err := s.elb.DeleteLoadBalancerPolicy("testlb", "MyPolicy")
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	c.Assert(values.Get("Version"), Equals, "2012-06-01")
+	c.Assert(values.Get("Signature"), Not(Equals), "")
+	c.Assert(values.Get("Timestamp"), Not(Equals), "")
+	c.Assert(values.Get("Action"), Equals, "DeleteLoadBalancerPolicy")
+	c.Assert(values.Get("LoadBalancerName"), Equals, "testlb")
+	c.Assert(values.Get("PolicyName"), Equals, "MyPolicy")
+	c.Assert(resp.RequestId, Equals, "83c88b9d-12b7-11e3-8b82-87b12EXAMPLE")
+}
+
+func (s *S) TestDescribeLoadBalancerPolicies(c *C) {
+	testServer.PrepareResponse(200, nil, DescribeLoadBalancerPolicies)
+	resp, err := s.elb.DescribeLoadBalancerPolicies("testlb", "MyDurationStickyPolicy", "EnableProxyProtocol")
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	c.Assert(values.Get("Version"), Equals, "2012-06-01")
+	c.Assert(values.Get("Signature"), Not(Equals), "")
+	c.Assert(values.Get("Timestamp"), Not(Equals), "")
+	c.Assert(values.Get("Action"), Equals, "DescribeLoadBalancerPolicies")
+	c.Assert(values.Get("LoadBalancerName"), Equals, "testlb")
+	c.Assert(values.Get("PolicyNames.member.1"), Equals, "MyDurationStickyPolicy")
+	c.Assert(values.Get("PolicyNames.member.2"), Equals, "EnableProxyProtocol")
+	expected := []elb.PolicyDescription{
+		{
+			PolicyName:     "MyDurationStickyPolicy",
+			PolicyTypeName: "LBCookieStickinessPolicyType",
+			PolicyAttributeDescriptions: []elb.PolicyAttribute{
+				{AttributeName: "CookieExpirationPeriod", AttributeValue: "60"},
+			},
+		},
+		{
+			PolicyName:     "EnableProxyProtocol",
+			PolicyTypeName: "ProxyProtocolPolicyType",
+			PolicyAttributeDescriptions: []elb.PolicyAttribute{
+				{AttributeName: "ProxyProtocol", AttributeValue: "true"},
+			},
+		},
+	}
+	c.Assert(resp.PolicyDescriptions, DeepEquals, expected)
+	c.Assert(resp.RequestId, Equals, "83c88b9d-12b7-11e3-8b82-87b12EXAMPLE")
+}
+
+func (s *S) TestDescribeLoadBalancerPoliciesWithoutLoadBalancer(c *C) {
+	testServer.PrepareResponse(200, nil, DescribeLoadBalancerPoliciesEmpty)
+	resp, err := s.elb.DescribeLoadBalancerPolicies("")
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	_, ok := values["LoadBalancerName"]
+	c.Assert(ok, Equals, false)
+	c.Assert(resp.PolicyDescriptions, NotNil)
+	c.Assert(resp.PolicyDescriptions, HasLen, 0)
+}
+
+func (s *S) TestDescribeLoadBalancerPolicyTypes(c *C) {
+	testServer.PrepareResponse(200, nil, DescribeLoadBalancerPolicyTypes)
+	resp, err := s.elb.DescribeLoadBalancerPolicyTypes("ProxyProtocolPolicyType")
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	c.Assert(values.Get("Version"), Equals, "2012-06-01")
+	c.Assert(values.Get("Signature"), Not(Equals), "")
+	c.Assert(values.Get("Timestamp"), Not(Equals), "")
+	c.Assert(values.Get("Action"), Equals, "DescribeLoadBalancerPolicyTypes")
+	c.Assert(values.Get("PolicyTypeNames.member.1"), Equals, "ProxyProtocolPolicyType")
+	c.Assert(resp.PolicyTypeDescriptions, HasLen, 1)
+	t := resp.PolicyTypeDescriptions[0]
+	c.Assert(t.PolicyTypeName, Equals, "ProxyProtocolPolicyType")
+	c.Assert(t.Description, Matches, "Policy that controls whether to include the IP address .*")
+	expected := []elb.PolicyAttributeTypeDescription{
+		{AttributeName: "ProxyProtocol", AttributeType: "Boolean", Cardinality: "ONE"},
+	}
+	c.Assert(t.PolicyAttributeTypeDescriptions, DeepEquals, expected)
+	c.Assert(resp.RequestId, Equals, "83c88b9d-12b7-11e3-8b82-87b12EXAMPLE")
+}
+
+func (s *S) TestAddTags(c *C) {
+	testServer.PrepareResponse(200, nil, AddTags)
+	tags := []elb.Tag{{Key: "project", Value: "lima"}, {Key: "department", Value: "digital-media"}}
+	resp, err := s.elb.AddTags([]string{"testlb"}, tags)
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	c.Assert(values.Get("Version"), Equals, "2012-06-01")
+	c.Assert(values.Get("Signature"), Not(Equals), "")
+	c.Assert(values.Get("Timestamp"), Not(Equals), "")
+	c.Assert(values.Get("Action"), Equals, "AddTags")
+	c.Assert(values.Get("LoadBalancerNames.member.1"), Equals, "testlb")
+	c.Assert(values.Get("Tags.member.1.Key"), Equals, "project")
+	c.Assert(values.Get("Tags.member.1.Value"), Equals, "lima")
+	c.Assert(values.Get("Tags.member.2.Key"), Equals, "department")
+	c.Assert(values.Get("Tags.member.2.Value"), Equals, "digital-media")
+	c.Assert(resp.RequestId, Equals, "360e81f7-1100-11e4-b6ed-0f30EXAMPLE")
+}
+
+func (s *S) TestRemoveTags(c *C) {
+	testServer.PrepareResponse(200, nil, RemoveTags)
+	resp, err := s.elb.RemoveTags([]string{"testlb", "otherlb"}, []string{"project"})
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	c.Assert(values.Get("Version"), Equals, "2012-06-01")
+	c.Assert(values.Get("Signature"), Not(Equals), "")
+	c.Assert(values.Get("Timestamp"), Not(Equals), "")
+	c.Assert(values.Get("Action"), Equals, "RemoveTags")
+	c.Assert(values.Get("LoadBalancerNames.member.1"), Equals, "testlb")
+	c.Assert(values.Get("LoadBalancerNames.member.2"), Equals, "otherlb")
+	c.Assert(values.Get("Tags.member.1.Key"), Equals, "project")
+	_, ok := values["Tags.member.1.Value"]
+	c.Assert(ok, Equals, false)
+	c.Assert(resp.RequestId, Equals, "83c88b9d-12b7-11e3-8b82-87b12EXAMPLE")
+}
+
+func (s *S) TestDescribeTags(c *C) {
+	testServer.PrepareResponse(200, nil, DescribeTags)
+	resp, err := s.elb.DescribeTags("testlb", "otherlb")
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	c.Assert(values.Get("Version"), Equals, "2012-06-01")
+	c.Assert(values.Get("Signature"), Not(Equals), "")
+	c.Assert(values.Get("Timestamp"), Not(Equals), "")
+	c.Assert(values.Get("Action"), Equals, "DescribeTags")
+	c.Assert(values.Get("LoadBalancerNames.member.1"), Equals, "testlb")
+	c.Assert(values.Get("LoadBalancerNames.member.2"), Equals, "otherlb")
+	expected := []elb.TagDescription{
+		{
+			LoadBalancerName: "testlb",
+			Tags:             []elb.Tag{{Key: "project", Value: "lima"}, {Key: "department", Value: "digital-media"}},
+		},
+		{LoadBalancerName: "otherlb"},
+	}
+	c.Assert(resp.TagDescriptions, DeepEquals, expected)
+	c.Assert(resp.RequestId, Equals, "07b1ecbc-1100-11e3-acaf-dd7edEXAMPLE")
+}
+
+func (s *S) TestDescribeAccountLimits(c *C) {
+	testServer.PrepareResponse(200, nil, DescribeAccountLimits)
+	resp, err := s.elb.DescribeAccountLimits()
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	c.Assert(values.Get("Version"), Equals, "2012-06-01")
+	c.Assert(values.Get("Signature"), Not(Equals), "")
+	c.Assert(values.Get("Timestamp"), Not(Equals), "")
+	c.Assert(values.Get("Action"), Equals, "DescribeAccountLimits")
+	expected := []elb.Limit{
+		{Name: "classic-load-balancers", Max: "20"},
+		{Name: "classic-listeners", Max: "100"},
+		{Name: "classic-registered-instances", Max: "1000"},
+	}
+	c.Assert(resp.Limits, DeepEquals, expected)
+	c.Assert(resp.RequestId, Equals, "83c88b9d-12b7-11e3-8b82-87b12EXAMPLE")
+}
+
+func (s *S) TestSetLoadBalancerPoliciesForBackendServer(c *C) {
+	testServer.PrepareResponse(200, nil, SetLoadBalancerPoliciesForBackendServer)
+	resp, err := s.elb.SetLoadBalancerPoliciesForBackendServer("testlb", 80, []string{"EnableProxyProtocol"})
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	c.Assert(values.Get("Version"), Equals, "2012-06-01")
+	c.Assert(values.Get("Signature"), Not(Equals), "")
+	c.Assert(values.Get("Timestamp"), Not(Equals), "")
+	c.Assert(values.Get("Action"), Equals, "SetLoadBalancerPoliciesForBackendServer")
+	c.Assert(values.Get("LoadBalancerName"), Equals, "testlb")
+	c.Assert(values.Get("InstancePort"), Equals, "80")
+	c.Assert(values.Get("PolicyNames.member.1"), Equals, "EnableProxyProtocol")
+	c.Assert(resp.RequestId, Equals, "0eb9b381-dde0-11e2-8d78-6ddbaEXAMPLE")
+}
+
+func (s *S) TestSetLoadBalancerPoliciesForBackendServerWithoutPolicies(c *C) {
+	testServer.PrepareResponse(200, nil, SetLoadBalancerPoliciesForBackendServer)
+	_, err := s.elb.SetLoadBalancerPoliciesForBackendServer("testlb", 80, nil)
+	c.Assert(err, IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	_, ok := values["PolicyNames"]
+	c.Assert(ok, Equals, true)
+	c.Assert(values.Get("PolicyNames"), Equals, "")
+}
+
+func (s *S) TestDescribeLoadBalancersIgnoresUnknownElements(c *C) {
+	testServer.PrepareResponse(200, nil, DescribeLoadBalancersWithFutureFields)
+	resp, err := s.elb.DescribeLoadBalancers("testlb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions, HasLen, 1)
+	lb := resp.LoadBalancerDescriptions[0]
+	c.Assert(lb.LoadBalancerName, Equals, "testlb")
+	c.Assert(lb.DNSName, Equals, "testlb-2087227216.us-east-1.elb.amazonaws.com")
+	c.Assert(lb.HealthCheck, DeepEquals, elb.HealthCheck{
+		HealthyThreshold:   10,
+		Interval:           30,
+		Target:             "TCP:80",
+		Timeout:            5,
+		UnhealthyThreshold: 2,
+	})
+	c.Assert(lb.ListenerDescriptions, HasLen, 1)
+	c.Assert(lb.ListenerDescriptions[0].Listener, DeepEquals, elb.Listener{
+		InstancePort:     80,
+		InstanceProtocol: "HTTP",
+		LoadBalancerPort: 80,
+		Protocol:         "HTTP",
+	})
+	c.Assert(lb.Instances, DeepEquals, []elb.Instance{{InstanceId: "i-b44db8ca"}})
+	c.Assert(lb.AvailZones, HasLen, 0)
+}
+
+func (s *S) TestErrorIgnoresUnknownElements(c *C) {
+	testServer.PrepareResponse(400, nil, ThrottlingWithFutureFields)
+	_, err := s.elb.DescribeLoadBalancers()
+	throttled, ok := err.(*elb.ThrottleError)
+	c.Assert(ok, Equals, true)
+	c.Assert(throttled.Err.Code, Equals, "Throttling")
+	c.Assert(throttled.Err.Message, Equals, "Rate exceeded")
+	c.Assert(throttled.Err.RequestId, Equals, "4bd7a2f0-12b8-11e3-8c3d-a1b2cEXAMPLE")
+}
+
+func (s *S) TestLastRawResponseIsDisabledByDefault(c *C) {
+	testServer.PrepareResponse(200, nil, DescribeLoadBalancers)
+	_, err := s.elb.DescribeLoadBalancers()
+	c.Assert(err, IsNil)
+	c.Assert(s.elb.LastRawResponse(), IsNil)
+}
+
+func (s *S) TestLastRawResponse(c *C) {
+	s.elb.CaptureRawResponses(true)
+	defer s.elb.CaptureRawResponses(false)
+	testServer.PrepareResponse(200, nil, DescribeLoadBalancers)
+	resp, err := s.elb.DescribeLoadBalancers()
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].LoadBalancerName, Equals, "testlb")
+	c.Assert(string(s.elb.LastRawResponse()), Equals, DescribeLoadBalancers)
+	testServer.PrepareResponse(400, nil, CreateLoadBalancerBadRequest)
+	_, err = s.elb.DescribeLoadBalancers()
+	e, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.Code, Equals, "ValidationError")
+	c.Assert(string(s.elb.LastRawResponse()), Equals, CreateLoadBalancerBadRequest)
+	s.elb.CaptureRawResponses(false)
+	c.Assert(s.elb.LastRawResponse(), IsNil)
+}
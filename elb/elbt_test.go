@@ -1,10 +1,24 @@
 package elb_test
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/xml"
+	"fmt"
 	"github.com/flaviamissi/go-elb/aws"
 	"github.com/flaviamissi/go-elb/elb"
 	"github.com/flaviamissi/go-elb/elb/elbtest"
+	"github.com/flaviamissi/go-elb/elb/elbtest/assert"
+	"io/ioutil"
 	. "launchpad.net/gocheck"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 )
 
 // LocalServer represents a local elbtest fake server.
@@ -62,8 +76,8 @@ var _ = Suite(&LocalServerSuite{})
 
 func (s *LocalServerSuite) SetUpSuite(c *C) {
 	s.srv.SetUp(c)
-	s.ServerTests.elb = elb.New(s.srv.auth, s.srv.region)
-	s.clientTests.elb = elb.New(s.srv.auth, s.srv.region)
+	s.ServerTests.elb = elb.NewForTesting(s.srv.srv.URL())
+	s.clientTests.elb = elb.NewForTesting(s.srv.srv.URL())
 }
 
 func (s *LocalServerSuite) TestCreateLoadBalancer(c *C) {
@@ -146,6 +160,124 @@ func (s *LocalServerSuite) TestRegisterInstanceWithLoadBalancerWithAbsentLoadBal
 	c.Assert(resp, IsNil)
 }
 
+func (s *LocalServerSuite) TestRegisterInstanceRespondsWithAllRegisteredInstances(c *C) {
+	srv := s.srv.srv
+	inst1 := srv.NewInstance()
+	defer srv.RemoveInstance(inst1)
+	inst2 := srv.NewInstance()
+	defer srv.RemoveInstance(inst2)
+	srv.NewLoadBalancer("testlb")
+	defer srv.RemoveLoadBalancer("testlb")
+	_, err := s.clientTests.elb.RegisterInstancesWithLoadBalancer([]string{inst1}, "testlb")
+	c.Assert(err, IsNil)
+	resp, err := s.clientTests.elb.RegisterInstancesWithLoadBalancer([]string{inst2}, "testlb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.InstanceIds, DeepEquals, []string{inst1, inst2})
+}
+
+func (s *LocalServerSuite) TestRegistrationsAreTrackedPerLoadBalancer(c *C) {
+	srv := s.srv.srv
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("firstlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("firstlb")
+	_, err = s.clientTests.elb.CreateLoadBalancer(createLBRequest("secondlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("secondlb")
+	inst1, inst2 := srv.NewInstance(), srv.NewInstance()
+	defer srv.RemoveInstance(inst1)
+	defer srv.RemoveInstance(inst2)
+	_, err = s.clientTests.elb.RegisterInstancesWithLoadBalancer([]string{inst1, inst2}, "firstlb")
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.RegisterInstancesWithLoadBalancer([]string{inst2}, "secondlb")
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.DeregisterInstancesFromLoadBalancer([]string{inst2}, "firstlb")
+	c.Assert(err, IsNil)
+	members := func(lbName string) ([]elb.Instance, []string) {
+		resp, err := s.clientTests.elb.DescribeLoadBalancers(lbName)
+		c.Assert(err, IsNil)
+		health, err := s.clientTests.elb.DescribeInstanceHealth(lbName)
+		c.Assert(err, IsNil)
+		var ids []string
+		for _, state := range health.InstanceStates {
+			ids = append(ids, state.InstanceId)
+		}
+		return resp.LoadBalancerDescriptions[0].Instances, ids
+	}
+	instances, ids := members("firstlb")
+	c.Assert(instances, DeepEquals, []elb.Instance{{InstanceId: inst1}})
+	c.Assert(ids, DeepEquals, []string{inst1})
+	instances, ids = members("secondlb")
+	c.Assert(instances, DeepEquals, []elb.Instance{{InstanceId: inst2}})
+	c.Assert(ids, DeepEquals, []string{inst2})
+	// deregistering an instance that is not registered changes nothing
+	_, err = s.clientTests.elb.DeregisterInstancesFromLoadBalancer([]string{inst2}, "firstlb")
+	c.Assert(err, IsNil)
+	instances, ids = members("firstlb")
+	c.Assert(instances, DeepEquals, []elb.Instance{{InstanceId: inst1}})
+	c.Assert(ids, DeepEquals, []string{inst1})
+}
+
+func (s *LocalServerSuite) TestRegisterInstancesWithResult(c *C) {
+	srv := s.srv.srv
+	inst1 := srv.NewInstance()
+	defer srv.RemoveInstance(inst1)
+	inst2 := srv.NewInstance()
+	defer srv.RemoveInstance(inst2)
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("resultlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("resultlb")
+	result, err := s.clientTests.elb.RegisterInstancesWithResult("resultlb", inst1)
+	c.Assert(err, IsNil)
+	c.Assert(result.Added, DeepEquals, []string{inst1})
+	c.Assert(result.AlreadyRegistered, DeepEquals, []string{})
+	result, err = s.clientTests.elb.RegisterInstancesWithResult("resultlb", inst1, inst2)
+	c.Assert(err, IsNil)
+	c.Assert(result.Added, DeepEquals, []string{inst2})
+	c.Assert(result.AlreadyRegistered, DeepEquals, []string{inst1})
+	c.Assert(result.InstanceIds, DeepEquals, []string{inst1, inst2})
+}
+
+func (s *LocalServerSuite) TestRegisterInstancesWithResultWithDuplicatedInstance(c *C) {
+	srv := s.srv.srv
+	inst1 := srv.NewInstance()
+	defer srv.RemoveInstance(inst1)
+	inst2 := srv.NewInstance()
+	defer srv.RemoveInstance(inst2)
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("resultlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("resultlb")
+	_, err = s.clientTests.elb.RegisterInstancesWithResult("resultlb", inst1)
+	c.Assert(err, IsNil)
+	result, err := s.clientTests.elb.RegisterInstancesWithResult("resultlb", inst2, inst1, inst2, inst1)
+	c.Assert(err, IsNil)
+	c.Assert(result.Added, DeepEquals, []string{inst2})
+	c.Assert(result.AlreadyRegistered, DeepEquals, []string{inst1})
+	c.Assert(result.InstanceIds, DeepEquals, []string{inst1, inst2})
+}
+
+func (s *LocalServerSuite) TestRegisterInstancesWithResultReRegistering(c *C) {
+	srv := s.srv.srv
+	instId := srv.NewInstance()
+	defer srv.RemoveInstance(instId)
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("resultlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("resultlb")
+	_, err = s.clientTests.elb.RegisterInstancesWithResult("resultlb", instId)
+	c.Assert(err, IsNil)
+	result, err := s.clientTests.elb.RegisterInstancesWithResult("resultlb", instId)
+	c.Assert(err, IsNil)
+	c.Assert(result.Added, HasLen, 0)
+	c.Assert(result.AlreadyRegistered, DeepEquals, []string{instId})
+}
+
+func (s *LocalServerSuite) TestRegisterInstancesWithResultWithAbsentLoadBalancer(c *C) {
+	_, err := s.clientTests.elb.RegisterInstancesWithResult("absentlb", "i-212")
+	c.Assert(err, NotNil)
+	e, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.Code, Equals, "LoadBalancerNotFound")
+}
+
 func (s *LocalServerSuite) TestDeregisterInstanceWithLoadBalancer(c *C) {
 	// there is no need to register the instance first, amazon returns the same response
 	// in both cases (instance registered or not)
@@ -191,6 +323,28 @@ func (s *LocalServerSuite) TestDescribeInstanceHealth(c *C) {
 	c.Assert(resp.InstanceStates[0].ReasonCode, Equals, "Instance")
 }
 
+func (s *LocalServerSuite) TestDescribeInstanceHealthOfSpecificInstances(c *C) {
+	srv := s.srv.srv
+	inst1, inst2, inst3 := srv.NewInstance(), srv.NewInstance(), srv.NewInstance()
+	defer srv.RemoveInstance(inst1)
+	defer srv.RemoveInstance(inst2)
+	defer srv.RemoveInstance(inst3)
+	srv.NewLoadBalancer("testlb")
+	defer srv.RemoveLoadBalancer("testlb")
+	srv.RegisterInstance(inst1, "testlb")
+	srv.RegisterInstance(inst2, "testlb")
+	err := srv.SetInstanceHealth("testlb", inst2, "InService")
+	c.Assert(err, IsNil)
+	resp, err := s.clientTests.elb.DescribeInstanceHealth("testlb", inst2, inst3)
+	c.Assert(err, IsNil)
+	c.Assert(resp.InstanceStates, HasLen, 2)
+	c.Assert(resp.InstanceStates[0].InstanceId, Equals, inst2)
+	c.Assert(resp.InstanceStates[0].State, Equals, "InService")
+	c.Assert(resp.InstanceStates[1].InstanceId, Equals, inst3)
+	c.Assert(resp.InstanceStates[1].State, Equals, "OutOfService")
+	c.Assert(resp.InstanceStates[1].ReasonCode, Equals, "Instance")
+}
+
 func (s *LocalServerSuite) TestDescribeInstanceHealthBadRequest(c *C) {
 	s.clientTests.TestDescribeInstanceHealthBadRequest(c)
 }
@@ -241,3 +395,3900 @@ func (s *LocalServerSuite) TestConfigureHealthCheck(c *C) {
 func (s *LocalServerSuite) TestConfigureHealthCheckBadRequest(c *C) {
 	s.clientTests.TestConfigureHealthCheckBadRequest(c)
 }
+
+func createLBRequest(name string) *elb.CreateLoadBalancer {
+	return &elb.CreateLoadBalancer{
+		Name:       name,
+		AvailZones: []string{"us-east-1a"},
+		Listeners: []elb.Listener{
+			{
+				InstancePort:     80,
+				InstanceProtocol: "http",
+				LoadBalancerPort: 80,
+				Protocol:         "http",
+			},
+		},
+	}
+}
+
+func (s *LocalServerSuite) TestCreateLoadBalancerWithNameAtMaximumLength(c *C) {
+	name := "a1234567890123456789012345678901"
+	c.Assert(name, HasLen, 32)
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest(name))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer(name)
+}
+
+func (s *LocalServerSuite) TestCreateLoadBalancerWithTooLongName(c *C) {
+	name := "a12345678901234567890123456789012"
+	c.Assert(name, HasLen, 33)
+	resp, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest(name))
+	c.Assert(resp, IsNil)
+	e, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.Code, Equals, "ValidationError")
+	c.Assert(e.Message, Equals, "1 validation error detected: Value '"+name+"' at 'loadBalancerName' failed to satisfy constraint: Member must have length less than or equal to 32")
+}
+
+func (s *LocalServerSuite) TestCreateLoadBalancerWithTooLongNameIsRejectedByServer(c *C) {
+	name := "a12345678901234567890123456789012"
+	params := url.Values{
+		"Action":                              {"CreateLoadBalancer"},
+		"LoadBalancerName":                    {name},
+		"AvailabilityZones.member.1":          {"us-east-1a"},
+		"Listeners.member.1.InstancePort":     {"80"},
+		"Listeners.member.1.InstanceProtocol": {"http"},
+		"Listeners.member.1.Protocol":         {"http"},
+		"Listeners.member.1.LoadBalancerPort": {"80"},
+	}
+	r, err := http.Get(s.srv.srv.URL() + "/?" + params.Encode())
+	c.Assert(err, IsNil)
+	defer r.Body.Close()
+	c.Assert(r.StatusCode, Equals, 400)
+	body, err := ioutil.ReadAll(r.Body)
+	c.Assert(err, IsNil)
+	c.Assert(string(body), Matches, ".*<Code>ValidationError</Code>.*at &#39;loadBalancerName&#39; failed to satisfy constraint: Member must have length less than or equal to 32.*")
+}
+
+func (s *LocalServerSuite) TestCreateLoadBalancerWithInvalidNames(c *C) {
+	for _, name := range []string{"test_lb", "test.lb", "-testlb", "testlb-", "-"} {
+		resp, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest(name))
+		c.Check(resp, IsNil)
+		e, ok := err.(*elb.Error)
+		c.Assert(ok, Equals, true)
+		c.Check(e.Code, Equals, "ValidationError")
+		c.Check(e.Message, Equals, "LoadBalancerName must contain only alphanumeric characters or hyphens, and cannot begin or end with hyphen")
+	}
+}
+
+func (s *LocalServerSuite) TestCreateLoadBalancerWithHyphensInsideName(c *C) {
+	for _, name := range []string{"test-lb", "a-b-c", "a--b", "1"} {
+		_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest(name))
+		c.Check(err, IsNil)
+		s.clientTests.elb.DeleteLoadBalancer(name)
+	}
+}
+
+func (s *LocalServerSuite) TestCreateLoadBalancerWithInvalidNameIsRejectedByServer(c *C) {
+	// the client validates names before sending the request, so this test
+	// talks to the fake server directly.
+	params := url.Values{
+		"Action":                              {"CreateLoadBalancer"},
+		"LoadBalancerName":                    {"testlb-"},
+		"AvailabilityZones.member.1":          {"us-east-1a"},
+		"Listeners.member.1.InstancePort":     {"80"},
+		"Listeners.member.1.InstanceProtocol": {"http"},
+		"Listeners.member.1.Protocol":         {"http"},
+		"Listeners.member.1.LoadBalancerPort": {"80"},
+	}
+	r, err := http.Get(s.srv.srv.URL() + "/?" + params.Encode())
+	c.Assert(err, IsNil)
+	defer r.Body.Close()
+	c.Assert(r.StatusCode, Equals, 400)
+	body, err := ioutil.ReadAll(r.Body)
+	c.Assert(err, IsNil)
+	c.Assert(string(body), Matches, ".*<Code>ValidationError</Code>.*cannot begin or end with hyphen.*")
+}
+
+func (s *LocalServerSuite) TestStrictModeValidatesParameters(c *C) {
+	s.srv.srv.SetStrict(true)
+	defer s.srv.srv.SetStrict(false)
+	var tests = []struct {
+		params  url.Values
+		message string
+	}{
+		{
+			url.Values{"Action": {"DeleteLoadBalancer"}, "LoadBalancerName": {"testlb"}, "LoadBalancer": {"testlb"}},
+			"LoadBalancer is not a valid parameter of DeleteLoadBalancer",
+		},
+		{
+			url.Values{"Action": {"DescribeLoadBalancers"}, "LoadBalancerNames.member.0": {"testlb"}},
+			"LoadBalancerNames.member.0 is not a valid parameter of DescribeLoadBalancers",
+		},
+		{
+			url.Values{"Action": {"DescribeLoadBalancers"}, "LoadBalancerNames.member.1": {"testlb"}, "LoadBalancerNames.member.3": {"otherlb"}},
+			"LoadBalancerNames.member.2 is missing, the members of a list must be numbered from 1 with no gaps",
+		},
+		{
+			url.Values{"Action": {"DescribeLoadBalancers"}, "PageSize": {"ten"}},
+			"Invalid value 'ten' for PageSize, it must be an integer",
+		},
+		{
+			url.Values{"Action": {"ModifyLoadBalancerAttributes"}, "LoadBalancerName": {"testlb"}, "LoadBalancerAttributes.CrossZoneLoadBalancing.Enabled": {"yes"}},
+			"Invalid value 'yes' for LoadBalancerAttributes.CrossZoneLoadBalancing.Enabled, it must be true or false",
+		},
+	}
+	for _, t := range tests {
+		r, err := http.Get(s.srv.srv.URL() + "/?" + t.params.Encode())
+		c.Assert(err, IsNil)
+		var resp struct {
+			Code    string `xml:"Error>Code"`
+			Message string `xml:"Error>Message"`
+		}
+		err = xml.NewDecoder(r.Body).Decode(&resp)
+		r.Body.Close()
+		c.Assert(err, IsNil)
+		c.Check(r.StatusCode, Equals, 400)
+		c.Check(resp.Code, Equals, "ValidationError")
+		c.Check(resp.Message, Equals, t.message)
+	}
+}
+
+func (s *LocalServerSuite) TestStrictModeAcceptsClientRequests(c *C) {
+	s.srv.srv.SetStrict(true)
+	defer s.srv.srv.SetStrict(false)
+	createLB := createLBRequest("strictlb")
+	createLB.AvailZones = []string{"us-east-1a", "us-east-1b"}
+	createLB.Listeners = append(createLB.Listeners, elb.Listener{
+		InstancePort:     8080,
+		InstanceProtocol: "TCP",
+		LoadBalancerPort: 8080,
+		Protocol:         "TCP",
+	})
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("strictlb")
+	_, err = s.clientTests.elb.ModifyLoadBalancerAttributes("strictlb", &elb.LoadBalancerAttributes{
+		ConnectionDraining:     &elb.ConnectionDraining{Enabled: true, Timeout: 60},
+		CrossZoneLoadBalancing: &elb.CrossZoneLoadBalancing{Enabled: true},
+	})
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.DeleteLoadBalancerListeners("strictlb", 8080)
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.DescribeLoadBalancersPage("", 10, "strictlb")
+	c.Assert(err, IsNil)
+}
+
+func (s *LocalServerSuite) TestSetInstances(c *C) {
+	srv := s.srv.srv
+	srv.NewLoadBalancer("testlb")
+	defer srv.RemoveLoadBalancer("testlb")
+	inst1, inst2, inst3 := srv.NewInstance(), srv.NewInstance(), srv.NewInstance()
+	defer srv.RemoveInstance(inst1)
+	defer srv.RemoveInstance(inst2)
+	defer srv.RemoveInstance(inst3)
+	srv.RegisterInstance(inst1, "testlb")
+	srv.RegisterInstance(inst2, "testlb")
+	final, err := s.clientTests.elb.SetInstances("testlb", []string{inst2, inst3})
+	c.Assert(err, IsNil)
+	c.Assert(final, DeepEquals, []string{inst2, inst3})
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("testlb")
+	c.Assert(err, IsNil)
+	expected := []elb.Instance{{InstanceId: inst2}, {InstanceId: inst3}}
+	c.Assert(resp.LoadBalancerDescriptions[0].Instances, DeepEquals, expected)
+	// converging again is a no-op
+	final, err = s.clientTests.elb.SetInstances("testlb", []string{inst2, inst3})
+	c.Assert(err, IsNil)
+	c.Assert(final, DeepEquals, []string{inst2, inst3})
+	resp, err = s.clientTests.elb.DescribeLoadBalancers("testlb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].Instances, DeepEquals, expected)
+}
+
+func (s *LocalServerSuite) TestSetInstancesToEmptyList(c *C) {
+	srv := s.srv.srv
+	srv.NewLoadBalancer("testlb")
+	defer srv.RemoveLoadBalancer("testlb")
+	instId := srv.NewInstance()
+	defer srv.RemoveInstance(instId)
+	srv.RegisterInstance(instId, "testlb")
+	final, err := s.clientTests.elb.SetInstances("testlb", nil)
+	c.Assert(err, IsNil)
+	c.Assert(final, HasLen, 0)
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("testlb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].Instances, HasLen, 0)
+}
+
+func (s *LocalServerSuite) TestSetInstancesWithAbsentLoadBalancer(c *C) {
+	final, err := s.clientTests.elb.SetInstances("absentlb", []string{"i-212"})
+	c.Assert(final, IsNil)
+	c.Assert(err, ErrorMatches, ".*(LoadBalancerNotFound).*")
+}
+
+func (s *LocalServerSuite) TestInstanceHealthForAll(c *C) {
+	srv := s.srv.srv
+	var lbNames []string
+	for i := 0; i < 15; i++ {
+		name := fmt.Sprintf("testlb%d", i)
+		srv.NewLoadBalancer(name)
+		defer srv.RemoveLoadBalancer(name)
+		instId := srv.NewInstance()
+		defer srv.RemoveInstance(instId)
+		srv.RegisterInstance(instId, name)
+		lbNames = append(lbNames, name)
+	}
+	states, err := s.clientTests.elb.InstanceHealthForAll(lbNames)
+	c.Assert(err, IsNil)
+	c.Assert(states, HasLen, 15)
+	for _, name := range lbNames {
+		c.Assert(states[name], HasLen, 1)
+		c.Assert(states[name][0].State, Equals, "OutOfService")
+	}
+}
+
+func (s *LocalServerSuite) TestInstanceHealthForAllCollectsErrorsPerLoadBalancer(c *C) {
+	srv := s.srv.srv
+	srv.NewLoadBalancer("healthlb")
+	defer srv.RemoveLoadBalancer("healthlb")
+	states, err := s.clientTests.elb.InstanceHealthForAll([]string{"healthlb", "absentlb", "otherlb"})
+	c.Assert(err, NotNil)
+	c.Assert(states, HasLen, 1)
+	c.Assert(states["healthlb"], HasLen, 0)
+	e, ok := err.(elb.BatchError)
+	c.Assert(ok, Equals, true)
+	c.Assert(e, HasLen, 2)
+	c.Assert(e["absentlb"], ErrorMatches, ".*(LoadBalancerNotFound).*")
+	c.Assert(e["otherlb"], ErrorMatches, ".*(LoadBalancerNotFound).*")
+	c.Assert(err, ErrorMatches, "2 errors: absentlb: .*; otherlb: .*")
+}
+
+func (s *LocalServerSuite) TestInstanceHealthForAllContextCanceled(c *C) {
+	srv, err := elbtest.NewServer()
+	c.Assert(err, IsNil)
+	defer srv.Quit()
+	client := elb.NewForTesting(srv.URL())
+	srv.NewLoadBalancer("healthlb")
+	srv.NewLoadBalancer("otherlb")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	states, err := client.InstanceHealthForAllContext(ctx, []string{"healthlb", "otherlb"})
+	c.Assert(states, HasLen, 0)
+	e, ok := err.(elb.BatchError)
+	c.Assert(ok, Equals, true)
+	c.Assert(e, HasLen, 2)
+	c.Assert(e["healthlb"], ErrorMatches, ".*context canceled")
+	c.Assert(e["otherlb"], ErrorMatches, ".*context canceled")
+	c.Assert(srv.Operations(), HasLen, 0)
+}
+
+func (s *LocalServerSuite) TestInstanceHealthForAllContextAbortsRequestsInFlight(c *C) {
+	srv, err := elbtest.NewServer()
+	c.Assert(err, IsNil)
+	defer srv.Quit()
+	client := elb.NewForTesting(srv.URL())
+	srv.NewLoadBalancer("healthlb")
+	srv.SetLatency("DescribeInstanceHealth", 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = client.InstanceHealthForAllContext(ctx, []string{"healthlb"})
+	c.Assert(time.Since(start) < time.Second, Equals, true)
+	e, ok := err.(elb.BatchError)
+	c.Assert(ok, Equals, true)
+	c.Assert(e["healthlb"], ErrorMatches, ".*context deadline exceeded.*")
+}
+
+func (s *LocalServerSuite) TestDescribeLoadBalancerReturnsDefaultHealthCheck(c *C) {
+	createLB := createLBRequest("testlb")
+	createLB.Listeners[0].InstancePort = 8080
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("testlb")
+	c.Assert(err, IsNil)
+	expected := elb.HealthCheck{
+		HealthyThreshold:   10,
+		Interval:           30,
+		Target:             "TCP:8080",
+		Timeout:            5,
+		UnhealthyThreshold: 2,
+	}
+	c.Assert(resp.LoadBalancerDescriptions[0].HealthCheck, DeepEquals, expected)
+}
+
+func (s *LocalServerSuite) TestNewLoadBalancerHasDefaultHealthCheck(c *C) {
+	srv := s.srv.srv
+	srv.NewLoadBalancer("hclb")
+	defer srv.RemoveLoadBalancer("hclb")
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("hclb")
+	c.Assert(err, IsNil)
+	hc := resp.LoadBalancerDescriptions[0].HealthCheck
+	c.Assert(hc.Target, Equals, "TCP:80")
+	c.Assert(hc.Interval, Equals, 30)
+	c.Assert(hc.Timeout, Equals, 5)
+	c.Assert(hc.HealthyThreshold, Equals, 10)
+	c.Assert(hc.UnhealthyThreshold, Equals, 2)
+}
+
+func (s *LocalServerSuite) TestAddLoadBalancerWithoutHealthCheckGetsTheDefault(c *C) {
+	srv := s.srv.srv
+	err := srv.AddLoadBalancer(elb.LoadBalancerDescription{
+		LoadBalancerName: "hclb",
+		ListenerDescriptions: []elb.ListenerDescription{
+			{Listener: elb.Listener{Protocol: "HTTP", LoadBalancerPort: 80, InstanceProtocol: "HTTP", InstancePort: 8080}},
+		},
+	})
+	c.Assert(err, IsNil)
+	defer srv.RemoveLoadBalancer("hclb")
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("hclb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].HealthCheck.Target, Equals, "TCP:8080")
+}
+
+func (s *LocalServerSuite) TestConfigureHealthCheckOverridesDefaultHealthCheck(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	hc := elb.HealthCheck{
+		HealthyThreshold:   3,
+		Interval:           10,
+		Target:             "HTTP:80/ping",
+		Timeout:            2,
+		UnhealthyThreshold: 4,
+	}
+	_, err = s.clientTests.elb.ConfigureHealthCheck("testlb", &hc)
+	c.Assert(err, IsNil)
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("testlb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].HealthCheck, DeepEquals, hc)
+}
+
+func (s *LocalServerSuite) TestConfigureHealthCheckOnlyChangesTheGivenLoadBalancer(c *C) {
+	for _, name := range []string{"hclb1", "hclb2"} {
+		_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest(name))
+		c.Assert(err, IsNil)
+		defer s.clientTests.elb.DeleteLoadBalancer(name)
+	}
+	hc := elb.HealthCheck{
+		HealthyThreshold:   3,
+		Interval:           10,
+		Target:             "HTTP:80/ping",
+		Timeout:            2,
+		UnhealthyThreshold: 4,
+	}
+	_, err := s.clientTests.elb.ConfigureHealthCheck("hclb1", &hc)
+	c.Assert(err, IsNil)
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("hclb1", "hclb2")
+	c.Assert(err, IsNil)
+	byName := resp.LoadBalancersByName()
+	c.Assert(byName["hclb1"].HealthCheck, DeepEquals, hc)
+	c.Assert(byName["hclb2"].HealthCheck.Target, Equals, "TCP:80")
+}
+
+func (s *LocalServerSuite) TestConfigureHealthCheckWithAbsentLoadBalancer(c *C) {
+	hc := elb.HealthCheck{
+		HealthyThreshold:   10,
+		Interval:           30,
+		Target:             "HTTP:80/",
+		Timeout:            5,
+		UnhealthyThreshold: 2,
+	}
+	resp, err := s.clientTests.elb.ConfigureHealthCheck("absentlb", &hc)
+	c.Assert(resp, IsNil)
+	c.Assert(err, ErrorMatches, `^There is no ACTIVE Load Balancer named 'absentlb' \(LoadBalancerNotFound\)$`)
+}
+
+func (s *LocalServerSuite) TestDescribeLoadBalancersWithoutLoadBalancers(c *C) {
+	srv, err := elbtest.NewServer()
+	c.Assert(err, IsNil)
+	defer srv.Quit()
+	r, err := http.Get(srv.URL() + "/?Action=DescribeLoadBalancers")
+	c.Assert(err, IsNil)
+	defer r.Body.Close()
+	c.Assert(r.StatusCode, Equals, 200)
+	body, err := ioutil.ReadAll(r.Body)
+	c.Assert(err, IsNil)
+	c.Assert(string(body), Matches, ".*<DescribeLoadBalancersResult><LoadBalancerDescriptions></LoadBalancerDescriptions></DescribeLoadBalancersResult>.*")
+	resp, err := elb.NewForTesting(srv.URL()).DescribeLoadBalancers()
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions, NotNil)
+	c.Assert(resp.LoadBalancerDescriptions, HasLen, 0)
+}
+
+func (s *LocalServerSuite) TestDescribeLoadBalancerListsInstancesRegisteredThroughAPI(c *C) {
+	srv := s.srv.srv
+	srv.NewLoadBalancer("testlb")
+	defer srv.RemoveLoadBalancer("testlb")
+	inst1, inst2 := srv.NewInstance(), srv.NewInstance()
+	defer srv.RemoveInstance(inst1)
+	defer srv.RemoveInstance(inst2)
+	_, err := s.clientTests.elb.RegisterInstancesWithLoadBalancer([]string{inst1, inst2}, "testlb")
+	c.Assert(err, IsNil)
+	// registering an instance twice does not duplicate it
+	_, err = s.clientTests.elb.RegisterInstancesWithLoadBalancer([]string{inst1}, "testlb")
+	c.Assert(err, IsNil)
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("testlb")
+	c.Assert(err, IsNil)
+	expected := []elb.Instance{{InstanceId: inst1}, {InstanceId: inst2}}
+	c.Assert(resp.LoadBalancerDescriptions[0].Instances, DeepEquals, expected)
+	health, err := s.clientTests.elb.DescribeInstanceHealth("testlb")
+	c.Assert(err, IsNil)
+	c.Assert(health.InstanceStates, HasLen, 2)
+	_, err = s.clientTests.elb.DeregisterInstancesFromLoadBalancer([]string{inst1}, "testlb")
+	c.Assert(err, IsNil)
+	resp, err = s.clientTests.elb.DescribeLoadBalancers("testlb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].Instances, DeepEquals, []elb.Instance{{InstanceId: inst2}})
+}
+
+func (s *LocalServerSuite) TestDescribeLoadBalancerReturnsFullDescription(c *C) {
+	srv := s.srv.srv
+	instId := srv.NewInstance()
+	defer srv.RemoveInstance(instId)
+	before := time.Now().UTC().Add(-time.Second)
+	createResp, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("fulllb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("fulllb")
+	_, err = s.clientTests.elb.RegisterInstancesWithLoadBalancer([]string{instId}, "fulllb")
+	c.Assert(err, IsNil)
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("fulllb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions, HasLen, 1)
+	lb := resp.LoadBalancerDescriptions[0]
+	c.Assert(lb.LoadBalancerName, Equals, "fulllb")
+	c.Assert(lb.DNSName, Equals, createResp.DNSName)
+	c.Assert(lb.AvailZones, DeepEquals, []string{"us-east-1a"})
+	c.Assert(lb.ListenerDescriptions, DeepEquals, []elb.ListenerDescription{{
+		Listener: elb.Listener{InstancePort: 80, InstanceProtocol: "HTTP", LoadBalancerPort: 80, Protocol: "HTTP"},
+	}})
+	c.Assert(lb.Instances, DeepEquals, []elb.Instance{{InstanceId: instId}})
+	c.Assert(lb.CreatedTime.After(before), Equals, true)
+	c.Assert(lb.CreatedTime.After(time.Now().UTC()), Equals, false)
+}
+
+func (s *LocalServerSuite) TestSnapshot(c *C) {
+	srv := s.srv.srv
+	instId := srv.NewInstance()
+	defer srv.RemoveInstance(instId)
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("snapshotlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("snapshotlb")
+	_, err = s.clientTests.elb.RegisterInstancesWithLoadBalancer([]string{instId}, "snapshotlb")
+	c.Assert(err, IsNil)
+	state := srv.Snapshot()
+	lb, ok := state.LoadBalancers["snapshotlb"]
+	c.Assert(ok, Equals, true)
+	c.Assert(lb.LoadBalancerName, Equals, "snapshotlb")
+	c.Assert(lb.AvailZones, DeepEquals, []string{"us-east-1a"})
+	c.Assert(lb.ListenerDescriptions, HasLen, 1)
+	c.Assert(lb.ListenerDescriptions[0].Listener.LoadBalancerPort, Equals, 80)
+	c.Assert(lb.Instances, DeepEquals, []elb.Instance{{InstanceId: instId}})
+	c.Assert(state.InstanceStates["snapshotlb"], HasLen, 1)
+	c.Assert(state.InstanceStates["snapshotlb"][0].InstanceId, Equals, instId)
+	c.Assert(state.Instances, DeepEquals, []string{instId})
+	// changing the server does not change the snapshot
+	_, err = s.clientTests.elb.DeregisterInstancesFromLoadBalancer([]string{instId}, "snapshotlb")
+	c.Assert(err, IsNil)
+	c.Assert(state.LoadBalancers["snapshotlb"].Instances, DeepEquals, []elb.Instance{{InstanceId: instId}})
+	// and changing the snapshot does not change the server
+	lb.AvailZones[0] = "us-east-1b"
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("snapshotlb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].AvailZones, DeepEquals, []string{"us-east-1a"})
+}
+
+func (s *LocalServerSuite) TestRestore(c *C) {
+	srv, err := elbtest.NewServer()
+	c.Assert(err, IsNil)
+	defer srv.Quit()
+	client := elb.NewForTesting(srv.URL())
+	_, err = client.CreateLoadBalancer(createLBRequest("baselb"))
+	c.Assert(err, IsNil)
+	_, err = client.CreateLBCookieStickinessPolicy("baselb", "sticky", 60)
+	c.Assert(err, IsNil)
+	instId := srv.NewInstance()
+	_, err = client.RegisterInstancesWithLoadBalancer([]string{instId}, "baselb")
+	c.Assert(err, IsNil)
+	baseline := srv.Snapshot()
+	for i := 0; i < 2; i++ {
+		_, err = client.DeleteLoadBalancerPolicy("baselb", "sticky")
+		c.Assert(err, IsNil)
+		_, err = client.DeregisterInstancesFromLoadBalancer([]string{instId}, "baselb")
+		c.Assert(err, IsNil)
+		_, err = client.CreateLoadBalancer(createLBRequest("otherlb"))
+		c.Assert(err, IsNil)
+		srv.Restore(baseline)
+		resp, err := client.DescribeLoadBalancers()
+		c.Assert(err, IsNil)
+		c.Assert(resp.LoadBalancerDescriptions, HasLen, 1)
+		c.Assert(resp.LoadBalancerDescriptions[0].Instances, DeepEquals, []elb.Instance{{InstanceId: instId}})
+		policies, err := client.DescribeLoadBalancerPolicies("baselb", "sticky")
+		c.Assert(err, IsNil)
+		c.Assert(policies.PolicyDescriptions, HasLen, 1)
+		health, err := client.DescribeInstanceHealth("baselb")
+		c.Assert(err, IsNil)
+		c.Assert(health.InstanceStates, HasLen, 1)
+	}
+	c.Assert(srv.NewInstance(), Equals, "i-2")
+}
+
+func (s *LocalServerSuite) TestSaveAndLoad(c *C) {
+	srv, err := elbtest.NewServer()
+	c.Assert(err, IsNil)
+	defer srv.Quit()
+	client := elb.NewForTesting(srv.URL())
+	_, err = client.CreateLoadBalancer(createLBRequest("savedlb"))
+	c.Assert(err, IsNil)
+	_, err = client.CreateLBCookieStickinessPolicy("savedlb", "sticky", 60)
+	c.Assert(err, IsNil)
+	_, err = client.AddTags([]string{"savedlb"}, []elb.Tag{{Key: "env", Value: "test"}})
+	c.Assert(err, IsNil)
+	instId := srv.NewInstance()
+	_, err = client.RegisterInstancesWithLoadBalancer([]string{instId}, "savedlb")
+	c.Assert(err, IsNil)
+	srv.NewSubnet("subnet-1")
+	var buf bytes.Buffer
+	c.Assert(srv.Save(&buf), IsNil)
+	loaded, err := elbtest.NewServer()
+	c.Assert(err, IsNil)
+	defer loaded.Quit()
+	c.Assert(loaded.Load(&buf), IsNil)
+	loadedClient := elb.NewForTesting(loaded.URL())
+	want, err := client.DescribeLoadBalancers("savedlb")
+	c.Assert(err, IsNil)
+	got, err := loadedClient.DescribeLoadBalancers("savedlb")
+	c.Assert(err, IsNil)
+	c.Assert(got.LoadBalancerDescriptions[0].CreatedTime.Equal(want.LoadBalancerDescriptions[0].CreatedTime), Equals, true)
+	got.LoadBalancerDescriptions[0].CreatedTime = want.LoadBalancerDescriptions[0].CreatedTime
+	c.Assert(got.LoadBalancerDescriptions, DeepEquals, want.LoadBalancerDescriptions)
+	policies, err := loadedClient.DescribeLoadBalancerPolicies("savedlb", "sticky")
+	c.Assert(err, IsNil)
+	c.Assert(policies.PolicyDescriptions[0].PolicyTypeName, Equals, "LBCookieStickinessPolicyType")
+	tags, err := loadedClient.DescribeTags("savedlb")
+	c.Assert(err, IsNil)
+	c.Assert(tags.TagDescriptions[0].Tags, DeepEquals, []elb.Tag{{Key: "env", Value: "test"}})
+	health, err := loadedClient.DescribeInstanceHealth("savedlb")
+	c.Assert(err, IsNil)
+	c.Assert(health.InstanceStates, HasLen, 1)
+	c.Assert(loaded.Snapshot().Instances, DeepEquals, []string{instId})
+	c.Assert(loaded.NewInstance(), Equals, "i-2")
+}
+
+func (s *LocalServerSuite) TestLoadInvalidState(c *C) {
+	srv, err := elbtest.NewServer()
+	c.Assert(err, IsNil)
+	defer srv.Quit()
+	err = srv.Load(strings.NewReader("not json"))
+	c.Assert(err, ErrorMatches, "cannot load the state of the server: .*")
+}
+
+func (s *LocalServerSuite) TestNewTLSServer(c *C) {
+	srv, err := elbtest.NewTLSServer()
+	c.Assert(err, IsNil)
+	defer srv.Quit()
+	c.Assert(srv.URL(), Matches, "https://.*")
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	r, err := client.Get(srv.URL() + "/?Action=DescribeLoadBalancers")
+	c.Assert(err, IsNil)
+	defer r.Body.Close()
+	c.Assert(r.StatusCode, Equals, 200)
+	body, err := ioutil.ReadAll(r.Body)
+	c.Assert(err, IsNil)
+	c.Assert(string(body), Matches, "<DescribeLoadBalancersResponse xmlns=\"http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/\">.*")
+}
+
+func (s *LocalServerSuite) TestNewServerWithAddr(c *C) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	addr := l.Addr().String()
+	l.Close()
+	srv, err := elbtest.NewServer(elbtest.Options{Addr: addr})
+	c.Assert(err, IsNil)
+	defer srv.Quit()
+	c.Assert(srv.URL(), Equals, "http://"+addr)
+	_, err = elb.NewForTesting(srv.URL()).DescribeLoadBalancers()
+	c.Assert(err, IsNil)
+}
+
+func (s *LocalServerSuite) TestNewServerWithAddrInUse(c *C) {
+	_, err := elbtest.NewServer(elbtest.Options{Addr: s.srv.srv.URL()[len("http://"):]})
+	c.Assert(err, ErrorMatches, "cannot listen on 127.0.0.1:[0-9]+: .*")
+}
+
+func (s *LocalServerSuite) TestCertificateOfPlainServer(c *C) {
+	c.Assert(s.srv.srv.Certificate(), IsNil)
+}
+
+func (s *LocalServerSuite) TestSetCredentialsVerifiesSignatureVersion2(c *C) {
+	srv, err := elbtest.NewServer()
+	c.Assert(err, IsNil)
+	defer srv.Quit()
+	srv.SetCredentials(aws.Auth{AccessKey: "access", SecretKey: "secret"})
+	region := aws.Region{ELBEndpoint: srv.URL()}
+	_, err = elb.New(aws.Auth{AccessKey: "access", SecretKey: "secret"}, region).DescribeLoadBalancers()
+	c.Assert(err, IsNil)
+	var tests = []struct {
+		client *elb.ELB
+		code   string
+	}{
+		{elb.New(aws.Auth{AccessKey: "access", SecretKey: "wrong"}, region), "SignatureDoesNotMatch"},
+		{elb.New(aws.Auth{AccessKey: "unknown", SecretKey: "secret"}, region), "AuthFailure"},
+		{elb.NewForTesting(srv.URL()), "AuthFailure"},
+	}
+	for _, t := range tests {
+		_, err := t.client.DescribeLoadBalancers()
+		e, ok := err.(*elb.Error)
+		c.Assert(ok, Equals, true)
+		c.Check(e.StatusCode, Equals, 403)
+		c.Check(e.Code, Equals, t.code)
+	}
+	srv.SetCredentials()
+	_, err = elb.NewForTesting(srv.URL()).DescribeLoadBalancers()
+	c.Assert(err, IsNil)
+}
+
+func (s *LocalServerSuite) TestSetCredentialsVerifiesSignatureVersion4(c *C) {
+	srv, err := elbtest.NewServer()
+	c.Assert(err, IsNil)
+	defer srv.Quit()
+	srv.SetCredentials(aws.Auth{AccessKey: "AKIDEXAMPLE", SecretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"})
+	// the get-vanilla request of the Signature Version 4 test suite.
+	for signature, code := range map[string]string{
+		"5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31": "InvalidParameterValue",
+		"0000000000000000000000000000000000000000000000000000000000000000": "SignatureDoesNotMatch",
+	} {
+		req, err := http.NewRequest("GET", srv.URL()+"/", nil)
+		c.Assert(err, IsNil)
+		req.Host = "example.amazonaws.com"
+		req.Header.Set("X-Amz-Date", "20150830T123600Z")
+		req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature="+signature)
+		r, err := http.DefaultClient.Do(req)
+		c.Assert(err, IsNil)
+		var resp struct {
+			Code string `xml:"Error>Code"`
+		}
+		err = xml.NewDecoder(r.Body).Decode(&resp)
+		r.Body.Close()
+		c.Assert(err, IsNil)
+		// a valid signature gets past authentication, failing only
+		// because the request has no action.
+		c.Check(resp.Code, Equals, code)
+	}
+}
+
+func (s *LocalServerSuite) TestDescribeClassicLoadBalancerListsAvailabilityZones(c *C) {
+	createLB := createLBRequest("classiclb")
+	createLB.AvailZones = []string{"us-east-1a", "us-east-1b"}
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("classiclb")
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("classiclb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].AvailZones, DeepEquals, []string{"us-east-1a", "us-east-1b"})
+	c.Assert(resp.LoadBalancerDescriptions[0].Subnets, HasLen, 0)
+}
+
+func (s *LocalServerSuite) TestDescribeVPCLoadBalancerListsSubnets(c *C) {
+	createLB := createLBRequest("vpclb")
+	createLB.AvailZones = nil
+	createLB.Subnets = []string{"subnet-1", "subnet-2"}
+	createLB.SecurityGroups = []string{"sg-1"}
+	s.srv.srv.NewSubnet("subnet-1")
+	defer s.srv.srv.RemoveSubnet("subnet-1")
+	s.srv.srv.NewSubnet("subnet-2")
+	defer s.srv.srv.RemoveSubnet("subnet-2")
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("vpclb")
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("vpclb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].Subnets, DeepEquals, []string{"subnet-1", "subnet-2"})
+	c.Assert(resp.LoadBalancerDescriptions[0].SecurityGroups, DeepEquals, []string{"sg-1"})
+	c.Assert(resp.LoadBalancerDescriptions[0].AvailZones, HasLen, 0)
+}
+
+func (s *LocalServerSuite) createLBToRecreate(c *C, name string) []string {
+	srv := s.srv.srv
+	createLB := createLBRequest(name)
+	createLB.Listeners = append(createLB.Listeners, elb.Listener{
+		InstancePort:     8080,
+		InstanceProtocol: "tcp",
+		LoadBalancerPort: 8080,
+		Protocol:         "tcp",
+	})
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, IsNil)
+	hc := elb.HealthCheck{
+		HealthyThreshold:   3,
+		Interval:           10,
+		Target:             "HTTP:80/ping",
+		Timeout:            2,
+		UnhealthyThreshold: 4,
+	}
+	_, err = s.clientTests.elb.ConfigureHealthCheck(name, &hc)
+	c.Assert(err, IsNil)
+	instIds := []string{srv.NewInstance(), srv.NewInstance()}
+	_, err = s.clientTests.elb.RegisterInstancesWithLoadBalancer(instIds, name)
+	c.Assert(err, IsNil)
+	return instIds
+}
+
+func (s *LocalServerSuite) TestRecreateWithName(c *C) {
+	instIds := s.createLBToRecreate(c, "oldlb")
+	defer s.srv.srv.RemoveInstance(instIds[0])
+	defer s.srv.srv.RemoveInstance(instIds[1])
+	defer s.clientTests.elb.DeleteLoadBalancer("oldlb")
+	defer s.clientTests.elb.DeleteLoadBalancer("newlb")
+	old, err := s.clientTests.elb.DescribeLoadBalancers("oldlb")
+	c.Assert(err, IsNil)
+	lb, err := s.clientTests.elb.RecreateWithName("oldlb", "newlb", true)
+	c.Assert(err, IsNil)
+	c.Assert(lb.LoadBalancerName, Equals, "newlb")
+	c.Assert(lb.AvailZones, DeepEquals, old.LoadBalancerDescriptions[0].AvailZones)
+	c.Assert(lb.ListenerDescriptions, DeepEquals, old.LoadBalancerDescriptions[0].ListenerDescriptions)
+	c.Assert(lb.HealthCheck, DeepEquals, old.LoadBalancerDescriptions[0].HealthCheck)
+	c.Assert(lb.Instances, DeepEquals, []elb.Instance{{InstanceId: instIds[0]}, {InstanceId: instIds[1]}})
+	_, err = s.clientTests.elb.DescribeLoadBalancers("oldlb")
+	c.Assert(err, ErrorMatches, ".*(LoadBalancerNotFound).*")
+	// running it again after the old one is gone is harmless
+	again, err := s.clientTests.elb.RecreateWithName("oldlb", "newlb", true)
+	c.Assert(err, IsNil)
+	c.Assert(again, DeepEquals, lb)
+}
+
+func (s *LocalServerSuite) TestRecreateWithNameKeepingOldLoadBalancer(c *C) {
+	instIds := s.createLBToRecreate(c, "oldlb")
+	defer s.srv.srv.RemoveInstance(instIds[0])
+	defer s.srv.srv.RemoveInstance(instIds[1])
+	defer s.clientTests.elb.DeleteLoadBalancer("oldlb")
+	defer s.clientTests.elb.DeleteLoadBalancer("newlb")
+	_, err := s.clientTests.elb.RecreateWithName("oldlb", "newlb", false)
+	c.Assert(err, IsNil)
+	// resuming reuses the new load balancer
+	lb, err := s.clientTests.elb.RecreateWithName("oldlb", "newlb", false)
+	c.Assert(err, IsNil)
+	c.Assert(lb.Instances, HasLen, 2)
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("oldlb", "newlb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions, HasLen, 2)
+}
+
+func (s *LocalServerSuite) TestRecreateWithNameCopiesAttributesTagsAndPolicies(c *C) {
+	instIds := s.createLBToRecreate(c, "oldlb")
+	defer s.srv.srv.RemoveInstance(instIds[0])
+	defer s.srv.srv.RemoveInstance(instIds[1])
+	defer s.clientTests.elb.DeleteLoadBalancer("oldlb")
+	defer s.clientTests.elb.DeleteLoadBalancer("newlb")
+	s.enableConnectionDraining(c, "oldlb", 120)
+	_, err := s.clientTests.elb.AddTags([]string{"oldlb"}, []elb.Tag{{Key: "env", Value: "prod"}})
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.CreateLBCookieStickinessPolicy("oldlb", "sticky", 60)
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.SetLoadBalancerPoliciesOfListener("oldlb", 80, []string{"sticky"})
+	c.Assert(err, IsNil)
+	attrs := []elb.PolicyAttribute{{AttributeName: "ProxyProtocol", AttributeValue: "true"}}
+	_, err = s.clientTests.elb.CreateLoadBalancerPolicy("oldlb", "proxy", "ProxyProtocolPolicyType", attrs)
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.SetLoadBalancerPoliciesForBackendServer("oldlb", 8080, []string{"proxy"})
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.RecreateWithName("oldlb", "newlb", false)
+	c.Assert(err, IsNil)
+	// resuming doesn't create the policies again
+	lb, err := s.clientTests.elb.RecreateWithName("oldlb", "newlb", false)
+	c.Assert(err, IsNil)
+	old, err := s.clientTests.elb.DescribeLoadBalancers("oldlb")
+	c.Assert(err, IsNil)
+	c.Assert(lb.ListenerDescriptions, DeepEquals, old.LoadBalancerDescriptions[0].ListenerDescriptions)
+	c.Assert(lb.BackendServerDescriptions, DeepEquals, []elb.BackendServerDescription{
+		{InstancePort: 8080, PolicyNames: []string{"proxy"}},
+	})
+	c.Assert(lb.Policies, DeepEquals, old.LoadBalancerDescriptions[0].Policies)
+	resp, err := s.clientTests.elb.DescribeLoadBalancerAttributes("newlb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerAttributes.ConnectionDraining, DeepEquals, &elb.ConnectionDraining{Enabled: true, Timeout: 120})
+	tags, err := s.clientTests.elb.DescribeTags("newlb")
+	c.Assert(err, IsNil)
+	c.Assert(tags.TagDescriptions, HasLen, 1)
+	c.Assert(tags.TagDescriptions[0].Tags, DeepEquals, []elb.Tag{{Key: "env", Value: "prod"}})
+	policies, err := s.clientTests.elb.DescribeLoadBalancerPolicies("newlb", "proxy")
+	c.Assert(err, IsNil)
+	c.Assert(policies.PolicyDescriptions[0].PolicyAttributeDescriptions, DeepEquals, attrs)
+}
+
+func (s *LocalServerSuite) TestRecreateWithNameWithAbsentLoadBalancers(c *C) {
+	lb, err := s.clientTests.elb.RecreateWithName("absentlb", "otherlb", true)
+	c.Assert(lb, IsNil)
+	c.Assert(err, ErrorMatches, ".*(LoadBalancerNotFound).*")
+}
+
+func (s *LocalServerSuite) TestCreateLoadBalancerWithSSLCertificate(c *C) {
+	createLB := createLBRequest("testlb")
+	createLB.Listeners[0].Protocol = "https"
+	createLB.Listeners[0].LoadBalancerPort = 443
+	createLB.Listeners[0].SSLCertificateId = "arn:aws:acm:us-east-1:123456789012:certificate/12345678-1234-1234-1234-123456789012"
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("testlb")
+	c.Assert(err, IsNil)
+	listener := resp.LoadBalancerDescriptions[0].ListenerDescriptions[0].Listener
+	c.Assert(listener.Protocol, Equals, "HTTPS")
+	c.Assert(listener.SSLCertificateId, Equals, createLB.Listeners[0].SSLCertificateId)
+}
+
+func (s *LocalServerSuite) TestCreateLoadBalancerWithMalformedSSLCertificateIsRejectedByServer(c *C) {
+	params := url.Values{
+		"Action":                              {"CreateLoadBalancer"},
+		"LoadBalancerName":                    {"testlb"},
+		"AvailabilityZones.member.1":          {"us-east-1a"},
+		"Listeners.member.1.InstancePort":     {"80"},
+		"Listeners.member.1.InstanceProtocol": {"http"},
+		"Listeners.member.1.Protocol":         {"https"},
+		"Listeners.member.1.LoadBalancerPort": {"443"},
+		"Listeners.member.1.SSLCertificateId": {"mycert"},
+	}
+	r, err := http.Get(s.srv.srv.URL() + "/?" + params.Encode())
+	c.Assert(err, IsNil)
+	defer r.Body.Close()
+	c.Assert(r.StatusCode, Equals, 400)
+	body, err := ioutil.ReadAll(r.Body)
+	c.Assert(err, IsNil)
+	c.Assert(string(body), Matches, ".*<Code>CertificateNotFound</Code>.*")
+}
+
+func (s *LocalServerSuite) TestCreateLoadBalancerWithoutSSLCertificate(c *C) {
+	params := url.Values{
+		"Action":                              {"CreateLoadBalancer"},
+		"LoadBalancerName":                    {"testlb"},
+		"AvailabilityZones.member.1":          {"us-east-1a"},
+		"Listeners.member.1.InstancePort":     {"80"},
+		"Listeners.member.1.InstanceProtocol": {"http"},
+		"Listeners.member.1.Protocol":         {"https"},
+		"Listeners.member.1.LoadBalancerPort": {"443"},
+	}
+	r, err := http.Get(s.srv.srv.URL() + "/?" + params.Encode())
+	c.Assert(err, IsNil)
+	defer r.Body.Close()
+	c.Assert(r.StatusCode, Equals, 400)
+	body, err := ioutil.ReadAll(r.Body)
+	c.Assert(err, IsNil)
+	c.Assert(string(body), Matches, ".*<Code>ValidationError</Code><Message>Listeners.member.1.SSLCertificateId is required by HTTPS listeners</Message>.*")
+}
+
+func (s *LocalServerSuite) TestCreateLoadBalancerWithUnknownSSLCertificate(c *C) {
+	srv := s.srv.srv
+	srv.SetStrict(true)
+	defer srv.SetStrict(false)
+	cert := "arn:aws:iam::123456789012:server-certificate/mycert"
+	createLB := createLBRequest("ssllb")
+	createLB.Listeners = []elb.Listener{{
+		InstancePort:     80,
+		InstanceProtocol: "HTTP",
+		LoadBalancerPort: 443,
+		Protocol:         "HTTPS",
+		SSLCertificateId: cert,
+	}}
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, NotNil)
+	e, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.StatusCode, Equals, 400)
+	c.Assert(e.Code, Equals, "CertificateNotFound")
+	c.Assert(e.Message, Equals, "Server certificate "+cert+" was not found")
+	srv.NewCertificate(cert)
+	defer srv.RemoveCertificate(cert)
+	_, err = s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("ssllb")
+	other := "arn:aws:iam::123456789012:server-certificate/othercert"
+	_, err = s.clientTests.elb.SetLoadBalancerListenerSSLCertificate("ssllb", 443, other)
+	c.Assert(err, ErrorMatches, `^Server certificate `+other+` was not found \(CertificateNotFound\)$`)
+}
+
+func (s *LocalServerSuite) TestCreateConsistencyDelay(c *C) {
+	srv := s.srv.srv
+	srv.SetCreateConsistencyDelay(200 * time.Millisecond)
+	defer srv.SetCreateConsistencyDelay(0)
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	_, err = s.clientTests.elb.DescribeLoadBalancers("testlb")
+	c.Assert(err, ErrorMatches, `^Cannot find Load Balancer testlb \(LoadBalancerNotFound\)$`)
+	resp, err := s.clientTests.elb.DescribeLoadBalancers()
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancersByName()["testlb"], IsNil)
+	time.Sleep(250 * time.Millisecond)
+	resp, err = s.clientTests.elb.DescribeLoadBalancers("testlb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].LoadBalancerName, Equals, "testlb")
+	resp, err = s.clientTests.elb.DescribeLoadBalancers()
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancersByName()["testlb"], NotNil)
+}
+
+// fakeClock is a Clock whose time only moves when advanced.
+type fakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func (s *LocalServerSuite) TestSetClock(c *C) {
+	clock := &fakeClock{now: time.Date(2014, 3, 1, 10, 0, 0, 0, time.UTC)}
+	s.srv.srv.SetClock(clock)
+	defer s.srv.srv.SetClock(nil)
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("testlb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].CreatedTime.Equal(clock.now), Equals, true)
+}
+
+func (s *LocalServerSuite) TestCreateConsistencyDelayWithClock(c *C) {
+	srv := s.srv.srv
+	clock := &fakeClock{now: time.Date(2014, 3, 1, 10, 0, 0, 0, time.UTC)}
+	srv.SetClock(clock)
+	defer srv.SetClock(nil)
+	srv.SetCreateConsistencyDelay(time.Hour)
+	defer srv.SetCreateConsistencyDelay(0)
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	_, err = s.clientTests.elb.DescribeLoadBalancers("testlb")
+	c.Assert(err, ErrorMatches, `^Cannot find Load Balancer testlb \(LoadBalancerNotFound\)$`)
+	clock.Advance(time.Hour)
+	_, err = s.clientTests.elb.DescribeLoadBalancers("testlb")
+	c.Assert(err, IsNil)
+}
+
+func (s *LocalServerSuite) TestConnectionDrainingWithClock(c *C) {
+	srv := s.srv.srv
+	clock := &fakeClock{now: time.Date(2014, 3, 1, 10, 0, 0, 0, time.UTC)}
+	srv.SetClock(clock)
+	defer srv.SetClock(nil)
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("draininglb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("draininglb")
+	s.enableConnectionDraining(c, "draininglb", 300)
+	inst := srv.NewInstance()
+	defer srv.RemoveInstance(inst)
+	_, err = s.clientTests.elb.RegisterInstancesWithLoadBalancer([]string{inst}, "draininglb")
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.DeregisterInstancesFromLoadBalancer([]string{inst}, "draininglb")
+	c.Assert(err, IsNil)
+	clock.Advance(299 * time.Second)
+	resp, err := s.clientTests.elb.DescribeInstanceHealth("draininglb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.InstanceStates, HasLen, 1)
+	clock.Advance(time.Second)
+	resp, err = s.clientTests.elb.DescribeInstanceHealth("draininglb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.InstanceStates, HasLen, 0)
+}
+
+func (s *LocalServerSuite) TestDescribeLoadBalancersPages(c *C) {
+	names := []string{"pagelb1", "pagelb2", "pagelb3", "pagelb4", "pagelb5"}
+	for _, name := range names {
+		_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest(name))
+		c.Assert(err, IsNil)
+		defer s.clientTests.elb.DeleteLoadBalancer(name)
+	}
+	var described []string
+	var marker string
+	for pages := 1; ; pages++ {
+		resp, err := s.clientTests.elb.DescribeLoadBalancersPage(marker, 2)
+		c.Assert(err, IsNil)
+		c.Assert(len(resp.LoadBalancerDescriptions) <= 2, Equals, true)
+		for _, lb := range resp.LoadBalancerDescriptions {
+			described = append(described, lb.LoadBalancerName)
+		}
+		if resp.NextMarker == "" {
+			c.Assert(pages, Equals, 3)
+			break
+		}
+		marker = resp.NextMarker
+	}
+	c.Assert(described, DeepEquals, names)
+}
+
+func (s *LocalServerSuite) TestDescribeLoadBalancersWithInvalidPageSize(c *C) {
+	for _, size := range []int{-1, 401} {
+		_, err := s.clientTests.elb.DescribeLoadBalancersPage("", size)
+		e, ok := err.(*elb.Error)
+		c.Assert(ok, Equals, true)
+		c.Check(e.Code, Equals, "ValidationError")
+		c.Check(e.Message, Equals, fmt.Sprintf("Invalid value '%d' for PageSize, it must be between 1 and 400", size))
+	}
+}
+
+func (s *LocalServerSuite) TestDescribeLoadBalancersWithInvalidMarker(c *C) {
+	_, err := s.clientTests.elb.DescribeLoadBalancersPage("invalid", 0)
+	e, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.Code, Equals, "ValidationError")
+	c.Assert(e.Message, Equals, "Invalid value 'invalid' for Marker")
+}
+
+func (s *LocalServerSuite) TestWaitUntilLoadBalancerExists(c *C) {
+	srv := s.srv.srv
+	srv.SetCreateConsistencyDelay(300 * time.Millisecond)
+	defer srv.SetCreateConsistencyDelay(0)
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	start := time.Now()
+	err = s.clientTests.elb.WaitUntilLoadBalancerExists("testlb", 5*time.Second)
+	c.Assert(err, IsNil)
+	c.Assert(time.Since(start) >= 250*time.Millisecond, Equals, true)
+	_, err = s.clientTests.elb.DescribeLoadBalancers("testlb")
+	c.Assert(err, IsNil)
+}
+
+func (s *LocalServerSuite) TestWaitUntilLoadBalancerExistsTimesOut(c *C) {
+	srv := s.srv.srv
+	srv.SetCreateConsistencyDelay(time.Minute)
+	defer srv.SetCreateConsistencyDelay(0)
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	err = s.clientTests.elb.WaitUntilLoadBalancerExists("testlb", 200*time.Millisecond)
+	c.Assert(err, ErrorMatches, "timed out waiting for Load Balancer testlb to exist")
+}
+
+func (s *LocalServerSuite) TestCreateAndDeleteLoadBalancerListeners(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	listener := elb.Listener{
+		InstancePort:     8080,
+		InstanceProtocol: "HTTP",
+		LoadBalancerPort: 8080,
+		Protocol:         "HTTP",
+	}
+	_, err = s.clientTests.elb.CreateLoadBalancerListeners("testlb", []elb.Listener{listener})
+	c.Assert(err, IsNil)
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("testlb")
+	c.Assert(err, IsNil)
+	lds := resp.LoadBalancerDescriptions[0].ListenerDescriptions
+	c.Assert(lds, HasLen, 2)
+	c.Assert(lds[1].Listener, DeepEquals, listener)
+	_, err = s.clientTests.elb.DeleteLoadBalancerListeners("testlb", 80)
+	c.Assert(err, IsNil)
+	resp, err = s.clientTests.elb.DescribeLoadBalancers("testlb")
+	c.Assert(err, IsNil)
+	lds = resp.LoadBalancerDescriptions[0].ListenerDescriptions
+	c.Assert(lds, HasLen, 1)
+	c.Assert(lds[0].Listener, DeepEquals, listener)
+}
+
+func (s *LocalServerSuite) TestDeleteSeveralLoadBalancerListeners(c *C) {
+	createLB := createLBRequest("testlb")
+	createLB.Listeners = append(createLB.Listeners, elb.Listener{
+		InstancePort:     8080,
+		InstanceProtocol: "HTTP",
+		LoadBalancerPort: 8080,
+		Protocol:         "HTTP",
+	}, elb.Listener{
+		InstancePort:     9000,
+		InstanceProtocol: "TCP",
+		LoadBalancerPort: 9000,
+		Protocol:         "TCP",
+	})
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	// ports without a listener are ignored
+	_, err = s.clientTests.elb.DeleteLoadBalancerListeners("testlb", 80, 9000, 443)
+	c.Assert(err, IsNil)
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("testlb")
+	c.Assert(err, IsNil)
+	lds := resp.LoadBalancerDescriptions[0].ListenerDescriptions
+	c.Assert(lds, HasLen, 1)
+	c.Assert(lds[0].Listener.LoadBalancerPort, Equals, 8080)
+}
+
+func (s *LocalServerSuite) TestDeleteLoadBalancerListenersOfAbsentLoadBalancer(c *C) {
+	_, err := s.clientTests.elb.DeleteLoadBalancerListeners("absentlb", 80)
+	e, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.Code, Equals, "LoadBalancerNotFound")
+}
+
+func (s *LocalServerSuite) TestSetLoadBalancerPoliciesOfListener(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("policylb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("policylb")
+	_, err = s.clientTests.elb.CreateLBCookieStickinessPolicy("policylb", "sticky", 60)
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.CreateAppCookieStickinessPolicy("policylb", "appsticky", "JSESSIONID")
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.SetLoadBalancerPoliciesOfListener("policylb", 80, []string{"sticky"})
+	c.Assert(err, IsNil)
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("policylb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].ListenerDescriptions[0].PolicyNames, DeepEquals, []string{"sticky"})
+	_, err = s.clientTests.elb.SetLoadBalancerPoliciesOfListener("policylb", 80, []string{"appsticky"})
+	c.Assert(err, IsNil)
+	resp, err = s.clientTests.elb.DescribeLoadBalancers("policylb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].ListenerDescriptions[0].PolicyNames, DeepEquals, []string{"appsticky"})
+	_, err = s.clientTests.elb.DeleteLoadBalancerPolicy("policylb", "appsticky")
+	c.Assert(err, ErrorMatches, `.*\(InvalidConfigurationRequest\)$`)
+	_, err = s.clientTests.elb.SetLoadBalancerPoliciesOfListener("policylb", 80, nil)
+	c.Assert(err, IsNil)
+	resp, err = s.clientTests.elb.DescribeLoadBalancers("policylb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].ListenerDescriptions[0].PolicyNames, HasLen, 0)
+	_, err = s.clientTests.elb.DeleteLoadBalancerPolicy("policylb", "appsticky")
+	c.Assert(err, IsNil)
+}
+
+func (s *LocalServerSuite) TestSetLoadBalancerPoliciesOfListenerErrors(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("policylb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("policylb")
+	_, err = s.clientTests.elb.CreateLBCookieStickinessPolicy("policylb", "sticky", 60)
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.SetLoadBalancerPoliciesOfListener("policylb", 80, []string{"sticky", "unknown"})
+	c.Assert(err, ErrorMatches, `^There is no policy named unknown \(PolicyNotFound\)$`)
+	_, err = s.clientTests.elb.SetLoadBalancerPoliciesOfListener("policylb", 8080, []string{"sticky"})
+	c.Assert(err, ErrorMatches, `^Load Balancer policylb has no listener on port 8080 \(ListenerNotFound\)$`)
+	_, err = s.clientTests.elb.SetLoadBalancerPoliciesOfListener("absentlb", 80, nil)
+	c.Assert(err, ErrorMatches, `.*\(LoadBalancerNotFound\)$`)
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("policylb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].ListenerDescriptions[0].PolicyNames, HasLen, 0)
+}
+
+func (s *LocalServerSuite) TestSetLoadBalancerListenerSSLCertificate(c *C) {
+	createLB := createLBRequest("ssllb")
+	createLB.Listeners = append(createLB.Listeners, elb.Listener{
+		InstancePort:     80,
+		InstanceProtocol: "HTTP",
+		LoadBalancerPort: 443,
+		Protocol:         "HTTPS",
+		SSLCertificateId: "arn:aws:iam::123456789012:server-certificate/oldcert",
+	})
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("ssllb")
+	newCert := "arn:aws:acm:us-east-1:123456789012:certificate/12345678-1234-1234-1234-123456789012"
+	_, err = s.clientTests.elb.SetLoadBalancerListenerSSLCertificate("ssllb", 443, newCert)
+	c.Assert(err, IsNil)
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("ssllb")
+	c.Assert(err, IsNil)
+	lds := resp.LoadBalancerDescriptions[0].ListenerDescriptions
+	c.Assert(lds, HasLen, 2)
+	c.Assert(lds[0].Listener.SSLCertificateId, Equals, "")
+	c.Assert(lds[1].Listener.SSLCertificateId, Equals, newCert)
+}
+
+func (s *LocalServerSuite) TestSetLoadBalancerListenerSSLCertificateErrors(c *C) {
+	createLB := createLBRequest("ssllb")
+	createLB.Listeners = append(createLB.Listeners, elb.Listener{
+		InstancePort:     80,
+		InstanceProtocol: "HTTP",
+		LoadBalancerPort: 443,
+		Protocol:         "HTTPS",
+		SSLCertificateId: "arn:aws:iam::123456789012:server-certificate/oldcert",
+	})
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("ssllb")
+	cert := "arn:aws:iam::123456789012:server-certificate/newcert"
+	_, err = s.clientTests.elb.SetLoadBalancerListenerSSLCertificate("ssllb", 8443, cert)
+	c.Assert(err, ErrorMatches, `^Load Balancer ssllb has no listener on port 8443 \(ListenerNotFound\)$`)
+	_, err = s.clientTests.elb.SetLoadBalancerListenerSSLCertificate("ssllb", 80, cert)
+	c.Assert(err, ErrorMatches, `.*\(InvalidConfigurationRequest\)$`)
+	_, err = s.clientTests.elb.SetLoadBalancerListenerSSLCertificate("ssllb", 443, "mycert")
+	c.Assert(err, ErrorMatches, `.*\(CertificateNotFound\)$`)
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("ssllb")
+	c.Assert(err, IsNil)
+	l := resp.LoadBalancerDescriptions[0].ListenerDescriptions[1].Listener
+	c.Assert(l.SSLCertificateId, Equals, "arn:aws:iam::123456789012:server-certificate/oldcert")
+}
+
+func (s *LocalServerSuite) TestCreateLBCookieStickinessPolicy(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("policylb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("policylb")
+	_, err = s.clientTests.elb.CreateLBCookieStickinessPolicy("policylb", "sticky60", 60)
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.CreateLBCookieStickinessPolicy("policylb", "session", 0)
+	c.Assert(err, IsNil)
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("policylb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].Policies.LBCookieStickinessPolicies, DeepEquals, []elb.LBCookieStickinessPolicies{
+		{CookieExpirationPeriod: 60, PolicyName: "sticky60"},
+		{CookieExpirationPeriod: 0, PolicyName: "session"},
+	})
+}
+
+func (s *LocalServerSuite) TestCreateLBCookieStickinessPolicyWithDuplicateName(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("policylb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("policylb")
+	_, err = s.clientTests.elb.CreateLBCookieStickinessPolicy("policylb", "sticky", 60)
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.CreateLBCookieStickinessPolicy("policylb", "sticky", 120)
+	e, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.Code, Equals, "DuplicatePolicyName")
+	c.Assert(e.Message, Equals, "Policy sticky already exists for Load Balancer policylb")
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("policylb")
+	c.Assert(err, IsNil)
+	policies := resp.LoadBalancerDescriptions[0].Policies.LBCookieStickinessPolicies
+	c.Assert(policies, HasLen, 1)
+	c.Assert(policies[0].CookieExpirationPeriod, Equals, 60)
+}
+
+func (s *LocalServerSuite) TestCreateAppCookieStickinessPolicy(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("policylb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("policylb")
+	_, err = s.clientTests.elb.CreateAppCookieStickinessPolicy("policylb", "appsticky", "JSESSIONID")
+	c.Assert(err, IsNil)
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("policylb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].Policies.AppCookieStickinessPolicies, DeepEquals, []elb.AppCookieStickinessPolicies{
+		{CookieName: "JSESSIONID", PolicyName: "appsticky"},
+	})
+}
+
+func (s *LocalServerSuite) TestCreateAppCookieStickinessPolicyNamedAfterAnotherPolicy(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("policylb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("policylb")
+	_, err = s.clientTests.elb.CreateLBCookieStickinessPolicy("policylb", "sticky", 60)
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.CreateAppCookieStickinessPolicy("policylb", "sticky", "JSESSIONID")
+	c.Assert(err, ErrorMatches, ".*DuplicatePolicyName.*")
+}
+
+func (s *LocalServerSuite) TestCreateAppCookieStickinessPolicyWithoutCookieName(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("policylb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("policylb")
+	_, err = s.clientTests.elb.CreateAppCookieStickinessPolicy("policylb", "appsticky", "")
+	e, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.StatusCode, Equals, 400)
+}
+
+func (s *LocalServerSuite) TestCreateLoadBalancerPolicy(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("policylb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("policylb")
+	proxy := []elb.PolicyAttribute{{AttributeName: "ProxyProtocol", AttributeValue: "true"}}
+	_, err = s.clientTests.elb.CreateLoadBalancerPolicy("policylb", "proxy", "ProxyProtocolPolicyType", proxy)
+	c.Assert(err, IsNil)
+	ssl := []elb.PolicyAttribute{{AttributeName: "Reference-Security-Policy", AttributeValue: "ELBSecurityPolicy-2016-08"}}
+	_, err = s.clientTests.elb.CreateLoadBalancerPolicy("policylb", "ssl", "SSLNegotiationPolicyType", ssl)
+	c.Assert(err, IsNil)
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("policylb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].Policies.OtherPolicies, DeepEquals, []string{"proxy", "ssl"})
+	_, err = s.clientTests.elb.CreateLoadBalancerPolicy("policylb", "proxy", "ProxyProtocolPolicyType", proxy)
+	c.Assert(err, ErrorMatches, ".*DuplicatePolicyName.*")
+}
+
+func (s *LocalServerSuite) TestCreateLoadBalancerPolicyOfUnknownType(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("policylb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("policylb")
+	_, err = s.clientTests.elb.CreateLoadBalancerPolicy("policylb", "policy", "UnknownPolicyType", nil)
+	c.Assert(err, ErrorMatches, `^Policy type UnknownPolicyType does not exist \(PolicyTypeNotFound\)$`)
+}
+
+func (s *LocalServerSuite) TestDeleteLoadBalancerPolicy(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("policylb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("policylb")
+	_, err = s.clientTests.elb.CreateLBCookieStickinessPolicy("policylb", "sticky", 60)
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.CreateAppCookieStickinessPolicy("policylb", "appsticky", "JSESSIONID")
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.DeleteLoadBalancerPolicy("policylb", "sticky")
+	c.Assert(err, IsNil)
+	// deleting a policy that does not exist succeeds
+	_, err = s.clientTests.elb.DeleteLoadBalancerPolicy("policylb", "sticky")
+	c.Assert(err, IsNil)
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("policylb")
+	c.Assert(err, IsNil)
+	policies := resp.LoadBalancerDescriptions[0].Policies
+	c.Assert(policies.LBCookieStickinessPolicies, HasLen, 0)
+	c.Assert(policies.AppCookieStickinessPolicies, HasLen, 1)
+	_, err = s.clientTests.elb.CreateLBCookieStickinessPolicy("policylb", "sticky", 120)
+	c.Assert(err, IsNil)
+}
+
+func (s *LocalServerSuite) TestDeleteLoadBalancerPolicyInUse(c *C) {
+	srv := s.srv.srv
+	err := srv.AddLoadBalancer(elb.LoadBalancerDescription{
+		LoadBalancerName: "policylb",
+		ListenerDescriptions: []elb.ListenerDescription{{
+			Listener:    elb.Listener{InstancePort: 80, InstanceProtocol: "HTTP", LoadBalancerPort: 80, Protocol: "HTTP"},
+			PolicyNames: []string{"sticky"},
+		}},
+		Policies: elb.Policies{
+			LBCookieStickinessPolicies: []elb.LBCookieStickinessPolicies{{CookieExpirationPeriod: 60, PolicyName: "sticky"}},
+		},
+	})
+	c.Assert(err, IsNil)
+	defer srv.RemoveLoadBalancer("policylb")
+	proxy := []elb.PolicyAttribute{{AttributeName: "ProxyProtocol", AttributeValue: "true"}}
+	_, err = s.clientTests.elb.CreateLoadBalancerPolicy("policylb", "proxy", "ProxyProtocolPolicyType", proxy)
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.SetLoadBalancerPoliciesForBackendServer("policylb", 8080, []string{"proxy"})
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.DeleteLoadBalancerPolicy("policylb", "sticky")
+	c.Assert(err, ErrorMatches, `^Policy sticky is in use by the listener on port 80 \(InvalidConfigurationRequest\)$`)
+	_, err = s.clientTests.elb.DeleteLoadBalancerPolicy("policylb", "proxy")
+	c.Assert(err, ErrorMatches, `^Policy proxy is in use by the backend server on port 8080 \(InvalidConfigurationRequest\)$`)
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("policylb")
+	c.Assert(err, IsNil)
+	policies := resp.LoadBalancerDescriptions[0].Policies
+	c.Assert(policies.LBCookieStickinessPolicies, HasLen, 1)
+	c.Assert(policies.OtherPolicies, DeepEquals, []string{"proxy"})
+}
+
+func (s *LocalServerSuite) TestDescribeLoadBalancerPolicies(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("policylb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("policylb")
+	resp, err := s.clientTests.elb.DescribeLoadBalancerPolicies("policylb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.PolicyDescriptions, HasLen, 0)
+	_, err = s.clientTests.elb.CreateLBCookieStickinessPolicy("policylb", "sticky", 60)
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.CreateAppCookieStickinessPolicy("policylb", "appsticky", "JSESSIONID")
+	c.Assert(err, IsNil)
+	proxy := []elb.PolicyAttribute{{AttributeName: "ProxyProtocol", AttributeValue: "true"}}
+	_, err = s.clientTests.elb.CreateLoadBalancerPolicy("policylb", "proxy", "ProxyProtocolPolicyType", proxy)
+	c.Assert(err, IsNil)
+	resp, err = s.clientTests.elb.DescribeLoadBalancerPolicies("policylb")
+	c.Assert(err, IsNil)
+	expected := []elb.PolicyDescription{
+		{
+			PolicyName:                  "sticky",
+			PolicyTypeName:              "LBCookieStickinessPolicyType",
+			PolicyAttributeDescriptions: []elb.PolicyAttribute{{AttributeName: "CookieExpirationPeriod", AttributeValue: "60"}},
+		},
+		{
+			PolicyName:                  "appsticky",
+			PolicyTypeName:              "AppCookieStickinessPolicyType",
+			PolicyAttributeDescriptions: []elb.PolicyAttribute{{AttributeName: "CookieName", AttributeValue: "JSESSIONID"}},
+		},
+		{
+			PolicyName:                  "proxy",
+			PolicyTypeName:              "ProxyProtocolPolicyType",
+			PolicyAttributeDescriptions: proxy,
+		},
+	}
+	c.Assert(resp.PolicyDescriptions, DeepEquals, expected)
+	resp, err = s.clientTests.elb.DescribeLoadBalancerPolicies("policylb", "proxy")
+	c.Assert(err, IsNil)
+	c.Assert(resp.PolicyDescriptions, DeepEquals, expected[2:])
+}
+
+func (s *LocalServerSuite) TestDescribeLoadBalancerPoliciesErrors(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("policylb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("policylb")
+	_, err = s.clientTests.elb.DescribeLoadBalancerPolicies("policylb", "unknown")
+	c.Assert(err, ErrorMatches, `^There is no policy named unknown \(PolicyNotFound\)$`)
+	_, err = s.clientTests.elb.DescribeLoadBalancerPolicies("absentlb")
+	c.Assert(err, ErrorMatches, `.*\(LoadBalancerNotFound\)$`)
+}
+
+func (s *LocalServerSuite) TestDescribeSampleLoadBalancerPolicies(c *C) {
+	resp, err := s.clientTests.elb.DescribeLoadBalancerPolicies("")
+	c.Assert(err, IsNil)
+	c.Assert(len(resp.PolicyDescriptions) > 0, Equals, true)
+	for _, p := range resp.PolicyDescriptions {
+		c.Assert(p.PolicyTypeName, Equals, "SSLNegotiationPolicyType")
+	}
+	resp, err = s.clientTests.elb.DescribeLoadBalancerPolicies("", "ELBSecurityPolicy-2016-08")
+	c.Assert(err, IsNil)
+	c.Assert(resp.PolicyDescriptions, HasLen, 1)
+	c.Assert(resp.PolicyDescriptions[0].PolicyName, Equals, "ELBSecurityPolicy-2016-08")
+}
+
+func (s *LocalServerSuite) TestDescribeLoadBalancerPolicyTypes(c *C) {
+	resp, err := s.clientTests.elb.DescribeLoadBalancerPolicyTypes()
+	c.Assert(err, IsNil)
+	names := map[string]bool{}
+	for _, t := range resp.PolicyTypeDescriptions {
+		names[t.PolicyTypeName] = true
+	}
+	for _, name := range []string{"ProxyProtocolPolicyType", "SSLNegotiationPolicyType", "AppCookieStickinessPolicyType", "LBCookieStickinessPolicyType"} {
+		c.Check(names[name], Equals, true, Commentf("missing %s", name))
+	}
+	resp, err = s.clientTests.elb.DescribeLoadBalancerPolicyTypes("LBCookieStickinessPolicyType", "ProxyProtocolPolicyType")
+	c.Assert(err, IsNil)
+	c.Assert(resp.PolicyTypeDescriptions, HasLen, 2)
+	c.Assert(resp.PolicyTypeDescriptions[0].PolicyTypeName, Equals, "LBCookieStickinessPolicyType")
+	c.Assert(resp.PolicyTypeDescriptions[0].PolicyAttributeTypeDescriptions[0].AttributeName, Equals, "CookieExpirationPeriod")
+	c.Assert(resp.PolicyTypeDescriptions[1].PolicyTypeName, Equals, "ProxyProtocolPolicyType")
+	_, err = s.clientTests.elb.DescribeLoadBalancerPolicyTypes("UnknownPolicyType")
+	c.Assert(err, ErrorMatches, `^Policy type UnknownPolicyType does not exist \(PolicyTypeNotFound\)$`)
+}
+
+func (s *LocalServerSuite) TestAddTags(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("taglb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("taglb")
+	_, err = s.clientTests.elb.CreateLoadBalancer(createLBRequest("otherlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("otherlb")
+	_, err = s.clientTests.elb.AddTags([]string{"taglb", "otherlb"}, []elb.Tag{{Key: "project", Value: "lima"}})
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.AddTags([]string{"taglb"}, []elb.Tag{{Key: "env"}, {Key: "project", Value: "tango"}})
+	c.Assert(err, IsNil)
+	state := s.srv.srv.Snapshot()
+	c.Assert(state.Tags["taglb"], DeepEquals, []elb.Tag{{Key: "project", Value: "tango"}, {Key: "env"}})
+	c.Assert(state.Tags["otherlb"], DeepEquals, []elb.Tag{{Key: "project", Value: "lima"}})
+}
+
+func (s *LocalServerSuite) TestAddTooManyTags(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("taglb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("taglb")
+	var tags []elb.Tag
+	for i := 0; i < 10; i++ {
+		tags = append(tags, elb.Tag{Key: fmt.Sprintf("key%d", i)})
+	}
+	_, err = s.clientTests.elb.AddTags([]string{"taglb"}, tags)
+	c.Assert(err, IsNil)
+	// replacing a tag doesn't count against the limit
+	_, err = s.clientTests.elb.AddTags([]string{"taglb"}, []elb.Tag{{Key: "key0", Value: "value"}})
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.AddTags([]string{"taglb"}, []elb.Tag{{Key: "key10"}})
+	c.Assert(err, NotNil)
+	e, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.StatusCode, Equals, 400)
+	c.Assert(e.Code, Equals, "TooManyTags")
+	c.Assert(e.Message, Equals, "Load Balancer taglb can't have more than 10 tags")
+	c.Assert(s.srv.srv.Snapshot().Tags["taglb"], HasLen, 10)
+}
+
+func (s *LocalServerSuite) TestAddTagsErrors(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("taglb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("taglb")
+	_, err = s.clientTests.elb.AddTags([]string{"taglb"}, []elb.Tag{{Key: "env"}, {Key: "env", Value: "prod"}})
+	c.Assert(err, ErrorMatches, `^Tag key env is given more than once \(DuplicateTagKeys\)$`)
+	_, err = s.clientTests.elb.AddTags([]string{"taglb", "absentlb"}, []elb.Tag{{Key: "env"}})
+	c.Assert(err, ErrorMatches, `.*\(LoadBalancerNotFound\)$`)
+	_, err = s.clientTests.elb.AddTags([]string{"taglb"}, nil)
+	c.Assert(err, ErrorMatches, `^Tags.member.1.Key is required. \(ValidationError\)$`)
+	_, ok := s.srv.srv.Snapshot().Tags["taglb"]
+	c.Assert(ok, Equals, false)
+}
+
+func (s *LocalServerSuite) TestRemoveTags(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("taglb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("taglb")
+	_, err = s.clientTests.elb.CreateLoadBalancer(createLBRequest("otherlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("otherlb")
+	tags := []elb.Tag{{Key: "project", Value: "lima"}, {Key: "env", Value: "prod"}}
+	_, err = s.clientTests.elb.AddTags([]string{"taglb", "otherlb"}, tags)
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.RemoveTags([]string{"taglb", "otherlb"}, []string{"project", "unknown"})
+	c.Assert(err, IsNil)
+	state := s.srv.srv.Snapshot()
+	c.Assert(state.Tags["taglb"], DeepEquals, []elb.Tag{{Key: "env", Value: "prod"}})
+	c.Assert(state.Tags["otherlb"], DeepEquals, []elb.Tag{{Key: "env", Value: "prod"}})
+	_, err = s.clientTests.elb.RemoveTags([]string{"taglb"}, []string{"env"})
+	c.Assert(err, IsNil)
+	state = s.srv.srv.Snapshot()
+	_, ok := state.Tags["taglb"]
+	c.Assert(ok, Equals, false)
+	c.Assert(state.Tags["otherlb"], HasLen, 1)
+	_, err = s.clientTests.elb.RemoveTags([]string{"otherlb", "absentlb"}, []string{"env"})
+	c.Assert(err, ErrorMatches, `.*\(LoadBalancerNotFound\)$`)
+	c.Assert(s.srv.srv.Snapshot().Tags["otherlb"], HasLen, 1)
+}
+
+func (s *LocalServerSuite) TestDescribeTags(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("taglb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("taglb")
+	_, err = s.clientTests.elb.CreateLoadBalancer(createLBRequest("otherlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("otherlb")
+	tags := []elb.Tag{{Key: "project", Value: "lima"}, {Key: "env", Value: "prod"}}
+	_, err = s.clientTests.elb.AddTags([]string{"taglb"}, tags)
+	c.Assert(err, IsNil)
+	resp, err := s.clientTests.elb.DescribeTags("taglb", "otherlb")
+	c.Assert(err, IsNil)
+	expected := []elb.TagDescription{
+		{LoadBalancerName: "taglb", Tags: tags},
+		{LoadBalancerName: "otherlb"},
+	}
+	c.Assert(resp.TagDescriptions, DeepEquals, expected)
+	_, err = s.clientTests.elb.DescribeTags("taglb", "absentlb")
+	c.Assert(err, ErrorMatches, `.*\(LoadBalancerNotFound\)$`)
+}
+
+func (s *LocalServerSuite) TestDescribeTagsOfTooManyLoadBalancers(c *C) {
+	var names []string
+	for i := 0; i < 21; i++ {
+		name := fmt.Sprintf("taglb%d", i)
+		s.srv.srv.NewLoadBalancer(name)
+		defer s.srv.srv.RemoveLoadBalancer(name)
+		names = append(names, name)
+	}
+	resp, err := s.clientTests.elb.DescribeTags(names[:20]...)
+	c.Assert(err, IsNil)
+	c.Assert(resp.TagDescriptions, HasLen, 20)
+	_, err = s.clientTests.elb.DescribeTags(names...)
+	c.Assert(err, NotNil)
+	e, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.StatusCode, Equals, 400)
+	c.Assert(e.Code, Equals, "ValidationError")
+	c.Assert(e.Message, Equals, "The tags of at most 20 Load Balancers can be described at once, got 21")
+}
+
+func (s *LocalServerSuite) TestTagsAreRemovedWithTheLoadBalancer(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("taglb"))
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.AddTags([]string{"taglb"}, []elb.Tag{{Key: "env", Value: "prod"}})
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.DeleteLoadBalancer("taglb")
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.CreateLoadBalancer(createLBRequest("taglb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("taglb")
+	resp, err := s.clientTests.elb.DescribeTags("taglb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.TagDescriptions, DeepEquals, []elb.TagDescription{{LoadBalancerName: "taglb"}})
+}
+
+func (s *LocalServerSuite) TestDescribeAccountLimits(c *C) {
+	resp, err := s.clientTests.elb.DescribeAccountLimits()
+	c.Assert(err, IsNil)
+	expected := []elb.Limit{
+		{Name: "classic-listeners", Max: "100"},
+		{Name: "classic-load-balancers", Max: "20"},
+		{Name: "classic-registered-instances", Max: "1000"},
+	}
+	c.Assert(resp.Limits, DeepEquals, expected)
+}
+
+func (s *LocalServerSuite) TestSetAccountLimit(c *C) {
+	srv := s.srv.srv
+	srv.SetAccountLimit("classic-load-balancers", 50)
+	defer srv.SetAccountLimit("classic-load-balancers", 20)
+	srv.SetAccountLimit("classic-listeners", 1)
+	defer srv.SetAccountLimit("classic-listeners", 100)
+	resp, err := s.clientTests.elb.DescribeAccountLimits()
+	c.Assert(err, IsNil)
+	expected := []elb.Limit{
+		{Name: "classic-listeners", Max: "1"},
+		{Name: "classic-load-balancers", Max: "50"},
+		{Name: "classic-registered-instances", Max: "1000"},
+	}
+	c.Assert(resp.Limits, DeepEquals, expected)
+	srv.SetStrict(true)
+	defer srv.SetStrict(false)
+	createLB := createLBRequest("limitlb")
+	createLB.Listeners = append(createLB.Listeners, elb.Listener{
+		InstancePort:     8080,
+		InstanceProtocol: "HTTP",
+		LoadBalancerPort: 8080,
+		Protocol:         "HTTP",
+	})
+	_, err = s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, ErrorMatches, `.*\(InvalidConfigurationRequest\)$`)
+}
+
+func (s *LocalServerSuite) TestPoliciesAreRemovedWithTheLoadBalancer(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("policylb"))
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.CreateLBCookieStickinessPolicy("policylb", "sticky", 60)
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.DeleteLoadBalancer("policylb")
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.CreateLoadBalancer(createLBRequest("policylb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("policylb")
+	_, err = s.clientTests.elb.CreateLBCookieStickinessPolicy("policylb", "sticky", 60)
+	c.Assert(err, IsNil)
+}
+
+func (s *LocalServerSuite) TestAddLoadBalancerWithPolicies(c *C) {
+	srv := s.srv.srv
+	err := srv.AddLoadBalancer(elb.LoadBalancerDescription{
+		LoadBalancerName: "policylb",
+		Policies: elb.Policies{
+			LBCookieStickinessPolicies: []elb.LBCookieStickinessPolicies{{CookieExpirationPeriod: 30, PolicyName: "sticky"}},
+		},
+	})
+	c.Assert(err, IsNil)
+	defer srv.RemoveLoadBalancer("policylb")
+	_, err = s.clientTests.elb.CreateLBCookieStickinessPolicy("policylb", "sticky", 60)
+	c.Assert(err, ErrorMatches, ".*DuplicatePolicyName.*")
+	_, err = s.clientTests.elb.CreateLBCookieStickinessPolicy("policylb", "other", 60)
+	c.Assert(err, IsNil)
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("policylb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].Policies.LBCookieStickinessPolicies, DeepEquals, []elb.LBCookieStickinessPolicies{
+		{CookieExpirationPeriod: 30, PolicyName: "sticky"},
+		{CookieExpirationPeriod: 60, PolicyName: "other"},
+	})
+}
+
+func (s *LocalServerSuite) TestUpdateListenerInstanceProtocol(c *C) {
+	createLB := createLBRequest("testlb")
+	createLB.Listeners[0].Protocol = "https"
+	createLB.Listeners[0].LoadBalancerPort = 443
+	createLB.Listeners[0].SSLCertificateId = "arn:aws:iam::123456789012:server-certificate/mycert"
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	err = s.clientTests.elb.UpdateListenerInstanceProtocol("testlb", 443, "HTTPS", 8443)
+	c.Assert(err, IsNil)
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("testlb")
+	c.Assert(err, IsNil)
+	lds := resp.LoadBalancerDescriptions[0].ListenerDescriptions
+	c.Assert(lds, HasLen, 1)
+	expected := elb.Listener{
+		InstancePort:     8443,
+		InstanceProtocol: "HTTPS",
+		LoadBalancerPort: 443,
+		Protocol:         "HTTPS",
+		SSLCertificateId: "arn:aws:iam::123456789012:server-certificate/mycert",
+	}
+	c.Assert(lds[0].Listener, DeepEquals, expected)
+}
+
+func (s *LocalServerSuite) TestUpdateListenerInstanceProtocolWithoutListenerOnPort(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	err = s.clientTests.elb.UpdateListenerInstanceProtocol("testlb", 443, "HTTP", 80)
+	c.Assert(err, ErrorMatches, `^Load Balancer testlb has no listener on port 443 \(ListenerNotFound\)$`)
+}
+
+func (s *LocalServerSuite) TestCreateLoadBalancerWithListenersOnTheSamePort(c *C) {
+	createLB := createLBRequest("testlb")
+	createLB.Listeners = append(createLB.Listeners, elb.Listener{
+		InstancePort:     8080,
+		InstanceProtocol: "http",
+		LoadBalancerPort: 80,
+		Protocol:         "http",
+	})
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, NotNil)
+	e, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.StatusCode, Equals, 400)
+	c.Assert(e.Code, Equals, "DuplicateListener")
+	_, err = s.clientTests.elb.DescribeLoadBalancers("testlb")
+	c.Assert(err, NotNil)
+}
+
+func (s *LocalServerSuite) TestCreateLoadBalancerListenersOnAPortInUse(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	listener := elb.Listener{
+		InstancePort:     8080,
+		InstanceProtocol: "HTTP",
+		LoadBalancerPort: 80,
+		Protocol:         "HTTP",
+	}
+	_, err = s.clientTests.elb.CreateLoadBalancerListeners("testlb", []elb.Listener{listener})
+	c.Assert(err, NotNil)
+	e, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.StatusCode, Equals, 400)
+	c.Assert(e.Code, Equals, "DuplicateListener")
+	c.Assert(e.Message, Equals, "A listener already exists for LoadBalancerPort 80, but with a different InstancePort, Protocol, or SSLCertificateId")
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("testlb")
+	c.Assert(err, IsNil)
+	lds := resp.LoadBalancerDescriptions[0].ListenerDescriptions
+	c.Assert(lds, HasLen, 1)
+	c.Assert(lds[0].Listener.InstancePort, Equals, 80)
+}
+
+func (s *LocalServerSuite) TestCreateLoadBalancerListenersIdenticalToAnExistingOne(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	listener := elb.Listener{
+		InstancePort:     80,
+		InstanceProtocol: "HTTP",
+		LoadBalancerPort: 80,
+		Protocol:         "HTTP",
+	}
+	_, err = s.clientTests.elb.CreateLoadBalancerListeners("testlb", []elb.Listener{listener})
+	c.Assert(err, IsNil)
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("testlb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].ListenerDescriptions, HasLen, 1)
+}
+
+func (s *LocalServerSuite) TestCreateLoadBalancerListenersWithIncompatibleProtocols(c *C) {
+	s.srv.srv.SetStrict(true)
+	defer s.srv.srv.SetStrict(false)
+	s.srv.srv.NewCertificate("arn:aws:iam::123456789012:server-certificate/mycert")
+	defer s.srv.srv.RemoveCertificate("arn:aws:iam::123456789012:server-certificate/mycert")
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	listeners := []elb.Listener{
+		{
+			InstancePort:     443,
+			InstanceProtocol: "TCP",
+			LoadBalancerPort: 443,
+			Protocol:         "HTTPS",
+			SSLCertificateId: "arn:aws:iam::123456789012:server-certificate/mycert",
+		},
+		{
+			InstancePort:     8443,
+			InstanceProtocol: "HTTP",
+			LoadBalancerPort: 8443,
+			Protocol:         "SSL",
+			SSLCertificateId: "arn:aws:iam::123456789012:server-certificate/mycert",
+		},
+		{
+			InstancePort:     8080,
+			InstanceProtocol: "SSL",
+			LoadBalancerPort: 8080,
+			Protocol:         "HTTP",
+		},
+	}
+	for _, l := range listeners {
+		_, err = s.clientTests.elb.CreateLoadBalancerListeners("testlb", []elb.Listener{l})
+		c.Assert(err, NotNil)
+		e, ok := err.(*elb.Error)
+		c.Assert(ok, Equals, true)
+		c.Assert(e.StatusCode, Equals, 400)
+		c.Assert(e.Code, Equals, "ValidationError")
+		msg := fmt.Sprintf("Listener on port %d using %s can't forward to instance port %d using %s", l.LoadBalancerPort, l.Protocol, l.InstancePort, l.InstanceProtocol)
+		c.Assert(e.Message, Equals, msg)
+	}
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("testlb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].ListenerDescriptions, HasLen, 1)
+}
+
+func (s *LocalServerSuite) TestUpdateListenerInstanceProtocolRestoresListenerOnFailure(c *C) {
+	s.srv.srv.SetStrict(true)
+	defer s.srv.srv.SetStrict(false)
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	err = s.clientTests.elb.UpdateListenerInstanceProtocol("testlb", 80, "TCP", 8080)
+	c.Assert(err, NotNil)
+	e, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.Code, Equals, "ValidationError")
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("testlb")
+	c.Assert(err, IsNil)
+	lds := resp.LoadBalancerDescriptions[0].ListenerDescriptions
+	c.Assert(lds, HasLen, 1)
+	expected := elb.Listener{
+		InstancePort:     80,
+		InstanceProtocol: "HTTP",
+		LoadBalancerPort: 80,
+		Protocol:         "HTTP",
+	}
+	c.Assert(lds[0].Listener, DeepEquals, expected)
+}
+
+func (s *LocalServerSuite) TestDNSName(c *C) {
+	resp, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	dnsName, err := s.clientTests.elb.DNSName("testlb")
+	c.Assert(err, IsNil)
+	c.Assert(dnsName, Equals, resp.DNSName)
+}
+
+func (s *LocalServerSuite) TestDNSNameOfUnknownLoadBalancer(c *C) {
+	_, err := s.clientTests.elb.DNSName("unknown")
+	c.Assert(err, NotNil)
+	e, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.Code, Equals, "LoadBalancerNotFound")
+}
+
+func (s *LocalServerSuite) TestOperations(c *C) {
+	srv := s.srv.srv
+	srv.ClearOperations()
+	defer srv.ClearOperations()
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	_, err = s.clientTests.elb.DescribeLoadBalancers("unknown")
+	c.Assert(err, NotNil)
+	ops := srv.Operations()
+	c.Assert(ops, HasLen, 2)
+	c.Assert(ops[0].Action, Equals, "CreateLoadBalancer")
+	c.Assert(ops[0].Params.Get("LoadBalancerName"), Equals, "testlb")
+	c.Assert(ops[0].Response, DeepEquals, elb.CreateLoadBalancerResp{DNSName: "testlb-some-aws-stuff.us-east-1.elb.amazonaws.com"})
+	c.Assert(ops[0].Err, IsNil)
+	c.Assert(ops[1].Action, Equals, "DescribeLoadBalancers")
+	c.Assert(ops[1].Params.Get("LoadBalancerNames.member.1"), Equals, "unknown")
+	c.Assert(ops[1].Response, IsNil)
+	c.Assert(ops[1].Err.Code, Equals, "LoadBalancerNotFound")
+	c.Assert(ops[0].RequestId, Not(Equals), ops[1].RequestId)
+	srv.ClearOperations()
+	c.Assert(srv.Operations(), HasLen, 0)
+}
+
+func (s *LocalServerSuite) TestOperationsRecordsPreparedErrors(c *C) {
+	srv := s.srv.srv
+	srv.ClearOperations()
+	defer srv.ClearOperations()
+	srv.PrepareError("DescribeLoadBalancers", &elb.Error{StatusCode: 500, Code: "InternalFailure", Message: "oops"})
+	_, err := s.clientTests.elb.DescribeLoadBalancers()
+	c.Assert(err, NotNil)
+	ops := srv.Operations()
+	c.Assert(ops, HasLen, 1)
+	c.Assert(ops[0].Action, Equals, "DescribeLoadBalancers")
+	c.Assert(ops[0].Err.Code, Equals, "InternalFailure")
+}
+
+func (s *LocalServerSuite) TestInjectError(c *C) {
+	throttling := &elb.Error{StatusCode: 400, Code: "Throttling", Message: "Rate exceeded"}
+	s.srv.srv.InjectError("CreateLoadBalancer", throttling, 2)
+	for i := 0; i < 2; i++ {
+		_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("testlb"))
+		_, ok := err.(*elb.ThrottleError)
+		c.Assert(ok, Equals, true)
+	}
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+}
+
+func (s *LocalServerSuite) TestInjectErrorZeroTimesRemovesTheError(c *C) {
+	s.srv.srv.InjectError("DescribeLoadBalancers", &elb.Error{StatusCode: 500, Code: "InternalFailure", Message: "oops"}, 3)
+	s.srv.srv.InjectError("DescribeLoadBalancers", nil, 0)
+	_, err := s.clientTests.elb.DescribeLoadBalancers()
+	c.Assert(err, IsNil)
+}
+
+func (s *LocalServerSuite) TestSetLatency(c *C) {
+	srv := s.srv.srv
+	srv.SetLatency("DescribeLoadBalancers", 200*time.Millisecond)
+	defer srv.SetLatency("DescribeLoadBalancers", 0)
+	start := time.Now()
+	_, err := s.clientTests.elb.DescribeLoadBalancers()
+	c.Assert(err, IsNil)
+	c.Assert(time.Since(start) >= 200*time.Millisecond, Equals, true)
+	start = time.Now()
+	_, err = s.clientTests.elb.DescribeAccountLimits()
+	c.Assert(err, IsNil)
+	c.Assert(time.Since(start) < 200*time.Millisecond, Equals, true)
+}
+
+func (s *LocalServerSuite) TestSetDefaultLatency(c *C) {
+	srv := s.srv.srv
+	srv.SetLatency("", 100*time.Millisecond)
+	defer srv.SetLatency("", 0)
+	srv.SetLatency("DescribeAccountLimits", 0)
+	defer srv.SetLatency("DescribeAccountLimits", 0)
+	start := time.Now()
+	_, err := s.clientTests.elb.DescribeLoadBalancers()
+	c.Assert(err, IsNil)
+	c.Assert(time.Since(start) >= 100*time.Millisecond, Equals, true)
+	start = time.Now()
+	_, err = s.clientTests.elb.DescribeAccountLimits()
+	c.Assert(err, IsNil)
+	c.Assert(time.Since(start) < 100*time.Millisecond, Equals, true)
+}
+
+func (s *LocalServerSuite) TestSetRateLimit(c *C) {
+	srv := s.srv.srv
+	clock := &fakeClock{now: time.Date(2014, 3, 1, 10, 0, 0, 0, time.UTC)}
+	srv.SetClock(clock)
+	defer srv.SetClock(nil)
+	srv.SetRateLimit("DescribeLoadBalancers", 2)
+	defer srv.SetRateLimit("DescribeLoadBalancers", 0)
+	for i := 0; i < 2; i++ {
+		_, err := s.clientTests.elb.DescribeLoadBalancers()
+		c.Assert(err, IsNil)
+	}
+	_, err := s.clientTests.elb.DescribeLoadBalancers()
+	throttled, ok := err.(*elb.ThrottleError)
+	c.Assert(ok, Equals, true)
+	c.Assert(throttled.RetryAfter, Equals, time.Second)
+	_, err = s.clientTests.elb.DescribeAccountLimits()
+	c.Assert(err, IsNil)
+	clock.Advance(time.Second)
+	_, err = s.clientTests.elb.DescribeLoadBalancers()
+	c.Assert(err, IsNil)
+}
+
+func (s *LocalServerSuite) TestSetDefaultRateLimit(c *C) {
+	srv := s.srv.srv
+	clock := &fakeClock{now: time.Date(2014, 3, 1, 10, 0, 0, 0, time.UTC)}
+	srv.SetClock(clock)
+	defer srv.SetClock(nil)
+	srv.SetRateLimit("", 1)
+	defer srv.SetRateLimit("", 0)
+	_, err := s.clientTests.elb.DescribeLoadBalancers()
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.DescribeAccountLimits()
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.DescribeAccountLimits()
+	_, ok := err.(*elb.ThrottleError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *LocalServerSuite) TestRequestHookVetoesRequests(c *C) {
+	srv := s.srv.srv
+	defer srv.ClearHooks()
+	srv.AddRequestHook(func(action string, req *http.Request) *elb.Error {
+		if action == "DeleteLoadBalancer" {
+			return &elb.Error{StatusCode: 400, Code: "AccessDenied", Message: "not allowed"}
+		}
+		return nil
+	})
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	defer srv.RemoveLoadBalancer("testlb")
+	_, err = s.clientTests.elb.DeleteLoadBalancer("testlb")
+	c.Assert(err, ErrorMatches, `not allowed \(AccessDenied\)`)
+	_, err = s.clientTests.elb.DescribeLoadBalancers("testlb")
+	c.Assert(err, IsNil)
+}
+
+func (s *LocalServerSuite) TestRequestHookChangesRequests(c *C) {
+	srv := s.srv.srv
+	defer srv.ClearHooks()
+	srv.AddRequestHook(func(action string, req *http.Request) *elb.Error {
+		if action == "CreateLoadBalancer" {
+			req.Form.Set("LoadBalancerName", "hookedlb")
+		}
+		return nil
+	})
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	defer srv.RemoveLoadBalancer("hookedlb")
+	_, err = s.clientTests.elb.DescribeLoadBalancers("hookedlb")
+	c.Assert(err, IsNil)
+}
+
+func (s *LocalServerSuite) TestResponseHook(c *C) {
+	srv := s.srv.srv
+	defer srv.ClearHooks()
+	var ops []elbtest.Operation
+	srv.AddResponseHook(func(op elbtest.Operation) {
+		// hooks may use the server, as they don't hold its lock.
+		srv.Operations()
+		ops = append(ops, op)
+	})
+	_, err := s.clientTests.elb.DescribeLoadBalancers("unknown")
+	c.Assert(err, NotNil)
+	c.Assert(ops, HasLen, 1)
+	c.Assert(ops[0].Action, Equals, "DescribeLoadBalancers")
+	c.Assert(ops[0].Err.Code, Equals, "LoadBalancerNotFound")
+}
+
+func (s *LocalServerSuite) TestReset(c *C) {
+	srv, err := elbtest.NewServer()
+	c.Assert(err, IsNil)
+	defer srv.Quit()
+	client := elb.NewForTesting(srv.URL())
+	_, err = client.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	_, err = client.AddTags([]string{"testlb"}, []elb.Tag{{Key: "env", Value: "test"}})
+	c.Assert(err, IsNil)
+	srv.NewInstance()
+	srv.PrepareError("DescribeLoadBalancers", &elb.Error{StatusCode: 500, Code: "InternalFailure", Message: "oops"})
+	srv.Reset()
+	state := srv.Snapshot()
+	c.Assert(state.LoadBalancers, HasLen, 0)
+	c.Assert(state.InstanceStates, HasLen, 0)
+	c.Assert(state.Instances, HasLen, 0)
+	c.Assert(state.Attributes, HasLen, 0)
+	c.Assert(state.Tags, HasLen, 0)
+	c.Assert(srv.Operations(), HasLen, 0)
+	resp, err := client.DescribeLoadBalancers()
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions, HasLen, 0)
+	c.Assert(srv.NewInstance(), Equals, "i-1")
+}
+
+func (s *LocalServerSuite) TestPrepareThrottling(c *C) {
+	s.srv.srv.PrepareThrottling("DescribeLoadBalancers", 2*time.Second)
+	_, err := s.clientTests.elb.DescribeLoadBalancers()
+	throttled, ok := err.(*elb.ThrottleError)
+	c.Assert(ok, Equals, true)
+	c.Assert(throttled.RetryAfter, Equals, 2*time.Second)
+	c.Assert(throttled.Err.StatusCode, Equals, 400)
+	_, err = s.clientTests.elb.DescribeLoadBalancers()
+	c.Assert(err, IsNil)
+}
+
+func (s *LocalServerSuite) TestPrepareThrottlingRoundsRetryAfterUp(c *C) {
+	for _, t := range []struct {
+		delay time.Duration
+		want  string
+	}{
+		{0, "1"},
+		{500 * time.Millisecond, "1"},
+		{1500 * time.Millisecond, "2"},
+	} {
+		s.srv.srv.PrepareThrottling("DescribeLoadBalancers", t.delay)
+		r, err := http.Get(s.srv.srv.URL() + "/?Action=DescribeLoadBalancers")
+		c.Assert(err, IsNil)
+		r.Body.Close()
+		c.Check(r.StatusCode, Equals, 400)
+		c.Check(r.Header.Get("Retry-After"), Equals, t.want)
+	}
+}
+
+func (s *LocalServerSuite) TestPrepareError(c *C) {
+	s.srv.srv.PrepareError("DescribeLoadBalancers", &elb.Error{
+		StatusCode: 500,
+		Code:       "InternalFailure",
+		Message:    "Something went wrong",
+	})
+	_, err := s.clientTests.elb.DescribeLoadBalancers()
+	e, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.StatusCode, Equals, 500)
+	c.Assert(e.Code, Equals, "InternalFailure")
+	_, err = s.clientTests.elb.DescribeLoadBalancers()
+	c.Assert(err, IsNil)
+}
+
+func (s *LocalServerSuite) TestSetMalformedResponse(c *C) {
+	srv := s.srv.srv
+	srv.SetMalformedResponse("DescribeLoadBalancers")
+	defer srv.ClearMalformedResponse("DescribeLoadBalancers")
+	s.clientTests.elb.CaptureRawResponses(true)
+	defer s.clientTests.elb.CaptureRawResponses(false)
+	resp, err := s.clientTests.elb.DescribeLoadBalancers()
+	c.Assert(resp, IsNil)
+	c.Assert(err, ErrorMatches, "cannot decode the DescribeLoadBalancers response: XML syntax error.*")
+	raw := string(s.clientTests.elb.LastRawResponse())
+	c.Assert(raw, Matches, "(?s)^<DescribeLoadBalancersResponse.*<DescribeLoadBalancersResult>.*")
+	_, err = s.clientTests.elb.DescribeLoadBalancers()
+	c.Assert(err, NotNil)
+}
+
+func (s *LocalServerSuite) TestSetMalformedResponseOverridesPreparedErrors(c *C) {
+	srv := s.srv.srv
+	srv.SetMalformedResponse("DescribeLoadBalancers")
+	srv.PrepareError("DescribeLoadBalancers", &elb.Error{StatusCode: 500, Code: "InternalFailure"})
+	_, err := s.clientTests.elb.DescribeLoadBalancers()
+	_, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, false)
+	srv.ClearMalformedResponse("DescribeLoadBalancers")
+	_, err = s.clientTests.elb.DescribeLoadBalancers()
+	c.Assert(err, FitsTypeOf, &elb.Error{})
+	_, err = s.clientTests.elb.DescribeLoadBalancers()
+	c.Assert(err, IsNil)
+}
+
+func (s *LocalServerSuite) TestSetMalformedResponseOnlyAffectsTheGivenAction(c *C) {
+	srv := s.srv.srv
+	srv.SetMalformedResponse("DescribeInstanceHealth")
+	defer srv.ClearMalformedResponse("DescribeInstanceHealth")
+	_, err := s.clientTests.elb.DescribeLoadBalancers()
+	c.Assert(err, IsNil)
+}
+
+func (s *LocalServerSuite) TestDescribeLoadBalancersWithUnknownName(c *C) {
+	_, err := s.clientTests.elb.DescribeLoadBalancers("unknownlb")
+	c.Assert(err, NotNil)
+	e, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.StatusCode, Equals, 400)
+	c.Assert(e.Code, Equals, "LoadBalancerNotFound")
+	c.Assert(e.Message, Equals, "Cannot find Load Balancer unknownlb")
+}
+
+func (s *LocalServerSuite) TestDescribeLoadBalancersWithKnownAndUnknownNames(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	_, err = s.clientTests.elb.DescribeLoadBalancers("testlb", "unknownlb")
+	c.Assert(err, ErrorMatches, `^Cannot find Load Balancer unknownlb \(LoadBalancerNotFound\)$`)
+}
+
+func (s *LocalServerSuite) TestDescribeLoadBalancersWithoutNamesAfterDeletingAll(c *C) {
+	srv, err := elbtest.NewServer()
+	c.Assert(err, IsNil)
+	defer srv.Quit()
+	client := elb.NewForTesting(srv.URL())
+	_, err = client.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	_, err = client.DeleteLoadBalancer("testlb")
+	c.Assert(err, IsNil)
+	resp, err := client.DescribeLoadBalancers()
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions, NotNil)
+	c.Assert(resp.LoadBalancerDescriptions, HasLen, 0)
+}
+
+func (s *LocalServerSuite) TestCreateLoadBalancerWithTooManyListeners(c *C) {
+	s.srv.srv.SetMaxListeners(1)
+	defer s.srv.srv.SetMaxListeners(100)
+	createLB := createLBRequest("testlb")
+	createLB.Listeners = append(createLB.Listeners, elb.Listener{
+		InstancePort:     8080,
+		InstanceProtocol: "http",
+		LoadBalancerPort: 8080,
+		Protocol:         "http",
+	})
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, NotNil)
+	e, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.StatusCode, Equals, 400)
+	c.Assert(e.Code, Equals, "InvalidConfigurationRequest")
+	c.Assert(e.Message, Equals, "A Load Balancer can have at most 1 listeners")
+}
+
+func (s *LocalServerSuite) TestCreateLoadBalancerListenersBeyondMaxListeners(c *C) {
+	s.srv.srv.SetMaxListeners(2)
+	defer s.srv.srv.SetMaxListeners(100)
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	listener := elb.Listener{
+		InstancePort:     8080,
+		InstanceProtocol: "HTTP",
+		LoadBalancerPort: 8080,
+		Protocol:         "HTTP",
+	}
+	_, err = s.clientTests.elb.CreateLoadBalancerListeners("testlb", []elb.Listener{listener})
+	c.Assert(err, IsNil)
+	listener.LoadBalancerPort = 8081
+	_, err = s.clientTests.elb.CreateLoadBalancerListeners("testlb", []elb.Listener{listener})
+	c.Assert(err, NotNil)
+	e, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.Code, Equals, "InvalidConfigurationRequest")
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("testlb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].ListenerDescriptions, HasLen, 2)
+}
+
+func (s *LocalServerSuite) TestErrorsHaveRequestId(c *C) {
+	_, err := s.clientTests.elb.DescribeLoadBalancers("unknownlb")
+	e, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.RequestId, Matches, "req[0-9A-F]+")
+	_, err = s.clientTests.elb.DescribeLoadBalancers("unknownlb")
+	other, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(other.RequestId, Not(Equals), e.RequestId)
+}
+
+func (s *LocalServerSuite) TestResponseEnvelope(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	var tests = []struct {
+		params url.Values
+		body   string
+	}{
+		{
+			url.Values{"Action": {"DescribeLoadBalancerAttributes"}, "LoadBalancerName": {"testlb"}},
+			`<DescribeLoadBalancerAttributesResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/"><DescribeLoadBalancerAttributesResult><LoadBalancerAttributes>.*</LoadBalancerAttributes></DescribeLoadBalancerAttributesResult><ResponseMetadata><RequestId>req[0-9A-F]+</RequestId></ResponseMetadata></DescribeLoadBalancerAttributesResponse>`,
+		},
+		{
+			url.Values{"Action": {"RemoveTags"}, "LoadBalancerNames.member.1": {"testlb"}, "Tags.member.1.Key": {"env"}},
+			`<RemoveTagsResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/"><RemoveTagsResult></RemoveTagsResult><ResponseMetadata><RequestId>req[0-9A-F]+</RequestId></ResponseMetadata></RemoveTagsResponse>`,
+		},
+	}
+	for _, t := range tests {
+		r, err := http.Get(s.srv.srv.URL() + "/?" + t.params.Encode())
+		c.Assert(err, IsNil)
+		body, err := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		c.Assert(err, IsNil)
+		c.Check(string(body), Matches, t.body)
+	}
+}
+
+func (s *LocalServerSuite) TestUnrecognizedAction(c *C) {
+	r, err := http.Get(s.srv.srv.URL() + "/?Action=DoSomething")
+	c.Assert(err, IsNil)
+	defer r.Body.Close()
+	c.Assert(r.StatusCode, Equals, 400)
+	body, err := ioutil.ReadAll(r.Body)
+	c.Assert(err, IsNil)
+	c.Assert(string(body), Matches, "<ErrorResponse xmlns=\"http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/\"><Error><Type>Sender</Type><Code>InvalidParameterValue</Code><Message>Unrecognized Action</Message></Error><RequestId>req[0-9A-F]+</RequestId></ErrorResponse>")
+}
+
+func (s *LocalServerSuite) TestLoadBalancerHandle(c *C) {
+	lb := s.clientTests.elb.LoadBalancer("handlelb")
+	_, err := lb.Create(*createLBRequest("othername"))
+	c.Assert(err, IsNil)
+	defer lb.Delete()
+	id := s.srv.srv.NewInstance()
+	defer s.srv.srv.RemoveInstance(id)
+	_, err = lb.RegisterInstances(id)
+	c.Assert(err, IsNil)
+	healthCheck := elb.HealthCheck{
+		HealthyThreshold:   10,
+		Interval:           30,
+		Target:             "HTTP:80/health",
+		Timeout:            5,
+		UnhealthyThreshold: 2,
+	}
+	_, err = lb.ConfigureHealthCheck(&healthCheck)
+	c.Assert(err, IsNil)
+	desc, err := lb.Describe()
+	c.Assert(err, IsNil)
+	c.Assert(desc.LoadBalancerName, Equals, "handlelb")
+	c.Assert(desc.HealthCheck, DeepEquals, healthCheck)
+	c.Assert(desc.Instances, DeepEquals, []elb.Instance{{InstanceId: id}})
+	health, err := lb.InstanceHealth()
+	c.Assert(err, IsNil)
+	c.Assert(health.InstanceStates, HasLen, 1)
+	_, err = lb.DeregisterInstances(id)
+	c.Assert(err, IsNil)
+	_, err = lb.Delete()
+	c.Assert(err, IsNil)
+	_, err = lb.Describe()
+	c.Assert(err, ErrorMatches, ".*(LoadBalancerNotFound).*")
+	_, err = s.clientTests.elb.DescribeLoadBalancers("othername")
+	c.Assert(err, NotNil)
+}
+
+func (s *LocalServerSuite) TestDescribeLoadBalancerAttributesDefaults(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	resp, err := s.clientTests.elb.DescribeLoadBalancerAttributes("testlb")
+	c.Assert(err, IsNil)
+	expected := elb.LoadBalancerAttributes{
+		AccessLog:              &elb.AccessLog{Enabled: false},
+		ConnectionDraining:     &elb.ConnectionDraining{Enabled: false, Timeout: 300},
+		ConnectionSettings:     &elb.ConnectionSettings{IdleTimeout: 60},
+		CrossZoneLoadBalancing: &elb.CrossZoneLoadBalancing{Enabled: false},
+	}
+	c.Assert(resp.LoadBalancerAttributes, DeepEquals, expected)
+}
+
+func (s *LocalServerSuite) TestModifyLoadBalancerAttributes(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	attrs := elb.LoadBalancerAttributes{
+		AccessLog: &elb.AccessLog{
+			Enabled:        true,
+			S3BucketName:   "my-loadbalancer-logs",
+			S3BucketPrefix: "my-app/prod",
+			EmitInterval:   5,
+		},
+		ConnectionDraining: &elb.ConnectionDraining{Enabled: true},
+		ConnectionSettings: &elb.ConnectionSettings{IdleTimeout: 120},
+	}
+	resp, err := s.clientTests.elb.ModifyLoadBalancerAttributes("testlb", &attrs)
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerName, Equals, "testlb")
+	c.Assert(resp.LoadBalancerAttributes.AccessLog, DeepEquals, attrs.AccessLog)
+	c.Assert(resp.LoadBalancerAttributes.CrossZoneLoadBalancing, IsNil)
+	describeResp, err := s.clientTests.elb.DescribeLoadBalancerAttributes("testlb")
+	c.Assert(err, IsNil)
+	expected := elb.LoadBalancerAttributes{
+		AccessLog:              attrs.AccessLog,
+		ConnectionDraining:     &elb.ConnectionDraining{Enabled: true, Timeout: 300},
+		ConnectionSettings:     &elb.ConnectionSettings{IdleTimeout: 120},
+		CrossZoneLoadBalancing: &elb.CrossZoneLoadBalancing{Enabled: false},
+	}
+	c.Assert(describeResp.LoadBalancerAttributes, DeepEquals, expected)
+}
+
+func (s *LocalServerSuite) TestModifyAccessLog(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	attrs := elb.LoadBalancerAttributes{
+		AccessLog: &elb.AccessLog{Enabled: true, S3BucketName: "my-loadbalancer-logs"},
+	}
+	resp, err := s.clientTests.elb.ModifyLoadBalancerAttributes("testlb", &attrs)
+	c.Assert(err, IsNil)
+	expected := &elb.AccessLog{Enabled: true, S3BucketName: "my-loadbalancer-logs", EmitInterval: 60}
+	c.Assert(resp.LoadBalancerAttributes.AccessLog, DeepEquals, expected)
+	for _, interval := range []int{5, 60} {
+		attrs.AccessLog = &elb.AccessLog{
+			Enabled:        true,
+			S3BucketName:   "my-loadbalancer-logs",
+			S3BucketPrefix: "prod",
+			EmitInterval:   interval,
+		}
+		_, err = s.clientTests.elb.ModifyLoadBalancerAttributes("testlb", &attrs)
+		c.Assert(err, IsNil)
+		describeResp, err := s.clientTests.elb.DescribeLoadBalancerAttributes("testlb")
+		c.Assert(err, IsNil)
+		c.Assert(describeResp.LoadBalancerAttributes.AccessLog, DeepEquals, attrs.AccessLog)
+	}
+	attrs.AccessLog = &elb.AccessLog{Enabled: false}
+	_, err = s.clientTests.elb.ModifyLoadBalancerAttributes("testlb", &attrs)
+	c.Assert(err, IsNil)
+	describeResp, err := s.clientTests.elb.DescribeLoadBalancerAttributes("testlb")
+	c.Assert(err, IsNil)
+	c.Assert(describeResp.LoadBalancerAttributes.AccessLog, DeepEquals, &elb.AccessLog{Enabled: false})
+}
+
+func (s *LocalServerSuite) TestModifyIdleTimeout(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	for _, timeout := range []int{1, 3600, 45} {
+		attrs := elb.LoadBalancerAttributes{
+			ConnectionSettings: &elb.ConnectionSettings{IdleTimeout: timeout},
+		}
+		resp, err := s.clientTests.elb.ModifyLoadBalancerAttributes("testlb", &attrs)
+		c.Assert(err, IsNil)
+		c.Assert(resp.LoadBalancerAttributes.ConnectionSettings, DeepEquals, attrs.ConnectionSettings)
+		describeResp, err := s.clientTests.elb.DescribeLoadBalancerAttributes("testlb")
+		c.Assert(err, IsNil)
+		c.Assert(describeResp.LoadBalancerAttributes.ConnectionSettings, DeepEquals, attrs.ConnectionSettings)
+	}
+	_, err = s.clientTests.elb.CreateLoadBalancer(createLBRequest("otherlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("otherlb")
+	describeResp, err := s.clientTests.elb.DescribeLoadBalancerAttributes("otherlb")
+	c.Assert(err, IsNil)
+	c.Assert(describeResp.LoadBalancerAttributes.ConnectionSettings, DeepEquals, &elb.ConnectionSettings{IdleTimeout: 60})
+}
+
+func (s *LocalServerSuite) TestModifyCrossZoneLoadBalancing(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	settings := elb.LoadBalancerAttributes{ConnectionSettings: &elb.ConnectionSettings{IdleTimeout: 30}}
+	_, err = s.clientTests.elb.ModifyLoadBalancerAttributes("testlb", &settings)
+	c.Assert(err, IsNil)
+	for _, enabled := range []bool{true, false} {
+		attrs := elb.LoadBalancerAttributes{
+			CrossZoneLoadBalancing: &elb.CrossZoneLoadBalancing{Enabled: enabled},
+		}
+		resp, err := s.clientTests.elb.ModifyLoadBalancerAttributes("testlb", &attrs)
+		c.Assert(err, IsNil)
+		c.Assert(resp.LoadBalancerAttributes.CrossZoneLoadBalancing, DeepEquals, attrs.CrossZoneLoadBalancing)
+		describeResp, err := s.clientTests.elb.DescribeLoadBalancerAttributes("testlb")
+		c.Assert(err, IsNil)
+		c.Assert(describeResp.LoadBalancerAttributes.CrossZoneLoadBalancing, DeepEquals, attrs.CrossZoneLoadBalancing)
+		c.Assert(describeResp.LoadBalancerAttributes.ConnectionSettings, DeepEquals, settings.ConnectionSettings)
+	}
+}
+
+func (s *LocalServerSuite) TestLoadBalancerAttributesRoundTrip(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	attrs := elb.LoadBalancerAttributes{
+		AccessLog: &elb.AccessLog{
+			Enabled:        true,
+			S3BucketName:   "my-loadbalancer-logs",
+			S3BucketPrefix: "my-app/prod",
+			EmitInterval:   60,
+		},
+		ConnectionDraining:     &elb.ConnectionDraining{Enabled: true, Timeout: 60},
+		ConnectionSettings:     &elb.ConnectionSettings{IdleTimeout: 300},
+		CrossZoneLoadBalancing: &elb.CrossZoneLoadBalancing{Enabled: true},
+	}
+	_, err = s.clientTests.elb.ModifyLoadBalancerAttributes("testlb", &attrs)
+	c.Assert(err, IsNil)
+	resp, err := s.clientTests.elb.DescribeLoadBalancerAttributes("testlb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerAttributes, DeepEquals, attrs)
+	c.Assert(resp.RequestId, Not(Equals), "")
+	// the attributes returned by Describe can be applied back as they are
+	_, err = s.clientTests.elb.ModifyLoadBalancerAttributes("testlb", &resp.LoadBalancerAttributes)
+	c.Assert(err, IsNil)
+	again, err := s.clientTests.elb.DescribeLoadBalancerAttributes("testlb")
+	c.Assert(err, IsNil)
+	c.Assert(again.LoadBalancerAttributes, DeepEquals, attrs)
+}
+
+func (s *LocalServerSuite) TestDescribeLoadBalancerAttributesOfAbsentLoadBalancer(c *C) {
+	_, err := s.clientTests.elb.DescribeLoadBalancerAttributes("absentlb")
+	c.Assert(err, ErrorMatches, ".*(LoadBalancerNotFound).*")
+}
+
+func (s *LocalServerSuite) TestModifyLoadBalancerAttributesOfAbsentLoadBalancer(c *C) {
+	attrs := elb.LoadBalancerAttributes{
+		CrossZoneLoadBalancing: &elb.CrossZoneLoadBalancing{Enabled: true},
+	}
+	_, err := s.clientTests.elb.ModifyLoadBalancerAttributes("absentlb", &attrs)
+	c.Assert(err, ErrorMatches, ".*(LoadBalancerNotFound).*")
+}
+
+func (s *LocalServerSuite) TestModifyLoadBalancerAttributesValidation(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	tests := []struct {
+		attrs   elb.LoadBalancerAttributes
+		message string
+	}{
+		{
+			elb.LoadBalancerAttributes{},
+			"At least one attribute must be specified",
+		},
+		{
+			elb.LoadBalancerAttributes{AccessLog: &elb.AccessLog{Enabled: true}},
+			"S3BucketName is required when the access log is enabled",
+		},
+		{
+			elb.LoadBalancerAttributes{AccessLog: &elb.AccessLog{Enabled: true, S3BucketName: "logs", EmitInterval: 10}},
+			"EmitInterval must be 5 or 60 minutes, got 10",
+		},
+		{
+			elb.LoadBalancerAttributes{ConnectionDraining: &elb.ConnectionDraining{Enabled: true, Timeout: 3601}},
+			"ConnectionDraining Timeout must be between 1 and 3600 seconds, got 3601",
+		},
+		{
+			elb.LoadBalancerAttributes{ConnectionSettings: &elb.ConnectionSettings{IdleTimeout: 0}},
+			"ConnectionSettings IdleTimeout must be between 1 and 3600 seconds, got 0",
+		},
+		{
+			elb.LoadBalancerAttributes{ConnectionSettings: &elb.ConnectionSettings{IdleTimeout: 3601}},
+			"ConnectionSettings IdleTimeout must be between 1 and 3600 seconds, got 3601",
+		},
+	}
+	for _, t := range tests {
+		_, err := s.clientTests.elb.ModifyLoadBalancerAttributes("testlb", &t.attrs)
+		c.Assert(err, NotNil)
+		e, ok := err.(*elb.Error)
+		c.Assert(ok, Equals, true)
+		c.Assert(e.StatusCode, Equals, 400)
+		c.Assert(e.Code, Equals, "ValidationError")
+		c.Assert(e.Message, Equals, t.message)
+	}
+	resp, err := s.clientTests.elb.DescribeLoadBalancerAttributes("testlb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerAttributes.AccessLog, DeepEquals, &elb.AccessLog{Enabled: false})
+	c.Assert(resp.LoadBalancerAttributes.ConnectionSettings, DeepEquals, &elb.ConnectionSettings{IdleTimeout: 60})
+}
+
+func (s *LocalServerSuite) TestModifyLoadBalancerAttributesWithInvalidBoolean(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	params := url.Values{
+		"Action":           {"ModifyLoadBalancerAttributes"},
+		"LoadBalancerName": {"testlb"},
+		"LoadBalancerAttributes.CrossZoneLoadBalancing.Enabled": {"yes please"},
+	}
+	r, err := http.Get(s.srv.srv.URL() + "/?" + params.Encode())
+	c.Assert(err, IsNil)
+	defer r.Body.Close()
+	c.Assert(r.StatusCode, Equals, 400)
+	body, err := ioutil.ReadAll(r.Body)
+	c.Assert(err, IsNil)
+	c.Assert(string(body), Matches, ".*<Code>ValidationError</Code><Message>Invalid value &#39;yes please&#39; for LoadBalancerAttributes.CrossZoneLoadBalancing.Enabled</Message>.*")
+}
+
+func (s *LocalServerSuite) TestDeleteLoadBalancers(c *C) {
+	for _, name := range []string{"deletelb1", "deletelb2"} {
+		_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest(name))
+		c.Assert(err, IsNil)
+	}
+	err := s.clientTests.elb.DeleteLoadBalancers([]string{"deletelb1", "absentlb", "deletelb2"})
+	c.Assert(err, IsNil)
+	state := s.srv.srv.Snapshot()
+	_, ok := state.LoadBalancers["deletelb1"]
+	c.Assert(ok, Equals, false)
+	_, ok = state.LoadBalancers["deletelb2"]
+	c.Assert(ok, Equals, false)
+}
+
+func (s *LocalServerSuite) TestDeleteLoadBalancersContinuesOnFailure(c *C) {
+	for _, name := range []string{"deletelb1", "deletelb2"} {
+		_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest(name))
+		c.Assert(err, IsNil)
+	}
+	err := s.clientTests.elb.DeleteLoadBalancers([]string{"deletelb1", "", "absentlb", "deletelb2"})
+	c.Assert(err, NotNil)
+	e, ok := err.(elb.BatchError)
+	c.Assert(ok, Equals, true)
+	c.Assert(e, HasLen, 1)
+	c.Assert(e[""], NotNil)
+	state := s.srv.srv.Snapshot()
+	_, ok = state.LoadBalancers["deletelb1"]
+	c.Assert(ok, Equals, false)
+	_, ok = state.LoadBalancers["deletelb2"]
+	c.Assert(ok, Equals, false)
+}
+
+func (s *LocalServerSuite) TestDeleteAbsentLoadBalancer(c *C) {
+	resp, err := s.clientTests.elb.DeleteLoadBalancer("absentlb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.RequestId, Matches, "req[0-9A-F]+")
+	_, err = s.clientTests.elb.DeleteLoadBalancer("absentlb")
+	c.Assert(err, IsNil)
+}
+
+func (s *LocalServerSuite) TestDeleteLoadBalancerRemovesAllState(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("cleanlb"))
+	c.Assert(err, IsNil)
+	id := s.srv.srv.NewInstance()
+	defer s.srv.srv.RemoveInstance(id)
+	_, err = s.clientTests.elb.RegisterInstancesWithLoadBalancer([]string{id}, "cleanlb")
+	c.Assert(err, IsNil)
+	attrs := elb.LoadBalancerAttributes{
+		ConnectionSettings: &elb.ConnectionSettings{IdleTimeout: 120},
+	}
+	_, err = s.clientTests.elb.ModifyLoadBalancerAttributes("cleanlb", &attrs)
+	c.Assert(err, IsNil)
+	resp, err := s.clientTests.elb.DeleteLoadBalancer("cleanlb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.RequestId, Matches, "req[0-9A-F]+")
+	state := s.srv.srv.Snapshot()
+	_, ok := state.LoadBalancers["cleanlb"]
+	c.Assert(ok, Equals, false)
+	_, ok = state.InstanceStates["cleanlb"]
+	c.Assert(ok, Equals, false)
+	_, ok = state.Attributes["cleanlb"]
+	c.Assert(ok, Equals, false)
+	c.Assert(state.Instances, DeepEquals, []string{id})
+	_, err = s.clientTests.elb.CreateLoadBalancer(createLBRequest("cleanlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("cleanlb")
+	describeResp, err := s.clientTests.elb.DescribeLoadBalancers("cleanlb")
+	c.Assert(err, IsNil)
+	c.Assert(describeResp.LoadBalancerDescriptions[0].Instances, HasLen, 0)
+	attrsResp, err := s.clientTests.elb.DescribeLoadBalancerAttributes("cleanlb")
+	c.Assert(err, IsNil)
+	c.Assert(attrsResp.LoadBalancerAttributes.ConnectionSettings, DeepEquals, &elb.ConnectionSettings{IdleTimeout: 60})
+	healthResp, err := s.clientTests.elb.DescribeInstanceHealth("cleanlb")
+	c.Assert(err, IsNil)
+	c.Assert(healthResp.InstanceStates, HasLen, 0)
+}
+
+func (s *LocalServerSuite) TestCreateAndDescribe(c *C) {
+	lb, err := s.clientTests.elb.CreateAndDescribe(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	c.Assert(lb.LoadBalancerName, Equals, "testlb")
+	c.Assert(lb.DNSName, Equals, "testlb-some-aws-stuff.us-east-1.elb.amazonaws.com")
+	c.Assert(lb.CanonicalHostedZoneName, Equals, lb.DNSName)
+	c.Assert(lb.CanonicalHostedZoneNameId, Equals, "Z3DZXE0Q79N41H")
+	c.Assert(lb.ListenerDescriptions, HasLen, 1)
+}
+
+func (s *LocalServerSuite) TestDNSNameIsInTheRegionOfTheAvailabilityZones(c *C) {
+	createLB := createLBRequest("zonelb")
+	createLB.AvailZones = []string{"sa-east-1a"}
+	resp, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("zonelb")
+	c.Assert(resp.DNSName, Equals, "zonelb-some-aws-stuff.sa-east-1.elb.amazonaws.com")
+}
+
+func (s *LocalServerSuite) TestSetRegion(c *C) {
+	s.srv.srv.SetRegion("eu-west-1")
+	defer s.srv.srv.SetRegion("us-east-1")
+	createLB := createLBRequest("vpclb")
+	createLB.AvailZones = nil
+	createLB.Subnets = []string{"subnet-1"}
+	resp, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("vpclb")
+	c.Assert(resp.DNSName, Equals, "vpclb-some-aws-stuff.eu-west-1.elb.amazonaws.com")
+	s.srv.srv.NewLoadBalancer("fakelb")
+	defer s.srv.srv.RemoveLoadBalancer("fakelb")
+	dnsName, err := s.clientTests.elb.DNSName("fakelb")
+	c.Assert(err, IsNil)
+	c.Assert(dnsName, Equals, "fakelb-some-aws-stuff.eu-west-1.elb.amazonaws.com")
+}
+
+func (s *LocalServerSuite) TestCreateAndDescribeWaitsForConsistency(c *C) {
+	s.srv.srv.SetCreateConsistencyDelay(200 * time.Millisecond)
+	defer s.srv.srv.SetCreateConsistencyDelay(0)
+	start := time.Now()
+	lb, err := s.clientTests.elb.CreateAndDescribe(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	c.Assert(time.Since(start) >= 200*time.Millisecond, Equals, true)
+	c.Assert(lb.LoadBalancerName, Equals, "testlb")
+}
+
+func (s *LocalServerSuite) TestCreateAndDescribeWithInvalidName(c *C) {
+	_, err := s.clientTests.elb.CreateAndDescribe(createLBRequest("-testlb"))
+	c.Assert(err, NotNil)
+	e, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.Code, Equals, "ValidationError")
+}
+
+func (s *LocalServerSuite) TestCreateLoadBalancerInUnknownSubnet(c *C) {
+	s.srv.srv.SetStrict(true)
+	defer s.srv.srv.SetStrict(false)
+	createLB := createLBRequest("vpclb")
+	createLB.AvailZones = nil
+	createLB.Subnets = []string{"subnet-1", "subnet-unknown"}
+	s.srv.srv.NewSubnet("subnet-1")
+	defer s.srv.srv.RemoveSubnet("subnet-1")
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, NotNil)
+	e, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.StatusCode, Equals, 400)
+	c.Assert(e.Code, Equals, "InvalidSubnet")
+	c.Assert(e.Message, Equals, "Invalid subnet: subnet-unknown")
+	_, err = s.clientTests.elb.DescribeLoadBalancers("vpclb")
+	c.Assert(err, NotNil)
+}
+
+func (s *LocalServerSuite) TestCreateLoadBalancerInRemovedSubnet(c *C) {
+	s.srv.srv.SetStrict(true)
+	defer s.srv.srv.SetStrict(false)
+	createLB := createLBRequest("vpclb")
+	createLB.AvailZones = nil
+	createLB.Subnets = []string{"subnet-1"}
+	s.srv.srv.NewSubnet("subnet-1")
+	s.srv.srv.RemoveSubnet("subnet-1")
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, ErrorMatches, ".*(InvalidSubnet).*")
+}
+
+func (s *LocalServerSuite) TestEnableAvailabilityZonesForLoadBalancer(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("azlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("azlb")
+	resp, err := s.clientTests.elb.EnableAvailabilityZonesForLoadBalancer("azlb", []string{"us-east-1b", "us-east-1a"})
+	c.Assert(err, IsNil)
+	c.Assert(resp.AvailabilityZones, DeepEquals, []string{"us-east-1a", "us-east-1b"})
+	describeResp, err := s.clientTests.elb.DescribeLoadBalancers("azlb")
+	c.Assert(err, IsNil)
+	c.Assert(describeResp.LoadBalancerDescriptions[0].AvailZones, DeepEquals, []string{"us-east-1a", "us-east-1b"})
+}
+
+func (s *LocalServerSuite) TestEnableAvailabilityZonesForAbsentLoadBalancer(c *C) {
+	_, err := s.clientTests.elb.EnableAvailabilityZonesForLoadBalancer("absentlb", []string{"us-east-1b"})
+	e, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.Code, Equals, "LoadBalancerNotFound")
+}
+
+func (s *LocalServerSuite) TestDisableAvailabilityZonesForLoadBalancer(c *C) {
+	createLB := createLBRequest("azlb")
+	createLB.AvailZones = []string{"us-east-1a", "us-east-1b", "us-east-1c"}
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("azlb")
+	resp, err := s.clientTests.elb.DisableAvailabilityZonesForLoadBalancer("azlb", []string{"us-east-1a", "us-east-1d"})
+	c.Assert(err, IsNil)
+	c.Assert(resp.AvailabilityZones, DeepEquals, []string{"us-east-1b", "us-east-1c"})
+	describeResp, err := s.clientTests.elb.DescribeLoadBalancers("azlb")
+	c.Assert(err, IsNil)
+	c.Assert(describeResp.LoadBalancerDescriptions[0].AvailZones, DeepEquals, []string{"us-east-1b", "us-east-1c"})
+}
+
+func (s *LocalServerSuite) TestDisableTheLastAvailabilityZones(c *C) {
+	createLB := createLBRequest("azlb")
+	createLB.AvailZones = []string{"us-east-1a", "us-east-1b"}
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("azlb")
+	_, err = s.clientTests.elb.DisableAvailabilityZonesForLoadBalancer("azlb", []string{"us-east-1a", "us-east-1b"})
+	e, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.Code, Equals, "ValidationError")
+	c.Assert(e.Message, Equals, "Cannot remove all Availability Zones from a Load Balancer")
+	describeResp, err := s.clientTests.elb.DescribeLoadBalancers("azlb")
+	c.Assert(err, IsNil)
+	c.Assert(describeResp.LoadBalancerDescriptions[0].AvailZones, DeepEquals, []string{"us-east-1a", "us-east-1b"})
+}
+
+func (s *LocalServerSuite) TestAttachLoadBalancerToSubnets(c *C) {
+	createLB := createLBRequest("vpclb")
+	createLB.AvailZones = nil
+	createLB.Subnets = []string{"subnet-1"}
+	s.srv.srv.NewSubnet("subnet-1")
+	defer s.srv.srv.RemoveSubnet("subnet-1")
+	s.srv.srv.NewSubnet("subnet-2")
+	defer s.srv.srv.RemoveSubnet("subnet-2")
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("vpclb")
+	resp, err := s.clientTests.elb.AttachLoadBalancerToSubnets("vpclb", []string{"subnet-2", "subnet-1"})
+	c.Assert(err, IsNil)
+	c.Assert(resp.Subnets, DeepEquals, []string{"subnet-1", "subnet-2"})
+	describeResp, err := s.clientTests.elb.DescribeLoadBalancers("vpclb")
+	c.Assert(err, IsNil)
+	c.Assert(describeResp.LoadBalancerDescriptions[0].Subnets, DeepEquals, []string{"subnet-1", "subnet-2"})
+}
+
+func (s *LocalServerSuite) TestAttachLoadBalancerToUnknownSubnet(c *C) {
+	s.srv.srv.SetStrict(true)
+	defer s.srv.srv.SetStrict(false)
+	createLB := createLBRequest("vpclb")
+	createLB.AvailZones = nil
+	createLB.Subnets = []string{"subnet-1"}
+	s.srv.srv.NewSubnet("subnet-1")
+	defer s.srv.srv.RemoveSubnet("subnet-1")
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("vpclb")
+	_, err = s.clientTests.elb.AttachLoadBalancerToSubnets("vpclb", []string{"subnet-unknown"})
+	c.Assert(err, NotNil)
+	e, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.StatusCode, Equals, 400)
+	c.Assert(e.Code, Equals, "SubnetNotFound")
+	c.Assert(e.Message, Equals, "One or more subnets were not found: subnet-unknown")
+	describeResp, err := s.clientTests.elb.DescribeLoadBalancers("vpclb")
+	c.Assert(err, IsNil)
+	c.Assert(describeResp.LoadBalancerDescriptions[0].Subnets, DeepEquals, []string{"subnet-1"})
+}
+
+func (s *LocalServerSuite) TestAttachClassicLoadBalancerToSubnets(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("classiclb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("classiclb")
+	_, err = s.clientTests.elb.AttachLoadBalancerToSubnets("classiclb", []string{"subnet-1"})
+	c.Assert(err, ErrorMatches, `^Subnets are only available to load balancers in a VPC, and classiclb is not in one \(InvalidConfigurationRequest\)$`)
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("classiclb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].Subnets, HasLen, 0)
+	c.Assert(resp.LoadBalancerDescriptions[0].AvailZones, DeepEquals, []string{"us-east-1a"})
+}
+
+func (s *LocalServerSuite) TestDetachLoadBalancerFromSubnets(c *C) {
+	createLB := createLBRequest("vpclb")
+	createLB.AvailZones = nil
+	createLB.Subnets = []string{"subnet-1", "subnet-2", "subnet-3"}
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("vpclb")
+	resp, err := s.clientTests.elb.DetachLoadBalancerFromSubnets("vpclb", []string{"subnet-2", "subnet-unknown"})
+	c.Assert(err, IsNil)
+	c.Assert(resp.Subnets, DeepEquals, []string{"subnet-1", "subnet-3"})
+	describeResp, err := s.clientTests.elb.DescribeLoadBalancers("vpclb")
+	c.Assert(err, IsNil)
+	c.Assert(describeResp.LoadBalancerDescriptions[0].Subnets, DeepEquals, []string{"subnet-1", "subnet-3"})
+}
+
+func (s *LocalServerSuite) TestDetachLoadBalancerFromItsLastSubnet(c *C) {
+	createLB := createLBRequest("vpclb")
+	createLB.AvailZones = nil
+	createLB.Subnets = []string{"subnet-1", "subnet-2"}
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("vpclb")
+	_, err = s.clientTests.elb.DetachLoadBalancerFromSubnets("vpclb", []string{"subnet-1", "subnet-2"})
+	c.Assert(err, NotNil)
+	e, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.StatusCode, Equals, 400)
+	c.Assert(e.Code, Equals, "InvalidConfigurationRequest")
+	c.Assert(e.Message, Equals, "Load Balancer vpclb can't be detached from all its subnets")
+	describeResp, err := s.clientTests.elb.DescribeLoadBalancers("vpclb")
+	c.Assert(err, IsNil)
+	c.Assert(describeResp.LoadBalancerDescriptions[0].Subnets, DeepEquals, []string{"subnet-1", "subnet-2"})
+	_, err = s.clientTests.elb.DetachLoadBalancerFromSubnets("vpclb", []string{"subnet-1"})
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.DetachLoadBalancerFromSubnets("vpclb", []string{"subnet-2"})
+	c.Assert(err, ErrorMatches, `.*\(InvalidConfigurationRequest\)$`)
+}
+
+func (s *LocalServerSuite) TestCreateLoadBalancerAgainWithTheSameDefinition(c *C) {
+	srv := s.srv.srv
+	createLB := createLBRequest("duplb")
+	first, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("duplb")
+	inst := srv.NewInstance()
+	defer srv.RemoveInstance(inst)
+	_, err = s.clientTests.elb.RegisterInstancesWithLoadBalancer([]string{inst}, "duplb")
+	c.Assert(err, IsNil)
+	second, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, IsNil)
+	c.Assert(second.DNSName, Equals, first.DNSName)
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("duplb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].Instances, DeepEquals, []elb.Instance{{InstanceId: inst}})
+}
+
+func (s *LocalServerSuite) TestCreateLoadBalancerAgainWithADifferentDefinition(c *C) {
+	createLB := createLBRequest("duplb")
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("duplb")
+	createLB.AvailZones = []string{"us-east-1b"}
+	_, err = s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, NotNil)
+	e, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.StatusCode, Equals, 400)
+	c.Assert(e.Code, Equals, "DuplicateLoadBalancerName")
+	c.Assert(e.Message, Equals, "Load Balancer named duplb already exists and it is configured with different parameters.")
+	createLB = createLBRequest("duplb")
+	createLB.Listeners[0].InstancePort = 8080
+	_, err = s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, ErrorMatches, `.*\(DuplicateLoadBalancerName\)$`)
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("duplb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].AvailZones, DeepEquals, []string{"us-east-1a"})
+	c.Assert(resp.LoadBalancerDescriptions[0].ListenerDescriptions[0].Listener.InstancePort, Equals, 80)
+}
+
+func (s *LocalServerSuite) TestTooManyLoadBalancers(c *C) {
+	srv := s.srv.srv
+	srv.SetMaxLoadBalancers(2)
+	defer srv.SetMaxLoadBalancers(20)
+	for _, name := range []string{"quotalb1", "quotalb2"} {
+		_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest(name))
+		c.Assert(err, IsNil)
+		defer s.clientTests.elb.DeleteLoadBalancer(name)
+	}
+	// creating an existing load balancer again doesn't count
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("quotalb1"))
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.CreateLoadBalancer(createLBRequest("quotalb3"))
+	c.Assert(err, NotNil)
+	e, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.StatusCode, Equals, 400)
+	c.Assert(e.Code, Equals, "TooManyLoadBalancers")
+	c.Assert(e.Message, Equals, "Exceeded quota of account: at most 2 Load Balancers can be created")
+	_, err = s.clientTests.elb.DeleteLoadBalancer("quotalb2")
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.CreateLoadBalancer(createLBRequest("quotalb3"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("quotalb3")
+	limits, err := s.clientTests.elb.DescribeAccountLimits()
+	c.Assert(err, IsNil)
+	c.Assert(limits.Limits[1], DeepEquals, elb.Limit{Name: "classic-load-balancers", Max: "2"})
+}
+
+func (s *LocalServerSuite) TestCreateLoadBalancerWithInvalidProtocols(c *C) {
+	createLB := createLBRequest("protolb")
+	createLB.Listeners = append(createLB.Listeners, elb.Listener{
+		InstancePort:     21,
+		InstanceProtocol: "TCP",
+		LoadBalancerPort: 2121,
+		Protocol:         "ftp",
+	})
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, NotNil)
+	e, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.StatusCode, Equals, 400)
+	c.Assert(e.Code, Equals, "ValidationError")
+	c.Assert(e.Message, Equals, "Invalid value 'FTP' for Listeners.member.2.Protocol, it must be one of HTTP, HTTPS, TCP or SSL")
+	createLB = createLBRequest("protolb")
+	createLB.Listeners[0].InstanceProtocol = "UDP"
+	_, err = s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, ErrorMatches, `^Invalid value 'UDP' for Listeners.member.1.InstanceProtocol, it must be one of HTTP, HTTPS, TCP or SSL \(ValidationError\)$`)
+	_, err = s.clientTests.elb.DescribeLoadBalancers("protolb")
+	c.Assert(err, NotNil)
+}
+
+func (s *LocalServerSuite) TestCreateLoadBalancerListenersWithInvalidProtocol(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("protolb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("protolb")
+	listeners := []elb.Listener{{InstancePort: 53, InstanceProtocol: "TCP", LoadBalancerPort: 1053, Protocol: "UDP"}}
+	_, err = s.clientTests.elb.CreateLoadBalancerListeners("protolb", listeners)
+	c.Assert(err, ErrorMatches, `^Invalid value 'UDP' for Listeners.member.1.Protocol, it must be one of HTTP, HTTPS, TCP or SSL \(ValidationError\)$`)
+}
+
+func (s *LocalServerSuite) TestCreateLoadBalancerWithInvalidPorts(c *C) {
+	tests := []struct {
+		lbPort, instancePort int
+		message              string
+	}{
+		{22, 22, "Invalid value '22' for Listeners.member.1.LoadBalancerPort, it must be 25, 80, 443, 465, 587 or between 1024 and 65535"},
+		{1023, 80, "Invalid value '1023' for Listeners.member.1.LoadBalancerPort, it must be 25, 80, 443, 465, 587 or between 1024 and 65535"},
+		{65536, 80, "Invalid value '65536' for Listeners.member.1.LoadBalancerPort, it must be 25, 80, 443, 465, 587 or between 1024 and 65535"},
+		{80, 0, "Invalid value '0' for Listeners.member.1.InstancePort, it must be between 1 and 65535"},
+		{80, 65536, "Invalid value '65536' for Listeners.member.1.InstancePort, it must be between 1 and 65535"},
+	}
+	for _, t := range tests {
+		createLB := createLBRequest("portlb")
+		createLB.Listeners[0].LoadBalancerPort = t.lbPort
+		createLB.Listeners[0].InstancePort = t.instancePort
+		_, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+		c.Assert(err, NotNil)
+		e, ok := err.(*elb.Error)
+		c.Assert(ok, Equals, true)
+		c.Check(e.Code, Equals, "ValidationError")
+		c.Check(e.Message, Equals, t.message)
+	}
+	for _, port := range []int{25, 465, 587, 1024, 65535} {
+		createLB := createLBRequest("portlb")
+		createLB.Listeners[0].LoadBalancerPort = port
+		createLB.Listeners[0].InstancePort = 1
+		_, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+		c.Assert(err, IsNil)
+		_, err = s.clientTests.elb.DeleteLoadBalancer("portlb")
+		c.Assert(err, IsNil)
+	}
+}
+
+func (s *LocalServerSuite) TestCreateInternalLoadBalancer(c *C) {
+	createLB := createLBRequest("internallb")
+	createLB.AvailZones = nil
+	createLB.Subnets = []string{"subnet-1"}
+	createLB.Scheme = "internal"
+	createResp, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("internallb")
+	c.Assert(createResp.DNSName, Equals, "internal-internallb-some-aws-stuff.us-east-1.elb.amazonaws.com")
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("internallb")
+	c.Assert(err, IsNil)
+	lb := resp.LoadBalancerDescriptions[0]
+	c.Assert(lb.Scheme, Equals, "internal")
+	c.Assert(lb.DNSName, Equals, createResp.DNSName)
+	c.Assert(lb.CanonicalHostedZoneName, Equals, "")
+}
+
+func (s *LocalServerSuite) TestCreateInternalLoadBalancerOutsideVPC(c *C) {
+	createLB := createLBRequest("internallb")
+	createLB.Scheme = "internal"
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, ErrorMatches, `^The internal scheme is only available to load balancers in a VPC \(InvalidConfigurationRequest\)$`)
+	createLB.Scheme = "private"
+	_, err = s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, ErrorMatches, `^Invalid value 'private' for Scheme \(ValidationError\)$`)
+	_, err = s.clientTests.elb.DescribeLoadBalancers("internallb")
+	c.Assert(err, NotNil)
+}
+
+func (s *LocalServerSuite) createVPCLoadBalancer(c *C, name string, securityGroups ...string) {
+	createLB := createLBRequest(name)
+	createLB.AvailZones = nil
+	createLB.Subnets = []string{"subnet-sg"}
+	createLB.SecurityGroups = securityGroups
+	s.srv.srv.NewSubnet("subnet-sg")
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, IsNil)
+}
+
+func (s *LocalServerSuite) TestSecurityGroupsAreNotValidatedOutsideStrictMode(c *C) {
+	s.createVPCLoadBalancer(c, "vpclb", "sg-unregistered")
+	defer s.clientTests.elb.DeleteLoadBalancer("vpclb")
+	resp, err := s.clientTests.elb.ApplySecurityGroupsToLoadBalancer("vpclb", []string{"sg-other"})
+	c.Assert(err, IsNil)
+	c.Assert(resp.SecurityGroups, DeepEquals, []string{"sg-other"})
+	describeResp, err := s.clientTests.elb.DescribeLoadBalancers("vpclb")
+	c.Assert(err, IsNil)
+	c.Assert(describeResp.LoadBalancerDescriptions[0].SecurityGroups, DeepEquals, []string{"sg-other"})
+}
+
+func (s *LocalServerSuite) TestCreateLoadBalancerWithUnknownSecurityGroup(c *C) {
+	s.srv.srv.SetStrict(true)
+	defer s.srv.srv.SetStrict(false)
+	s.srv.srv.NewSecurityGroup("sg-1")
+	defer s.srv.srv.RemoveSecurityGroup("sg-1")
+	createLB := createLBRequest("vpclb")
+	createLB.AvailZones = nil
+	createLB.Subnets = []string{"subnet-sg"}
+	createLB.SecurityGroups = []string{"sg-1", "sg-unknown"}
+	s.srv.srv.NewSubnet("subnet-sg")
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, NotNil)
+	e, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.StatusCode, Equals, 400)
+	c.Assert(e.Code, Equals, "InvalidSecurityGroup")
+	c.Assert(e.Message, Equals, "Invalid security group: sg-unknown")
+	_, err = s.clientTests.elb.DescribeLoadBalancers("vpclb")
+	c.Assert(err, NotNil)
+}
+
+func (s *LocalServerSuite) TestApplySecurityGroupsToLoadBalancer(c *C) {
+	s.srv.srv.SetStrict(true)
+	defer s.srv.srv.SetStrict(false)
+	s.srv.srv.NewSecurityGroup("sg-1")
+	defer s.srv.srv.RemoveSecurityGroup("sg-1")
+	s.srv.srv.NewSecurityGroup("sg-2")
+	defer s.srv.srv.RemoveSecurityGroup("sg-2")
+	s.createVPCLoadBalancer(c, "vpclb", "sg-1")
+	defer s.clientTests.elb.DeleteLoadBalancer("vpclb")
+	_, err := s.clientTests.elb.ApplySecurityGroupsToLoadBalancer("vpclb", []string{"sg-2"})
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.ApplySecurityGroupsToLoadBalancer("vpclb", []string{"sg-2", "sg-unknown"})
+	c.Assert(err, ErrorMatches, `^Invalid security group: sg-unknown \(InvalidSecurityGroup\)$`)
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("vpclb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].SecurityGroups, DeepEquals, []string{"sg-2"})
+}
+
+func (s *LocalServerSuite) TestApplySecurityGroupsToClassicLoadBalancer(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("classiclb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("classiclb")
+	_, err = s.clientTests.elb.ApplySecurityGroupsToLoadBalancer("classiclb", []string{"sg-1"})
+	c.Assert(err, ErrorMatches, `^Security groups are only available to load balancers in a VPC, and classiclb is not in one \(InvalidConfigurationRequest\)$`)
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("classiclb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].SecurityGroups, HasLen, 0)
+}
+
+func (s *LocalServerSuite) TestLenientModeAcceptsUnregisteredSubnetsAndListenerConflicts(c *C) {
+	createLB := createLBRequest("vpclb")
+	createLB.AvailZones = nil
+	createLB.Subnets = []string{"subnet-unknown"}
+	createLB.Listeners = append(createLB.Listeners, elb.Listener{
+		InstancePort:     443,
+		InstanceProtocol: "tcp",
+		LoadBalancerPort: 443,
+		Protocol:         "http",
+	})
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("vpclb")
+	_, err = s.clientTests.elb.AttachLoadBalancerToSubnets("vpclb", []string{"subnet-other"})
+	c.Assert(err, IsNil)
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("vpclb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].Subnets, DeepEquals, []string{"subnet-unknown", "subnet-other"})
+	c.Assert(resp.LoadBalancerDescriptions[0].ListenerDescriptions, HasLen, 2)
+}
+
+func (s *LocalServerSuite) TestBackendServerDescriptions(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("testlb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].BackendServerDescriptions, DeepEquals, []elb.BackendServerDescription{})
+	proxy := []elb.PolicyAttribute{{AttributeName: "ProxyProtocol", AttributeValue: "true"}}
+	_, err = s.clientTests.elb.CreateLoadBalancerPolicy("testlb", "EnableProxyProtocol", "ProxyProtocolPolicyType", proxy)
+	c.Assert(err, IsNil)
+	auth := []elb.PolicyAttribute{{AttributeName: "PublicKeyPolicyName", AttributeValue: "MyPublicKey"}}
+	_, err = s.clientTests.elb.CreateLoadBalancerPolicy("testlb", "BackendAuth", "BackendServerAuthenticationPolicyType", auth)
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.SetLoadBalancerPoliciesForBackendServer("testlb", 80, []string{"EnableProxyProtocol"})
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.SetLoadBalancerPoliciesForBackendServer("testlb", 8080, []string{"EnableProxyProtocol"})
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.SetLoadBalancerPoliciesForBackendServer("testlb", 8080, []string{"BackendAuth"})
+	c.Assert(err, IsNil)
+	resp, err = s.clientTests.elb.DescribeLoadBalancers("testlb")
+	c.Assert(err, IsNil)
+	expected := []elb.BackendServerDescription{
+		{InstancePort: 80, PolicyNames: []string{"EnableProxyProtocol"}},
+		{InstancePort: 8080, PolicyNames: []string{"BackendAuth"}},
+	}
+	c.Assert(resp.LoadBalancerDescriptions[0].BackendServerDescriptions, DeepEquals, expected)
+	_, err = s.clientTests.elb.SetLoadBalancerPoliciesForBackendServer("testlb", 80, nil)
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.SetLoadBalancerPoliciesForBackendServer("testlb", 8080, nil)
+	c.Assert(err, IsNil)
+	resp, err = s.clientTests.elb.DescribeLoadBalancers("testlb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].BackendServerDescriptions, DeepEquals, []elb.BackendServerDescription{})
+}
+
+func (s *LocalServerSuite) TestSetLoadBalancerPoliciesForBackendServerErrors(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	_, err = s.clientTests.elb.CreateLBCookieStickinessPolicy("testlb", "sticky", 60)
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.SetLoadBalancerPoliciesForBackendServer("testlb", 80, []string{"unknown"})
+	c.Assert(err, ErrorMatches, `^There is no policy named unknown \(PolicyNotFound\)$`)
+	_, err = s.clientTests.elb.SetLoadBalancerPoliciesForBackendServer("testlb", 80, []string{"sticky"})
+	c.Assert(err, ErrorMatches, `^Policy sticky of type LBCookieStickinessPolicyType cannot be applied to backend servers \(InvalidConfigurationRequest\)$`)
+	_, err = s.clientTests.elb.SetLoadBalancerPoliciesForBackendServer("testlb", 80, nil)
+	c.Assert(err, IsNil)
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("testlb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].BackendServerDescriptions, HasLen, 0)
+}
+
+func (s *LocalServerSuite) TestDetectDrift(c *C) {
+	createLB := createLBRequest("driftlb")
+	createLB.Listeners = append(createLB.Listeners, elb.Listener{
+		InstancePort:     8080,
+		InstanceProtocol: "http",
+		LoadBalancerPort: 8080,
+		Protocol:         "http",
+	}, elb.Listener{
+		InstancePort:     9000,
+		InstanceProtocol: "tcp",
+		LoadBalancerPort: 9000,
+		Protocol:         "tcp",
+	})
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("driftlb")
+	inst1, inst2, inst3 := s.srv.srv.NewInstance(), s.srv.srv.NewInstance(), s.srv.srv.NewInstance()
+	defer s.srv.srv.RemoveInstance(inst1)
+	defer s.srv.srv.RemoveInstance(inst2)
+	defer s.srv.srv.RemoveInstance(inst3)
+	_, err = s.clientTests.elb.RegisterInstancesWithLoadBalancer([]string{inst1, inst2}, "driftlb")
+	c.Assert(err, IsNil)
+	desired := elb.LoadBalancerDescription{
+		ListenerDescriptions: []elb.ListenerDescription{
+			{Listener: elb.Listener{InstancePort: 80, InstanceProtocol: "HTTP", LoadBalancerPort: 80, Protocol: "HTTP"}},
+			{Listener: elb.Listener{InstancePort: 8081, InstanceProtocol: "HTTP", LoadBalancerPort: 8080, Protocol: "HTTP"}},
+			{Listener: elb.Listener{InstancePort: 25, InstanceProtocol: "TCP", LoadBalancerPort: 25, Protocol: "TCP"}},
+		},
+		Instances: []elb.Instance{{InstanceId: inst2}, {InstanceId: inst3}},
+		HealthCheck: elb.HealthCheck{
+			HealthyThreshold:   3,
+			Interval:           10,
+			Target:             "HTTP:80/health",
+			Timeout:            5,
+			UnhealthyThreshold: 2,
+		},
+	}
+	drift, err := s.clientTests.elb.DetectDrift("driftlb", desired)
+	c.Assert(err, IsNil)
+	c.Assert(drift.Empty(), Equals, false)
+	c.Assert(drift.MissingListeners, DeepEquals, []elb.Listener{desired.ListenerDescriptions[2].Listener})
+	c.Assert(drift.ExtraListeners, DeepEquals, []elb.Listener{
+		{InstancePort: 9000, InstanceProtocol: "TCP", LoadBalancerPort: 9000, Protocol: "TCP"},
+	})
+	c.Assert(drift.ChangedListeners, DeepEquals, []elb.ListenerDrift{{
+		Desired: desired.ListenerDescriptions[1].Listener,
+		Current: elb.Listener{InstancePort: 8080, InstanceProtocol: "HTTP", LoadBalancerPort: 8080, Protocol: "HTTP"},
+	}})
+	c.Assert(drift.MissingInstances, DeepEquals, []string{inst3})
+	c.Assert(drift.ExtraInstances, DeepEquals, []string{inst1})
+	c.Assert(drift.HealthCheck, NotNil)
+	c.Assert(drift.HealthCheck.Desired, DeepEquals, desired.HealthCheck)
+	c.Assert(drift.HealthCheck.Current.Target, Equals, "TCP:80")
+	desired.Instances = append(desired.Instances, elb.Instance{InstanceId: inst3})
+	drift, err = s.clientTests.elb.DetectDrift("driftlb", desired)
+	c.Assert(err, IsNil)
+	c.Assert(drift.MissingInstances, DeepEquals, []string{inst3})
+}
+
+func (s *LocalServerSuite) TestReconcileListeners(c *C) {
+	createLB := createLBRequest("reconcilelb")
+	createLB.Listeners = append(createLB.Listeners, elb.Listener{
+		InstancePort:     8080,
+		InstanceProtocol: "HTTP",
+		LoadBalancerPort: 8080,
+		Protocol:         "HTTP",
+	}, elb.Listener{
+		InstancePort:     9000,
+		InstanceProtocol: "TCP",
+		LoadBalancerPort: 9000,
+		Protocol:         "TCP",
+	})
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("reconcilelb")
+	desired := []elb.Listener{
+		{InstancePort: 80, InstanceProtocol: "HTTP", LoadBalancerPort: 80, Protocol: "HTTP"},
+		{InstancePort: 8081, InstanceProtocol: "HTTP", LoadBalancerPort: 8080, Protocol: "HTTP"},
+		{InstancePort: 25, InstanceProtocol: "TCP", LoadBalancerPort: 25, Protocol: "TCP"},
+	}
+	err = s.clientTests.elb.ReconcileListeners("reconcilelb", desired)
+	c.Assert(err, IsNil)
+	lds := make([]elb.ListenerDescription, len(desired))
+	for i, l := range desired {
+		lds[i] = elb.ListenerDescription{Listener: l}
+	}
+	drift, err := s.clientTests.elb.DetectDrift("reconcilelb", elb.LoadBalancerDescription{ListenerDescriptions: lds})
+	c.Assert(err, IsNil)
+	c.Assert(drift.MissingListeners, HasLen, 0)
+	c.Assert(drift.ExtraListeners, HasLen, 0)
+	c.Assert(drift.ChangedListeners, HasLen, 0)
+}
+
+func (s *LocalServerSuite) TestReconcileListenersRestoresListenersOnFailure(c *C) {
+	createLB := createLBRequest("reconcilelb")
+	createLB.Listeners = append(createLB.Listeners, elb.Listener{
+		InstancePort:     8080,
+		InstanceProtocol: "HTTP",
+		LoadBalancerPort: 8080,
+		Protocol:         "HTTP",
+	}, elb.Listener{
+		InstancePort:     9000,
+		InstanceProtocol: "TCP",
+		LoadBalancerPort: 9000,
+		Protocol:         "TCP",
+	})
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("reconcilelb")
+	s.srv.srv.InjectError("CreateLoadBalancerListeners", &elb.Error{StatusCode: 500, Code: "InternalFailure", Message: "oops"}, 1)
+	desired := []elb.Listener{
+		{InstancePort: 80, InstanceProtocol: "HTTP", LoadBalancerPort: 80, Protocol: "HTTP"},
+		{InstancePort: 8081, InstanceProtocol: "HTTP", LoadBalancerPort: 8080, Protocol: "HTTP"},
+	}
+	err = s.clientTests.elb.ReconcileListeners("reconcilelb", desired)
+	e, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.Code, Equals, "InternalFailure")
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("reconcilelb")
+	c.Assert(err, IsNil)
+	listeners := make(map[int]elb.Listener)
+	for _, ld := range resp.LoadBalancerDescriptions[0].ListenerDescriptions {
+		listeners[ld.Listener.LoadBalancerPort] = ld.Listener
+	}
+	c.Assert(listeners, DeepEquals, map[int]elb.Listener{
+		80:   {InstancePort: 80, InstanceProtocol: "HTTP", LoadBalancerPort: 80, Protocol: "HTTP"},
+		8080: {InstancePort: 8080, InstanceProtocol: "HTTP", LoadBalancerPort: 8080, Protocol: "HTTP"},
+		9000: {InstancePort: 9000, InstanceProtocol: "TCP", LoadBalancerPort: 9000, Protocol: "TCP"},
+	})
+}
+
+func (s *LocalServerSuite) TestReconcileListenersOfAbsentLoadBalancer(c *C) {
+	err := s.clientTests.elb.ReconcileListeners("absentlb", nil)
+	e, ok := err.(*elb.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.Code, Equals, "LoadBalancerNotFound")
+}
+
+func (s *LocalServerSuite) TestReconcileAll(c *C) {
+	srv := s.srv.srv
+	inst1, inst2 := srv.NewInstance(), srv.NewInstance()
+	defer srv.RemoveInstance(inst1)
+	defer srv.RemoveInstance(inst2)
+	names := []string{"reconcile1", "reconcile2", "reconcile3"}
+	for _, name := range names {
+		_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest(name))
+		c.Assert(err, IsNil)
+		defer s.clientTests.elb.DeleteLoadBalancer(name)
+	}
+	_, err := s.clientTests.elb.RegisterInstancesWithLoadBalancer([]string{inst1}, "reconcile1")
+	c.Assert(err, IsNil)
+	listeners := []elb.ListenerDescription{
+		{Listener: elb.Listener{InstancePort: 8080, InstanceProtocol: "HTTP", LoadBalancerPort: 80, Protocol: "HTTP"}},
+	}
+	var desired []elb.LoadBalancerDescription
+	for _, name := range names {
+		desired = append(desired, elb.LoadBalancerDescription{
+			LoadBalancerName:     name,
+			ListenerDescriptions: listeners,
+			Instances:            []elb.Instance{{InstanceId: inst2}},
+		})
+	}
+	err = s.clientTests.elb.ReconcileAll(desired, 2)
+	c.Assert(err, IsNil)
+	for _, d := range desired {
+		drift, err := s.clientTests.elb.DetectDrift(d.LoadBalancerName, d)
+		c.Assert(err, IsNil)
+		c.Assert(drift.Empty(), Equals, true)
+	}
+}
+
+func (s *LocalServerSuite) TestReconcileAllReportsFailuresByName(c *C) {
+	srv := s.srv.srv
+	instId := srv.NewInstance()
+	defer srv.RemoveInstance(instId)
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("reconcile1"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("reconcile1")
+	listeners := []elb.ListenerDescription{
+		{Listener: elb.Listener{InstancePort: 80, InstanceProtocol: "HTTP", LoadBalancerPort: 80, Protocol: "HTTP"}},
+	}
+	desired := []elb.LoadBalancerDescription{
+		{LoadBalancerName: "absentlb1", ListenerDescriptions: listeners},
+		{LoadBalancerName: "reconcile1", ListenerDescriptions: listeners, Instances: []elb.Instance{{InstanceId: instId}}},
+		{LoadBalancerName: "absentlb2", ListenerDescriptions: listeners},
+	}
+	err = s.clientTests.elb.ReconcileAll(desired, 0)
+	batch, ok := err.(elb.BatchError)
+	c.Assert(ok, Equals, true)
+	c.Assert(batch, HasLen, 2)
+	c.Assert(batch["absentlb1"], NotNil)
+	c.Assert(batch["absentlb2"], NotNil)
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("reconcile1")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].Instances, DeepEquals, []elb.Instance{{InstanceId: instId}})
+}
+
+func (s *LocalServerSuite) TestReconcileAllWithDuplicateNames(c *C) {
+	desired := []elb.LoadBalancerDescription{
+		{LoadBalancerName: "duplicatelb"},
+		{LoadBalancerName: "duplicatelb"},
+	}
+	err := s.clientTests.elb.ReconcileAll(desired, 1)
+	c.Assert(err, ErrorMatches, "Load Balancer duplicatelb is given more than once")
+}
+
+func (s *LocalServerSuite) TestDetectDriftOfAttributesAndTags(c *C) {
+	healthCheck := elb.HealthCheck{
+		HealthyThreshold:   3,
+		Interval:           10,
+		Target:             "HTTP:80/health",
+		Timeout:            5,
+		UnhealthyThreshold: 2,
+	}
+	desc := elb.LoadBalancerDescription{
+		LoadBalancerName: "driftlb",
+		AvailZones:       []string{"us-east-1a"},
+		ListenerDescriptions: []elb.ListenerDescription{
+			{Listener: elb.Listener{InstancePort: 80, InstanceProtocol: "HTTP", LoadBalancerPort: 80, Protocol: "HTTP"}},
+			{Listener: elb.Listener{InstancePort: 9000, InstanceProtocol: "TCP", LoadBalancerPort: 9000, Protocol: "TCP"}},
+		},
+		Instances:   []elb.Instance{{InstanceId: "i-drift1"}, {InstanceId: "i-drift2"}},
+		HealthCheck: healthCheck,
+	}
+	err := s.srv.srv.AddLoadBalancer(desc, elbtest.LoadBalancerOptions{
+		Attributes: &elb.LoadBalancerAttributes{
+			ConnectionDraining:     &elb.ConnectionDraining{Enabled: true, Timeout: 60},
+			CrossZoneLoadBalancing: &elb.CrossZoneLoadBalancing{Enabled: true},
+		},
+		Tags: []elb.Tag{{Key: "env", Value: "test"}, {Key: "team", Value: "web"}},
+	})
+	c.Assert(err, IsNil)
+	defer s.srv.srv.RemoveLoadBalancer("driftlb")
+	defer s.srv.srv.RemoveInstance("i-drift1")
+	defer s.srv.srv.RemoveInstance("i-drift2")
+	opts := elb.DriftOptions{
+		Attributes: &elb.LoadBalancerAttributes{
+			ConnectionDraining:     &elb.ConnectionDraining{Enabled: true, Timeout: 300},
+			ConnectionSettings:     &elb.ConnectionSettings{IdleTimeout: 60},
+			CrossZoneLoadBalancing: &elb.CrossZoneLoadBalancing{Enabled: true},
+		},
+		Tags: []elb.Tag{{Key: "env", Value: "prod"}, {Key: "owner", Value: "ops"}},
+	}
+	drift, err := s.clientTests.elb.DetectDrift("driftlb", desc, opts)
+	c.Assert(err, IsNil)
+	c.Assert(drift.Empty(), Equals, false)
+	c.Assert(drift.MissingListeners, HasLen, 0)
+	c.Assert(drift.ExtraListeners, HasLen, 0)
+	c.Assert(drift.ChangedListeners, HasLen, 0)
+	c.Assert(drift.MissingInstances, HasLen, 0)
+	c.Assert(drift.ExtraInstances, HasLen, 0)
+	c.Assert(drift.HealthCheck, IsNil)
+	c.Assert(drift.Attributes, DeepEquals, &elb.AttributesDrift{
+		Desired: elb.LoadBalancerAttributes{ConnectionDraining: &elb.ConnectionDraining{Enabled: true, Timeout: 300}},
+		Current: elb.LoadBalancerAttributes{ConnectionDraining: &elb.ConnectionDraining{Enabled: true, Timeout: 60}},
+	})
+	c.Assert(drift.MissingTags, DeepEquals, []elb.Tag{{Key: "owner", Value: "ops"}})
+	c.Assert(drift.ExtraTags, DeepEquals, []elb.Tag{{Key: "team", Value: "web"}})
+	c.Assert(drift.ChangedTags, DeepEquals, []elb.TagDrift{{
+		Desired: elb.Tag{Key: "env", Value: "prod"},
+		Current: elb.Tag{Key: "env", Value: "test"},
+	}})
+	opts = elb.DriftOptions{
+		Attributes: &elb.LoadBalancerAttributes{
+			ConnectionDraining: &elb.ConnectionDraining{Enabled: true, Timeout: 60},
+		},
+		Tags: []elb.Tag{{Key: "team", Value: "web"}, {Key: "env", Value: "test"}},
+	}
+	drift, err = s.clientTests.elb.DetectDrift("driftlb", desc, opts)
+	c.Assert(err, IsNil)
+	c.Assert(drift.Empty(), Equals, true)
+}
+
+func (s *LocalServerSuite) TestDetectDriftWithoutDifferences(c *C) {
+	createLB := createLBRequest("driftlb")
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLB)
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("driftlb")
+	desired := elb.LoadBalancerDescription{
+		ListenerDescriptions: []elb.ListenerDescription{{Listener: createLB.Listeners[0]}},
+	}
+	drift, err := s.clientTests.elb.DetectDrift("driftlb", desired)
+	c.Assert(err, IsNil)
+	c.Assert(drift.Empty(), Equals, true)
+}
+
+func (s *LocalServerSuite) TestDetectDriftOfAbsentLoadBalancer(c *C) {
+	_, err := s.clientTests.elb.DetectDrift("absentlb", elb.LoadBalancerDescription{})
+	c.Assert(err, ErrorMatches, ".*(LoadBalancerNotFound).*")
+}
+
+func (s *LocalServerSuite) TestAddLoadBalancer(c *C) {
+	desc := elb.LoadBalancerDescription{
+		LoadBalancerName: "seededlb",
+		AvailZones:       []string{"us-east-1a"},
+		ListenerDescriptions: []elb.ListenerDescription{
+			{Listener: elb.Listener{InstancePort: 80, InstanceProtocol: "HTTP", LoadBalancerPort: 80, Protocol: "HTTP"}},
+			{Listener: elb.Listener{InstancePort: 8443, InstanceProtocol: "HTTP", LoadBalancerPort: 443, Protocol: "HTTPS", SSLCertificateId: "arn:aws:iam::123456789012:server-certificate/mycert"}},
+		},
+		Instances: []elb.Instance{{InstanceId: "i-seeded1"}, {InstanceId: "i-seeded2"}},
+		HealthCheck: elb.HealthCheck{
+			HealthyThreshold:   3,
+			Interval:           10,
+			Target:             "HTTP:80/health",
+			Timeout:            5,
+			UnhealthyThreshold: 2,
+		},
+	}
+	s.srv.srv.SetCreateConsistencyDelay(time.Hour)
+	defer s.srv.srv.SetCreateConsistencyDelay(0)
+	err := s.srv.srv.AddLoadBalancer(desc)
+	c.Assert(err, IsNil)
+	defer s.srv.srv.RemoveLoadBalancer("seededlb")
+	defer s.srv.srv.RemoveInstance("i-seeded1")
+	defer s.srv.srv.RemoveInstance("i-seeded2")
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("seededlb")
+	c.Assert(err, IsNil)
+	lb := resp.LoadBalancerDescriptions[0]
+	c.Assert(lb.ListenerDescriptions, DeepEquals, desc.ListenerDescriptions)
+	c.Assert(lb.Instances, DeepEquals, desc.Instances)
+	c.Assert(lb.HealthCheck, DeepEquals, desc.HealthCheck)
+	c.Assert(lb.DNSName, Equals, "seededlb-some-aws-stuff.us-east-1.elb.amazonaws.com")
+	health, err := s.clientTests.elb.DescribeInstanceHealth("seededlb")
+	c.Assert(err, IsNil)
+	c.Assert(health.InstanceStates, HasLen, 2)
+	c.Assert(health.InstanceStates[0].InstanceId, Equals, "i-seeded1")
+	c.Assert(health.InstanceStates[0].State, Equals, "InService")
+	attrs, err := s.clientTests.elb.DescribeLoadBalancerAttributes("seededlb")
+	c.Assert(err, IsNil)
+	c.Assert(attrs.LoadBalancerAttributes.ConnectionSettings, DeepEquals, &elb.ConnectionSettings{IdleTimeout: 60})
+	desc.ListenerDescriptions[0].Listener.InstancePort = 8080
+	resp, err = s.clientTests.elb.DescribeLoadBalancers("seededlb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].ListenerDescriptions[0].Listener.InstancePort, Equals, 80)
+}
+
+func (s *LocalServerSuite) TestAddLoadBalancerWithAttributesAndTags(c *C) {
+	desc := elb.LoadBalancerDescription{LoadBalancerName: "seededlb"}
+	opts := elbtest.LoadBalancerOptions{
+		Attributes: &elb.LoadBalancerAttributes{
+			ConnectionDraining:     &elb.ConnectionDraining{Enabled: true, Timeout: 60},
+			CrossZoneLoadBalancing: &elb.CrossZoneLoadBalancing{Enabled: true},
+		},
+		Tags: []elb.Tag{{Key: "env", Value: "test"}},
+	}
+	err := s.srv.srv.AddLoadBalancer(desc, opts)
+	c.Assert(err, IsNil)
+	defer s.srv.srv.RemoveLoadBalancer("seededlb")
+	opts.Attributes.ConnectionDraining.Timeout = 120
+	opts.Tags[0].Value = "prod"
+	attrs, err := s.clientTests.elb.DescribeLoadBalancerAttributes("seededlb")
+	c.Assert(err, IsNil)
+	c.Assert(attrs.LoadBalancerAttributes.ConnectionDraining, DeepEquals, &elb.ConnectionDraining{Enabled: true, Timeout: 60})
+	c.Assert(attrs.LoadBalancerAttributes.CrossZoneLoadBalancing, DeepEquals, &elb.CrossZoneLoadBalancing{Enabled: true})
+	c.Assert(attrs.LoadBalancerAttributes.ConnectionSettings, DeepEquals, &elb.ConnectionSettings{IdleTimeout: 60})
+	tags, err := s.clientTests.elb.DescribeTags("seededlb")
+	c.Assert(err, IsNil)
+	c.Assert(tags.TagDescriptions, HasLen, 1)
+	c.Assert(tags.TagDescriptions[0].Tags, DeepEquals, []elb.Tag{{Key: "env", Value: "test"}})
+}
+
+func (s *LocalServerSuite) TestAddLoadBalancerWithPolicyDescriptions(c *C) {
+	desc := elb.LoadBalancerDescription{
+		LoadBalancerName: "seededlb",
+		ListenerDescriptions: []elb.ListenerDescription{
+			{Listener: elb.Listener{InstancePort: 80, InstanceProtocol: "TCP", LoadBalancerPort: 80, Protocol: "TCP"}},
+		},
+		Policies: elb.Policies{OtherPolicies: []string{"ELBSecurityPolicy-2016-08"}},
+	}
+	opts := elbtest.LoadBalancerOptions{
+		Policies: []elb.PolicyDescription{{
+			PolicyName:                  "proxy",
+			PolicyTypeName:              "ProxyProtocolPolicyType",
+			PolicyAttributeDescriptions: []elb.PolicyAttribute{{AttributeName: "ProxyProtocol", AttributeValue: "true"}},
+		}},
+	}
+	err := s.srv.srv.AddLoadBalancer(desc, opts)
+	c.Assert(err, IsNil)
+	defer s.srv.srv.RemoveLoadBalancer("seededlb")
+	policies, err := s.clientTests.elb.DescribeLoadBalancerPolicies("seededlb", "ELBSecurityPolicy-2016-08", "proxy")
+	c.Assert(err, IsNil)
+	c.Assert(policies.PolicyDescriptions, HasLen, 2)
+	c.Assert(policies.PolicyDescriptions[0].PolicyTypeName, Equals, "SSLNegotiationPolicyType")
+	c.Assert(policies.PolicyDescriptions[1].PolicyTypeName, Equals, "ProxyProtocolPolicyType")
+	_, err = s.clientTests.elb.SetLoadBalancerPoliciesForBackendServer("seededlb", 80, []string{"proxy"})
+	c.Assert(err, IsNil)
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("seededlb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].BackendServerDescriptions, DeepEquals, []elb.BackendServerDescription{
+		{InstancePort: 80, PolicyNames: []string{"proxy"}},
+	})
+	err = s.srv.srv.AddLoadBalancer(elb.LoadBalancerDescription{LoadBalancerName: "otherlb"}, elbtest.LoadBalancerOptions{
+		Policies: []elb.PolicyDescription{{PolicyName: "proxy", PolicyTypeName: "UnknownPolicyType"}},
+	})
+	c.Assert(err, ErrorMatches, `policy "proxy" of load balancer "otherlb" has unknown type "UnknownPolicyType"`)
+}
+
+func (s *LocalServerSuite) TestAddLoadBalancerValidation(c *C) {
+	err := s.srv.srv.AddLoadBalancer(elb.LoadBalancerDescription{})
+	c.Assert(err, ErrorMatches, "load balancer has no name")
+	err = s.srv.srv.AddLoadBalancer(elb.LoadBalancerDescription{
+		LoadBalancerName: "seededlb",
+		ListenerDescriptions: []elb.ListenerDescription{
+			{Listener: elb.Listener{InstancePort: 80, InstanceProtocol: "HTTP", LoadBalancerPort: 80, Protocol: "HTTP"}},
+			{Listener: elb.Listener{InstancePort: 8080, InstanceProtocol: "HTTP", LoadBalancerPort: 80, Protocol: "HTTP"}},
+		},
+	})
+	c.Assert(err, ErrorMatches, `load balancer "seededlb" has more than one listener on port 80`)
+	err = s.srv.srv.AddLoadBalancer(elb.LoadBalancerDescription{LoadBalancerName: "seededlb"}, elbtest.LoadBalancerOptions{
+		Tags: []elb.Tag{{Key: "env", Value: "test"}, {Key: "env", Value: "prod"}},
+	})
+	c.Assert(err, ErrorMatches, `load balancer "seededlb" has more than one tag with key "env"`)
+	err = s.srv.srv.AddLoadBalancer(elb.LoadBalancerDescription{LoadBalancerName: "seededlb"})
+	c.Assert(err, IsNil)
+	defer s.srv.srv.RemoveLoadBalancer("seededlb")
+	err = s.srv.srv.AddLoadBalancer(elb.LoadBalancerDescription{LoadBalancerName: "seededlb"})
+	c.Assert(err, ErrorMatches, `load balancer "seededlb" already exists`)
+}
+
+func (s *LocalServerSuite) enableConnectionDraining(c *C, lbName string, timeout int) {
+	attrs := elb.LoadBalancerAttributes{
+		ConnectionDraining: &elb.ConnectionDraining{Enabled: true, Timeout: timeout},
+	}
+	_, err := s.clientTests.elb.ModifyLoadBalancerAttributes(lbName, &attrs)
+	c.Assert(err, IsNil)
+}
+
+func (s *LocalServerSuite) TestDeregisterWithoutConnectionDraining(c *C) {
+	srv := s.srv.srv
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("draininglb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("draininglb")
+	inst := srv.NewInstance()
+	defer srv.RemoveInstance(inst)
+	_, err = s.clientTests.elb.RegisterInstancesWithLoadBalancer([]string{inst}, "draininglb")
+	c.Assert(err, IsNil)
+	c.Assert(srv.SetInstanceHealth("draininglb", inst, "InService"), IsNil)
+	_, err = s.clientTests.elb.DeregisterInstancesFromLoadBalancer([]string{inst}, "draininglb")
+	c.Assert(err, IsNil)
+	resp, err := s.clientTests.elb.DescribeInstanceHealth("draininglb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.InstanceStates, HasLen, 0)
+}
+
+func (s *LocalServerSuite) TestDeregisterWithConnectionDraining(c *C) {
+	srv := s.srv.srv
+	srv.SetConnectionDrainingUnit(time.Millisecond)
+	defer srv.SetConnectionDrainingUnit(time.Second)
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("draininglb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("draininglb")
+	s.enableConnectionDraining(c, "draininglb", 100)
+	inst1, inst2 := srv.NewInstance(), srv.NewInstance()
+	defer srv.RemoveInstance(inst1)
+	defer srv.RemoveInstance(inst2)
+	_, err = s.clientTests.elb.RegisterInstancesWithLoadBalancer([]string{inst1, inst2}, "draininglb")
+	c.Assert(err, IsNil)
+	c.Assert(srv.SetInstanceHealth("draininglb", inst1, "InService"), IsNil)
+	_, err = s.clientTests.elb.DeregisterInstancesFromLoadBalancer([]string{inst1}, "draininglb")
+	c.Assert(err, IsNil)
+	describeResp, err := s.clientTests.elb.DescribeLoadBalancers("draininglb")
+	c.Assert(err, IsNil)
+	c.Assert(describeResp.LoadBalancerDescriptions[0].Instances, DeepEquals, []elb.Instance{{InstanceId: inst2}})
+	resp, err := s.clientTests.elb.DescribeInstanceHealth("draininglb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.InstanceStates, HasLen, 2)
+	c.Assert(resp.InstanceStates[0].InstanceId, Equals, inst1)
+	c.Assert(resp.InstanceStates[0].State, Equals, "InService")
+	c.Assert(resp.InstanceStates[0].Description, Equals, "Instance deregistration currently in progress.")
+	time.Sleep(150 * time.Millisecond)
+	resp, err = s.clientTests.elb.DescribeInstanceHealth("draininglb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.InstanceStates, HasLen, 1)
+	c.Assert(resp.InstanceStates[0].InstanceId, Equals, inst2)
+}
+
+func (s *LocalServerSuite) TestRegisterInstanceWhileDraining(c *C) {
+	srv := s.srv.srv
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("draininglb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("draininglb")
+	s.enableConnectionDraining(c, "draininglb", 300)
+	inst := srv.NewInstance()
+	defer srv.RemoveInstance(inst)
+	_, err = s.clientTests.elb.RegisterInstancesWithLoadBalancer([]string{inst}, "draininglb")
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.DeregisterInstancesFromLoadBalancer([]string{inst}, "draininglb")
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.RegisterInstancesWithLoadBalancer([]string{inst}, "draininglb")
+	c.Assert(err, IsNil)
+	resp, err := s.clientTests.elb.DescribeInstanceHealth("draininglb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.InstanceStates, HasLen, 1)
+	c.Assert(resp.InstanceStates[0].Description, Equals, "Instance is in pending state.")
+}
+
+func (s *LocalServerSuite) TestDeregisterAndWait(c *C) {
+	srv := s.srv.srv
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("draininglb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("draininglb")
+	s.enableConnectionDraining(c, "draininglb", 300)
+	inst1, inst2 := srv.NewInstance(), srv.NewInstance()
+	defer srv.RemoveInstance(inst1)
+	defer srv.RemoveInstance(inst2)
+	_, err = s.clientTests.elb.RegisterInstancesWithLoadBalancer([]string{inst1, inst2}, "draininglb")
+	c.Assert(err, IsNil)
+	c.Assert(srv.SetInstanceHealth("draininglb", inst1, "InService"), IsNil)
+	c.Assert(srv.SetInstanceHealth("draininglb", inst2, "InService"), IsNil)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		srv.SetInstanceHealth("draininglb", inst1, "OutOfService")
+		srv.SetInstanceHealth("draininglb", inst2, "OutOfService")
+	}()
+	start := time.Now()
+	err = s.clientTests.elb.DeregisterAndWait("draininglb", []string{inst1, inst2}, 5*time.Second)
+	c.Assert(err, IsNil)
+	c.Assert(time.Since(start) >= 100*time.Millisecond, Equals, true)
+	resp, err := s.clientTests.elb.DescribeLoadBalancers("draininglb")
+	c.Assert(err, IsNil)
+	c.Assert(resp.LoadBalancerDescriptions[0].Instances, HasLen, 0)
+}
+
+func (s *LocalServerSuite) TestDeregisterAndWaitTimeout(c *C) {
+	srv := s.srv.srv
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("draininglb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("draininglb")
+	s.enableConnectionDraining(c, "draininglb", 300)
+	inst := srv.NewInstance()
+	defer srv.RemoveInstance(inst)
+	_, err = s.clientTests.elb.RegisterInstancesWithLoadBalancer([]string{inst}, "draininglb")
+	c.Assert(err, IsNil)
+	c.Assert(srv.SetInstanceHealth("draininglb", inst, "InService"), IsNil)
+	err = s.clientTests.elb.DeregisterAndWait("draininglb", []string{inst}, 200*time.Millisecond)
+	c.Assert(err, ErrorMatches, "timed out waiting for instances "+inst+" to leave Load Balancer draininglb")
+}
+
+func (s *LocalServerSuite) TestSetInstanceHealthOfUnregisteredInstance(c *C) {
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("testlb")
+	err = s.srv.srv.SetInstanceHealth("testlb", "i-unknown", "InService")
+	c.Assert(err, ErrorMatches, "instance i-unknown is not registered with load balancer testlb")
+}
+
+func (s *LocalServerSuite) TestSetInstanceState(c *C) {
+	srv := s.srv.srv
+	inst := srv.NewInstance()
+	defer srv.RemoveInstance(inst)
+	srv.NewLoadBalancer("testlb")
+	defer srv.RemoveLoadBalancer("testlb")
+	srv.RegisterInstance(inst, "testlb")
+	var tests = []struct {
+		state, reason string
+		want          elb.InstanceState
+	}{
+		{"InService", "", elb.InstanceState{InstanceId: inst, State: "InService", ReasonCode: "N/A", Description: "N/A"}},
+		{"Unknown", "", elb.InstanceState{InstanceId: inst, State: "Unknown", ReasonCode: "ELB", Description: "A transient error occurred. Please try again later."}},
+		{"OutOfService", "Instance has failed the health check.", elb.InstanceState{InstanceId: inst, State: "OutOfService", ReasonCode: "Instance", Description: "Instance has failed the health check."}},
+	}
+	for _, t := range tests {
+		c.Assert(srv.SetInstanceState("testlb", inst, t.state, t.reason), IsNil)
+		resp, err := s.clientTests.elb.DescribeInstanceHealth("testlb", inst)
+		c.Assert(err, IsNil)
+		c.Assert(resp.InstanceStates, DeepEquals, []elb.InstanceState{t.want})
+	}
+}
+
+func (s *LocalServerSuite) TestSetInstanceStateWithInvalidState(c *C) {
+	srv := s.srv.srv
+	inst := srv.NewInstance()
+	defer srv.RemoveInstance(inst)
+	srv.NewLoadBalancer("testlb")
+	defer srv.RemoveLoadBalancer("testlb")
+	srv.RegisterInstance(inst, "testlb")
+	err := srv.SetInstanceState("testlb", inst, "Healthy", "")
+	c.Assert(err, ErrorMatches, `invalid instance state "Healthy", it must be InService, OutOfService or Unknown`)
+}
+
+func (s *LocalServerSuite) TestLastRawResponseWithConcurrentRequests(c *C) {
+	client := elb.NewForTesting(s.srv.srv.URL())
+	client.CaptureRawResponses(true)
+	done := make(chan error)
+	for i := 0; i < 10; i++ {
+		go func() {
+			_, err := client.DescribeLoadBalancers()
+			done <- err
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		c.Assert(<-done, IsNil)
+	}
+	c.Assert(string(client.LastRawResponse()), Matches, "(?s)<DescribeLoadBalancersResponse xmlns=\"http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/\">.*</DescribeLoadBalancersResponse>")
+}
+
+func (s *LocalServerSuite) TestDescribeInstanceHealthListsInstancesInRegistrationOrder(c *C) {
+	srv := s.srv.srv
+	_, err := s.clientTests.elb.CreateLoadBalancer(createLBRequest("orderlb"))
+	c.Assert(err, IsNil)
+	defer s.clientTests.elb.DeleteLoadBalancer("orderlb")
+	inst1, inst2, inst3, inst4 := srv.NewInstance(), srv.NewInstance(), srv.NewInstance(), srv.NewInstance()
+	defer srv.RemoveInstance(inst1)
+	defer srv.RemoveInstance(inst2)
+	defer srv.RemoveInstance(inst3)
+	defer srv.RemoveInstance(inst4)
+	_, err = s.clientTests.elb.RegisterInstancesWithLoadBalancer([]string{inst3, inst1}, "orderlb")
+	c.Assert(err, IsNil)
+	_, err = s.clientTests.elb.RegisterInstancesWithLoadBalancer([]string{inst2}, "orderlb")
+	c.Assert(err, IsNil)
+	instanceIds := func() []string {
+		resp, err := s.clientTests.elb.DescribeInstanceHealth("orderlb")
+		c.Assert(err, IsNil)
+		ids := make([]string, len(resp.InstanceStates))
+		for i, state := range resp.InstanceStates {
+			ids[i] = state.InstanceId
+		}
+		return ids
+	}
+	c.Assert(instanceIds(), DeepEquals, []string{inst3, inst1, inst2})
+	srv.DeregisterInstance(inst3, "orderlb")
+	_, err = s.clientTests.elb.RegisterInstancesWithLoadBalancer([]string{inst4}, "orderlb")
+	c.Assert(err, IsNil)
+	c.Assert(instanceIds(), DeepEquals, []string{inst1, inst2, inst4})
+}
+
+func (s *LocalServerSuite) TestEvents(c *C) {
+	srv, err := elbtest.NewServer()
+	c.Assert(err, IsNil)
+	defer srv.Quit()
+	client := elb.NewForTesting(srv.URL())
+	events := srv.Events()
+	c.Assert(srv.Events(), Equals, events)
+	_, err = client.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	inst := srv.NewInstance()
+	_, err = client.RegisterInstancesWithLoadBalancer([]string{inst}, "testlb")
+	c.Assert(err, IsNil)
+	c.Assert(srv.SetInstanceState("testlb", inst, "OutOfService", ""), IsNil)
+	_, err = client.DeregisterInstancesFromLoadBalancer([]string{inst}, "testlb")
+	c.Assert(err, IsNil)
+	_, err = client.DeleteLoadBalancer("testlb")
+	c.Assert(err, IsNil)
+	want := []elbtest.Event{
+		{Type: elbtest.LBCreated, LoadBalancer: "testlb"},
+		{Type: elbtest.InstanceRegistered, LoadBalancer: "testlb", InstanceId: inst},
+		{Type: elbtest.InstanceStateChanged, LoadBalancer: "testlb", InstanceId: inst, State: "OutOfService"},
+		{Type: elbtest.InstanceDeregistered, LoadBalancer: "testlb", InstanceId: inst},
+		{Type: elbtest.LBDeleted, LoadBalancer: "testlb"},
+	}
+	for _, w := range want {
+		select {
+		case e := <-events:
+			c.Check(e, Equals, w)
+		case <-time.After(time.Second):
+			c.Fatalf("timed out waiting for %s event", w.Type)
+		}
+	}
+}
+
+func (s *LocalServerSuite) TestEventsAreNotSentForNoops(c *C) {
+	srv, err := elbtest.NewServer()
+	c.Assert(err, IsNil)
+	defer srv.Quit()
+	events := srv.Events()
+	inst := srv.NewInstance()
+	srv.RegisterInstance(inst, "unknown")
+	srv.DeregisterInstance(inst, "unknown")
+	srv.RemoveLoadBalancer("unknown")
+	srv.NewLoadBalancer("testlb")
+	select {
+	case e := <-events:
+		c.Check(e, Equals, elbtest.Event{Type: elbtest.LBCreated, LoadBalancer: "testlb"})
+	case <-time.After(time.Second):
+		c.Fatalf("timed out waiting for LBCreated event")
+	}
+}
+
+// failures records the failures reported by the assert package.
+type failures []string
+
+func (f *failures) Errorf(format string, args ...interface{}) {
+	*f = append(*f, fmt.Sprintf(format, args...))
+}
+
+func (s *LocalServerSuite) TestAssertions(c *C) {
+	srv, err := elbtest.NewServer()
+	c.Assert(err, IsNil)
+	defer srv.Quit()
+	client := elb.NewForTesting(srv.URL())
+	_, err = client.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	inst := srv.NewInstance()
+	other := srv.NewInstance()
+	_, err = client.RegisterInstancesWithLoadBalancer([]string{inst}, "testlb")
+	c.Assert(err, IsNil)
+	c.Assert(srv.SetInstanceState("testlb", inst, "InService", ""), IsNil)
+	var f failures
+	c.Check(assert.LoadBalancerExists(&f, srv, "testlb"), Equals, true)
+	c.Check(assert.LoadBalancerNotExists(&f, srv, "otherlb"), Equals, true)
+	c.Check(assert.InstanceRegistered(&f, srv, "testlb", inst), Equals, true)
+	c.Check(assert.InstanceNotRegistered(&f, srv, "testlb", other), Equals, true)
+	c.Check(assert.InstanceState(&f, srv, "testlb", inst, "InService"), Equals, true)
+	c.Check(assert.Listener(&f, srv, "testlb", 80, "http"), Equals, true)
+	c.Check(f, HasLen, 0)
+	c.Check(assert.LoadBalancerExists(&f, srv, "otherlb"), Equals, false)
+	c.Check(assert.LoadBalancerNotExists(&f, srv, "testlb"), Equals, false)
+	c.Check(assert.InstanceRegistered(&f, srv, "testlb", other), Equals, false)
+	c.Check(assert.InstanceNotRegistered(&f, srv, "testlb", inst), Equals, false)
+	c.Check(assert.InstanceState(&f, srv, "testlb", inst, "OutOfService"), Equals, false)
+	c.Check(assert.Listener(&f, srv, "testlb", 80, "TCP"), Equals, false)
+	c.Check(assert.Listener(&f, srv, "testlb", 443, "HTTPS"), Equals, false)
+	c.Check([]string(f), DeepEquals, []string{
+		`load balancer "otherlb" does not exist, load balancers: testlb`,
+		`load balancer "testlb" exists`,
+		fmt.Sprintf(`instance %q is not registered with load balancer "testlb", registered instances: %s`, other, inst),
+		fmt.Sprintf(`instance %q is registered with load balancer "testlb"`, inst),
+		fmt.Sprintf(`instance %q of load balancer "testlb" is InService, not OutOfService`, inst),
+		`listener of load balancer "testlb" on port 80 uses HTTP, not TCP`,
+		`load balancer "testlb" has no listener on port 443, listeners: HTTP:80`,
+	})
+}
+
+func (s *LocalServerSuite) TestStateGetters(c *C) {
+	srv, err := elbtest.NewServer()
+	c.Assert(err, IsNil)
+	defer srv.Quit()
+	client := elb.NewForTesting(srv.URL())
+	_, err = client.CreateLoadBalancer(createLBRequest("testlb"))
+	c.Assert(err, IsNil)
+	srv.NewLoadBalancer("anotherlb")
+	inst := srv.NewInstance()
+	_, err = client.RegisterInstancesWithLoadBalancer([]string{inst}, "testlb")
+	c.Assert(err, IsNil)
+	lbs := srv.LoadBalancers()
+	c.Assert(lbs, HasLen, 2)
+	c.Assert(lbs[0].LoadBalancerName, Equals, "anotherlb")
+	c.Assert(lbs[1].LoadBalancerName, Equals, "testlb")
+	lb, ok := srv.LoadBalancer("testlb")
+	c.Assert(ok, Equals, true)
+	c.Assert(lb.Instances, DeepEquals, []elb.Instance{{InstanceId: inst}})
+	listeners, ok := srv.Listeners("testlb")
+	c.Assert(ok, Equals, true)
+	c.Assert(listeners, HasLen, 1)
+	c.Assert(listeners[0].Listener, DeepEquals, elb.Listener{InstancePort: 80, InstanceProtocol: "HTTP", LoadBalancerPort: 80, Protocol: "HTTP"})
+	c.Assert(srv.Instances(), DeepEquals, []string{inst})
+	_, ok = srv.LoadBalancer("unknown")
+	c.Assert(ok, Equals, false)
+	_, ok = srv.Listeners("unknown")
+	c.Assert(ok, Equals, false)
+	// the getters return copies, changing them doesn't change the server.
+	lb.Instances[0].InstanceId = "i-changed"
+	listeners[0].Listener.LoadBalancerPort = 8080
+	srv.Instances()[0] = "i-changed"
+	lb, _ = srv.LoadBalancer("testlb")
+	c.Assert(lb.Instances, DeepEquals, []elb.Instance{{InstanceId: inst}})
+	c.Assert(lb.ListenerDescriptions[0].Listener.LoadBalancerPort, Equals, 80)
+	c.Assert(srv.Instances(), DeepEquals, []string{inst})
+}
+
+func (s *LocalServerSuite) TestEventsAreClosedByQuit(c *C) {
+	srv, err := elbtest.NewServer()
+	c.Assert(err, IsNil)
+	events := srv.Events()
+	srv.NewLoadBalancer("testlb")
+	srv.Quit()
+	select {
+	case _, ok := <-events:
+		c.Assert(ok, Equals, false)
+	case <-time.After(time.Second):
+		c.Fatalf("timed out waiting for the events channel to be closed")
+	}
+}
+
+func (s *LocalServerSuite) TestEventsAreDroppedByReset(c *C) {
+	srv, err := elbtest.NewServer()
+	c.Assert(err, IsNil)
+	defer srv.Quit()
+	events := srv.Events()
+	srv.NewLoadBalancer("testlb")
+	srv.NewLoadBalancer("otherlb")
+	// the first event may already be waiting in the channel.
+	e := <-events
+	c.Assert(e, Equals, elbtest.Event{Type: elbtest.LBCreated, LoadBalancer: "testlb"})
+	srv.Reset()
+	srv.NewLoadBalancer("newlb")
+	select {
+	case e := <-events:
+		c.Assert(e, Equals, elbtest.Event{Type: elbtest.LBCreated, LoadBalancer: "newlb"})
+	case <-time.After(time.Second):
+		c.Fatalf("timed out waiting for LBCreated event")
+	}
+}
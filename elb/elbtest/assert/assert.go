@@ -0,0 +1,174 @@
+// Package assert implements assertions on the state of an elbtest fake
+// server, so test suites using the fake don't have to describe the state with
+// the API to check it.
+//
+// The assertions work with both *testing.T and gocheck's *C:
+//
+//	func (s *S) TestCreate(c *C) {
+//	    // ...
+//	    assert.LoadBalancerExists(c, s.srv, "testlb")
+//	}
+//
+// Each assertion reports a failure with Errorf and returns whether it passed,
+// so the test goes on after a failed assertion unless it checks the result.
+package assert
+
+import (
+	"fmt"
+	"github.com/flaviamissi/go-elb/elb"
+	"github.com/flaviamissi/go-elb/elb/elbtest"
+	"sort"
+	"strings"
+)
+
+// TestingT is the part of *testing.T and gocheck's *C used to report
+// failures.
+type TestingT interface {
+	Errorf(format string, args ...interface{})
+}
+
+type helper interface {
+	Helper()
+}
+
+// LoadBalancerExists asserts that the server has a load balancer with the
+// given name.
+func LoadBalancerExists(t TestingT, srv *elbtest.Server, name string) bool {
+	if h, ok := t.(helper); ok {
+		h.Helper()
+	}
+	_, ok := loadBalancer(t, srv.Snapshot(), name)
+	return ok
+}
+
+// LoadBalancerNotExists asserts that the server has no load balancer with the
+// given name.
+func LoadBalancerNotExists(t TestingT, srv *elbtest.Server, name string) bool {
+	if h, ok := t.(helper); ok {
+		h.Helper()
+	}
+	if _, ok := srv.Snapshot().LoadBalancers[name]; ok {
+		t.Errorf("load balancer %q exists", name)
+		return false
+	}
+	return true
+}
+
+// InstanceRegistered asserts that the instance is registered with the load
+// balancer.
+func InstanceRegistered(t TestingT, srv *elbtest.Server, lbName, instId string) bool {
+	if h, ok := t.(helper); ok {
+		h.Helper()
+	}
+	lb, ok := loadBalancer(t, srv.Snapshot(), lbName)
+	if !ok {
+		return false
+	}
+	ids := instanceIds(lb)
+	for _, id := range ids {
+		if id == instId {
+			return true
+		}
+	}
+	t.Errorf("instance %q is not registered with load balancer %q, registered instances: %s", instId, lbName, list(ids))
+	return false
+}
+
+// InstanceNotRegistered asserts that the instance isn't registered with the
+// load balancer.
+func InstanceNotRegistered(t TestingT, srv *elbtest.Server, lbName, instId string) bool {
+	if h, ok := t.(helper); ok {
+		h.Helper()
+	}
+	lb, ok := loadBalancer(t, srv.Snapshot(), lbName)
+	if !ok {
+		return false
+	}
+	for _, id := range instanceIds(lb) {
+		if id == instId {
+			t.Errorf("instance %q is registered with load balancer %q", instId, lbName)
+			return false
+		}
+	}
+	return true
+}
+
+// InstanceState asserts that the instance registered with the load balancer
+// is in the given state, like InService.
+func InstanceState(t TestingT, srv *elbtest.Server, lbName, instId, state string) bool {
+	if h, ok := t.(helper); ok {
+		h.Helper()
+	}
+	snapshot := srv.Snapshot()
+	if _, ok := loadBalancer(t, snapshot, lbName); !ok {
+		return false
+	}
+	for _, s := range snapshot.InstanceStates[lbName] {
+		if s.InstanceId == instId {
+			if s.State != state {
+				t.Errorf("instance %q of load balancer %q is %s, not %s", instId, lbName, s.State, state)
+				return false
+			}
+			return true
+		}
+	}
+	t.Errorf("instance %q is not registered with load balancer %q", instId, lbName)
+	return false
+}
+
+// Listener asserts that the load balancer has a listener on the given port
+// with the given protocol. The protocol is compared ignoring case, so "http"
+// matches a listener created with "HTTP".
+func Listener(t TestingT, srv *elbtest.Server, lbName string, port int, protocol string) bool {
+	if h, ok := t.(helper); ok {
+		h.Helper()
+	}
+	lb, ok := loadBalancer(t, srv.Snapshot(), lbName)
+	if !ok {
+		return false
+	}
+	listeners := make([]string, 0, len(lb.ListenerDescriptions))
+	for _, d := range lb.ListenerDescriptions {
+		if d.Listener.LoadBalancerPort == port {
+			if !strings.EqualFold(d.Listener.Protocol, protocol) {
+				t.Errorf("listener of load balancer %q on port %d uses %s, not %s", lbName, port, d.Listener.Protocol, protocol)
+				return false
+			}
+			return true
+		}
+		listeners = append(listeners, fmt.Sprintf("%s:%d", d.Listener.Protocol, d.Listener.LoadBalancerPort))
+	}
+	t.Errorf("load balancer %q has no listener on port %d, listeners: %s", lbName, port, list(listeners))
+	return false
+}
+
+func loadBalancer(t TestingT, state *elbtest.State, name string) (elb.LoadBalancerDescription, bool) {
+	if h, ok := t.(helper); ok {
+		h.Helper()
+	}
+	lb, ok := state.LoadBalancers[name]
+	if !ok {
+		names := make([]string, 0, len(state.LoadBalancers))
+		for n := range state.LoadBalancers {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		t.Errorf("load balancer %q does not exist, load balancers: %s", name, list(names))
+	}
+	return lb, ok
+}
+
+func instanceIds(lb elb.LoadBalancerDescription) []string {
+	ids := make([]string, len(lb.Instances))
+	for i, instance := range lb.Instances {
+		ids[i] = instance.InstanceId
+	}
+	return ids
+}
+
+func list(items []string) string {
+	if len(items) == 0 {
+		return "none"
+	}
+	return strings.Join(items, ", ")
+}
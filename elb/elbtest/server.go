@@ -4,52 +4,860 @@
 package elbtest
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"encoding/xml"
 	"fmt"
+	"github.com/flaviamissi/go-elb/aws"
 	"github.com/flaviamissi/go-elb/elb"
+	"io"
+	"io/ioutil"
+	"math/big"
 	"net"
 	"net/http"
 	"net/url"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 // Server implements an ELB simulator for use in testing.
 type Server struct {
 	url            string
 	listener       net.Listener
+	certificate    *x509.Certificate
 	mutex          sync.Mutex
 	reqId          int
-	lbs            map[string]*elb.LoadBalancerDescription
-	lbsReqs        map[string]url.Values
+	lbs            map[string]*loadBalancer
 	instances      []string
-	instanceStates map[string][]*elb.InstanceState
+	subnets        map[string]bool
+	securityGroups map[string]bool
+	certificates   map[string]bool
 	instCount      int
+	clock          Clock
+	createDelay    time.Duration
+	drainingUnit   time.Duration
+	maxListeners   int
+	limits         map[string]int
+	strict         bool
+	region         string
+	prepared       map[string]preparedError
+	malformed      map[string]bool
+	latency        map[string]time.Duration
+	rateLimits     map[string]int
+	requestTimes   map[string][]time.Time
+	operations     []Operation
+	requestHooks   []RequestHook
+	responseHooks  []ResponseHook
+	credentials    map[string]string
+	events         *eventQueue
 }
 
-// Starts and returns a new server
-func NewServer() (*Server, error) {
-	l, err := net.Listen("tcp", "localhost:0")
+// loadBalancer holds all the state of a load balancer, so adding or removing
+// a load balancer adds or removes all of it at once.
+type loadBalancer struct {
+	// desc is what DescribeLoadBalancers returns, including the
+	// listeners, zones, subnets and registered instances.
+	desc           elb.LoadBalancerDescription
+	instanceStates []*elb.InstanceState
+	attributes     *elb.LoadBalancerAttributes
+	policies       []*policy
+	// draining holds when the connection draining of each deregistered
+	// instance ends, keyed by instance id.
+	draining map[string]time.Time
+	tags     []elb.Tag
+}
+
+// newLoadBalancer returns the state of a new load balancer with the given
+// description, with the default attributes and nothing else.
+func newLoadBalancer(desc elb.LoadBalancerDescription) *loadBalancer {
+	return &loadBalancer{
+		desc:       desc,
+		attributes: defaultAttributes(),
+		draining:   make(map[string]time.Time),
+	}
+}
+
+// preparedError is an error that the server returns to the next times
+// requests of an action, see InjectError.
+type preparedError struct {
+	err    *elb.Error
+	header http.Header
+	times  int
+}
+
+// Options holds the options of a server, see NewServer.
+type Options struct {
+	// Addr is the TCP address the server listens on, like
+	// "0.0.0.0:8080" to be reachable from other hosts and containers on a
+	// fixed port. The default is "localhost:0": a random port of
+	// localhost.
+	Addr string
+}
+
+func listen(opts []Options) (net.Listener, error) {
+	addr := "localhost:0"
+	if len(opts) > 0 && opts[0].Addr != "" {
+		addr = opts[0].Addr
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot listen on %s: %v", addr, err)
+	}
+	return l, nil
+}
+
+// Starts and returns a new server. It takes at most one Options; without
+// one, the server listens on a random port of localhost.
+func NewServer(opts ...Options) (*Server, error) {
+	l, err := listen(opts)
+	if err != nil {
+		return nil, err
+	}
+	return serve(l, "http://"+l.Addr().String()), nil
+}
+
+// NewTLSServer starts and returns a new server that serves HTTPS, for
+// clients that require https:// endpoints. The server uses a self-signed
+// certificate for localhost and 127.0.0.1, which clients must trust, see
+// Certificate. The options are the same of NewServer.
+func NewTLSServer(opts ...Options) (*Server, error) {
+	cert, err := selfSignedCertificate()
+	if err != nil {
+		return nil, fmt.Errorf("cannot create the certificate of the server: %v", err)
+	}
+	l, err := listen(opts)
 	if err != nil {
-		return nil, fmt.Errorf("cannot listen on localhost: %v", err)
+		return nil, err
 	}
+	l = tls.NewListener(l, &tls.Config{Certificates: []tls.Certificate{cert}})
+	srv := serve(l, "https://"+l.Addr().String())
+	srv.certificate = cert.Leaf
+	return srv, nil
+}
+
+func serve(l net.Listener, endpoint string) *Server {
 	srv := &Server{
-		listener:       l,
-		url:            "http://" + l.Addr().String(),
-		lbs:            make(map[string]*elb.LoadBalancerDescription),
-		instanceStates: make(map[string][]*elb.InstanceState),
+		listener: l,
+		url:      endpoint,
+		events:   newEventQueue(),
 	}
+	srv.reset()
 	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		srv.serveHTTP(w, req)
 	}))
-	return srv, nil
+	return srv
+}
+
+// selfSignedCertificate creates a certificate for localhost, signed by
+// itself.
+func selfSignedCertificate() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{"elbtest"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
+
+// Certificate returns the certificate of a server started with
+// NewTLSServer, or nil for servers started with NewServer. Clients trust
+// the server by adding it to the RootCAs of their TLS configuration.
+func (srv *Server) Certificate() *x509.Certificate {
+	return srv.certificate
+}
+
+// Clock tells the current time to the server. The creation time of load
+// balancers, the create consistency delay and connection draining are all
+// based on it, see SetClock.
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// SetClock replaces the clock of the server, so tests can control the
+// passing of time instead of sleeping. A nil clock restores the system
+// clock, which is the default.
+func (srv *Server) SetClock(clock Clock) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	if clock == nil {
+		clock = realClock{}
+	}
+	srv.clock = clock
+}
+
+// Reset brings the server back to the state it had when it was started, so
+// it can be shared by the tests of a suite: it removes all the load
+// balancers, instances, subnets, security groups, certificates, policies and
+// tags, forgets the recorded operations, and restores the defaults of all
+// the settings, including the injected errors, latencies, rate limits,
+// hooks and the clock. The URL of the server is kept.
+func (srv *Server) Reset() {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	srv.reset()
+}
+
+func (srv *Server) reset() {
+	srv.events.clear()
+	srv.reqId = 0
+	srv.lbs = make(map[string]*loadBalancer)
+	srv.instances = nil
+	srv.subnets = make(map[string]bool)
+	srv.securityGroups = make(map[string]bool)
+	srv.certificates = make(map[string]bool)
+	srv.instCount = 0
+	srv.clock = realClock{}
+	srv.createDelay = 0
+	srv.drainingUnit = time.Second
+	srv.maxListeners = defaultMaxListeners
+	srv.limits = map[string]int{"classic-load-balancers": 20, "classic-registered-instances": 1000}
+	srv.strict = false
+	srv.region = defaultRegion
+	srv.prepared = make(map[string]preparedError)
+	srv.malformed = make(map[string]bool)
+	srv.latency = make(map[string]time.Duration)
+	srv.rateLimits = make(map[string]int)
+	srv.requestTimes = make(map[string][]time.Time)
+	srv.operations = nil
+	srv.requestHooks = nil
+	srv.responseHooks = nil
+	srv.credentials = nil
+}
+
+// SetCreateConsistencyDelay simulates the eventual consistency of
+// CreateLoadBalancer: a load balancer created through the API is hidden from
+// DescribeLoadBalancers until the given delay has passed since its creation.
+// The default delay is zero.
+func (srv *Server) SetCreateConsistencyDelay(d time.Duration) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	srv.createDelay = d
+}
+
+// SetConnectionDrainingUnit sets how long a second of connection draining
+// lasts, so tests don't have to wait for the actual timeout: with a unit of
+// a millisecond, the default timeout of 300 seconds lasts 300 milliseconds.
+// The default unit is a second.
+//
+// When connection draining is enabled in the attributes of a load balancer,
+// deregistered instances are kept in its DescribeInstanceHealth until the
+// timeout has passed, as InService unless their health is changed with
+// SetInstanceHealth.
+func (srv *Server) SetConnectionDrainingUnit(d time.Duration) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	srv.drainingUnit = d
+}
+
+// PrepareError makes the server fail the next request of the given action
+// with the given error, instead of handling it. Only the next request fails,
+// the following ones are handled as usual.
+func (srv *Server) PrepareError(action string, err *elb.Error) {
+	srv.InjectError(action, err, 1)
+}
+
+// InjectError makes the server fail the next times requests of the given
+// action with the given error, instead of handling them, so tests can
+// exercise retries: after the given number of failures, the requests are
+// handled as usual. A times of zero or less removes the error injected for
+// the action, if any. It replaces the error prepared with PrepareError or
+// PrepareThrottling.
+func (srv *Server) InjectError(action string, err *elb.Error, times int) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	if times <= 0 {
+		delete(srv.prepared, action)
+		return
+	}
+	srv.prepared[action] = preparedError{err: err, times: times}
+}
+
+// retryAfterSeconds formats a delay for the Retry-After header, which is
+// given in whole seconds: the delay is rounded up, to at least one second.
+func retryAfterSeconds(d time.Duration) string {
+	seconds := int((d + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return strconv.Itoa(seconds)
+}
+
+// PrepareThrottling makes the server throttle the next request of the given
+// action, returning the Throttling error with a Retry-After header holding
+// the given delay in seconds, rounded up to at least one second. A negative
+// delay omits the header.
+func (srv *Server) PrepareThrottling(action string, retryAfter time.Duration) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	header := make(http.Header)
+	if retryAfter >= 0 {
+		header.Set("Retry-After", retryAfterSeconds(retryAfter))
+	}
+	srv.prepared[action] = preparedError{
+		err: &elb.Error{
+			StatusCode: 400,
+			Code:       "Throttling",
+			Message:    "Rate exceeded",
+		},
+		header: header,
+		times:  1,
+	}
+}
+
+// SetLatency makes the server wait for the given duration before handling
+// each request of the given action, simulating a slow ELB. An empty action
+// sets the latency of the actions without one of their own, which is zero
+// by default. Requests waiting don't block the other requests.
+func (srv *Server) SetLatency(action string, d time.Duration) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	srv.latency[action] = d
+}
+
+func (srv *Server) latencyOf(action string) time.Duration {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	if d, ok := srv.latency[action]; ok {
+		return d
+	}
+	return srv.latency[""]
+}
+
+// SetRateLimit limits the requests of the given action to rps requests per
+// second: like in ELB, the requests beyond the limit fail with Throttling
+// and a Retry-After header, without being handled. An empty action sets the
+// limit of the actions without one of their own, each action being limited
+// on its own. A limit of zero or less removes the limit, which is the
+// default. Time is told by the clock of the server, see SetClock.
+func (srv *Server) SetRateLimit(action string, rps int) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	// the requests are counted from the time the limit is set.
+	if action == "" {
+		srv.requestTimes = make(map[string][]time.Time)
+	} else {
+		delete(srv.requestTimes, action)
+	}
+	if rps <= 0 {
+		delete(srv.rateLimits, action)
+		return
+	}
+	srv.rateLimits[action] = rps
+}
+
+// throttle records a request of the given action, returning the time to
+// wait before sending it again if it exceeds the rate limit of the action.
+func (srv *Server) throttle(action string) (time.Duration, bool) {
+	rps, ok := srv.rateLimits[action]
+	if !ok {
+		rps = srv.rateLimits[""]
+	}
+	if rps <= 0 {
+		return 0, false
+	}
+	now := srv.clock.Now()
+	times := srv.requestTimes[action]
+	for len(times) > 0 && !times[0].Add(time.Second).After(now) {
+		times = times[1:]
+	}
+	if len(times) >= rps {
+		srv.requestTimes[action] = times
+		return times[0].Add(time.Second).Sub(now), true
+	}
+	srv.requestTimes[action] = append(times, now)
+	return 0, false
+}
+
+// SetCredentials makes the server verify the signature of every request,
+// which must be signed with one of the given credentials using either
+// Signature Version 2, like the elb package does, or Signature Version 4 in
+// the Authorization header, like the AWS SDKs do. Requests without a known
+// access key fail with AuthFailure, and requests with a wrong signature fail
+// with SignatureDoesNotMatch. Without credentials, which is the default,
+// signatures aren't verified.
+func (srv *Server) SetCredentials(credentials ...aws.Auth) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	srv.credentials = nil
+	if len(credentials) > 0 {
+		srv.credentials = make(map[string]string, len(credentials))
+		for _, auth := range credentials {
+			srv.credentials[auth.AccessKey] = auth.SecretKey
+		}
+	}
+}
+
+// authenticate verifies the signature of a request, see SetCredentials.
+func (srv *Server) authenticate(req *http.Request, payload []byte) *elb.Error {
+	srv.mutex.Lock()
+	credentials := srv.credentials
+	srv.mutex.Unlock()
+	if credentials == nil {
+		return nil
+	}
+	var accessKey, signature, expected string
+	if auth := req.Header.Get("Authorization"); strings.HasPrefix(auth, "AWS4-HMAC-SHA256 ") {
+		fields := parseAuthorization(strings.TrimPrefix(auth, "AWS4-HMAC-SHA256 "))
+		scope := strings.SplitN(fields["Credential"], "/", 2)
+		accessKey, signature = scope[0], fields["Signature"]
+		if secret, ok := credentials[accessKey]; ok && len(scope) == 2 {
+			expected = signV4(req, payload, secret, scope[1], strings.Split(fields["SignedHeaders"], ";"))
+		}
+	} else {
+		accessKey, signature = req.Form.Get("AWSAccessKeyId"), req.Form.Get("Signature")
+		if secret, ok := credentials[accessKey]; ok {
+			expected = signV2(req, secret)
+		}
+	}
+	if _, ok := credentials[accessKey]; !ok {
+		return &elb.Error{
+			StatusCode: 403,
+			Code:       "AuthFailure",
+			Message:    "AWS was not able to validate the provided access credentials",
+		}
+	}
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return &elb.Error{
+			StatusCode: 403,
+			Code:       "SignatureDoesNotMatch",
+			Message:    "The request signature we calculated does not match the signature you provided. Check your AWS Secret Access Key and signing method.",
+		}
+	}
+	return nil
+}
+
+// signV2 returns the Signature Version 2 of a request.
+func signV2(req *http.Request, secret string) string {
+	var keys, pairs []string
+	for k := range req.Form {
+		if k != "Signature" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		pairs = append(pairs, aws.Encode(k)+"="+aws.Encode(req.Form.Get(k)))
+	}
+	path := req.URL.Path
+	if path == "" {
+		path = "/"
+	}
+	payload := req.Method + "\n" + req.Host + "\n" + path + "\n" + strings.Join(pairs, "&")
+	hash := hmac.New(sha256.New, []byte(secret))
+	if req.Form.Get("SignatureMethod") == "HmacSHA1" {
+		hash = hmac.New(sha1.New, []byte(secret))
+	}
+	hash.Write([]byte(payload))
+	return base64.StdEncoding.EncodeToString(hash.Sum(nil))
+}
+
+// signV4 returns the Signature Version 4 of a request, given the scope of
+// its credential, like 20150830/us-east-1/elasticloadbalancing/aws4_request.
+func signV4(req *http.Request, payload []byte, secret, scope string, signedHeaders []string) string {
+	query := req.URL.Query()
+	var keys, pairs []string
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, aws.Encode(k)+"="+aws.Encode(v))
+		}
+	}
+	var headers []string
+	for _, name := range signedHeaders {
+		value := strings.Join(req.Header[http.CanonicalHeaderKey(name)], ",")
+		if name == "host" {
+			value = req.Host
+		}
+		headers = append(headers, name+":"+strings.TrimSpace(value)+"\n")
+	}
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonical := strings.Join([]string{
+		req.Method,
+		path,
+		strings.Join(pairs, "&"),
+		strings.Join(headers, ""),
+		strings.Join(signedHeaders, ";"),
+		hexSHA256(payload),
+	}, "\n")
+	date := req.Header.Get("X-Amz-Date")
+	toSign := "AWS4-HMAC-SHA256\n" + date + "\n" + scope + "\n" + hexSHA256([]byte(canonical))
+	key := []byte("AWS4" + secret)
+	for _, part := range strings.Split(scope, "/") {
+		key = hmacSHA256(key, part)
+	}
+	return hex.EncodeToString(hmacSHA256(key, toSign))
+}
+
+// parseAuthorization parses the fields of a Signature Version 4
+// Authorization header, like Credential=..., SignedHeaders=...,
+// Signature=....
+func parseAuthorization(auth string) map[string]string {
+	fields := make(map[string]string)
+	for _, field := range strings.Split(auth, ",") {
+		if kv := strings.SplitN(strings.TrimSpace(field), "=", 2); len(kv) == 2 {
+			fields[kv[0]] = kv[1]
+		}
+	}
+	return fields
+}
+
+func hexSHA256(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	hash := hmac.New(sha256.New, key)
+	hash.Write([]byte(data))
+	return hash.Sum(nil)
+}
+
+// EventType is the type of a change of the state of the server, see Events.
+type EventType string
+
+const (
+	// LBCreated is sent when a Load Balancer is created, through the API
+	// or with NewLoadBalancer or AddLoadBalancer.
+	LBCreated EventType = "LBCreated"
+	// LBDeleted is sent when a Load Balancer is deleted.
+	LBDeleted EventType = "LBDeleted"
+	// InstanceRegistered is sent when an instance is registered with a
+	// Load Balancer.
+	InstanceRegistered EventType = "InstanceRegistered"
+	// InstanceDeregistered is sent when an instance is deregistered from a
+	// Load Balancer.
+	InstanceDeregistered EventType = "InstanceDeregistered"
+	// InstanceStateChanged is sent when the health of an instance
+	// registered with a Load Balancer is changed, see SetInstanceState.
+	InstanceStateChanged EventType = "InstanceStateChanged"
+)
+
+// Event is a change of the state of the server.
+type Event struct {
+	Type EventType
+	// LoadBalancer is the name of the Load Balancer that changed.
+	LoadBalancer string
+	// InstanceId is the id of the instance of the instance events.
+	InstanceId string
+	// State is the new state of the instance of InstanceStateChanged
+	// events, like InService.
+	State string
+}
+
+// Events returns the channel the server sends its events on, the same
+// channel for every call. Events are sent in the order they happen, and are
+// kept until they are received, so the server never blocks on a test that
+// doesn't read them. Events that happened before the first call of Events
+// aren't sent, and neither are the events not yet received when the server
+// is reset. The channel is closed by Quit.
+func (srv *Server) Events() <-chan Event {
+	return srv.events.channel()
+}
+
+func (srv *Server) emit(e Event) {
+	srv.events.push(e)
+}
+
+// eventQueue holds the events not yet received from the channel returned by
+// Events.
+type eventQueue struct {
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	pending []Event
+	out     chan Event
+	done    bool
+	quit    chan struct{}
+	// cleared is closed by clear, so the event being sent is dropped too.
+	cleared chan struct{}
+}
+
+func newEventQueue() *eventQueue {
+	q := &eventQueue{quit: make(chan struct{}), cleared: make(chan struct{})}
+	q.cond = sync.NewCond(&q.mutex)
+	return q
+}
+
+func (q *eventQueue) channel() <-chan Event {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.out == nil {
+		q.out = make(chan Event)
+		if q.done {
+			close(q.out)
+		} else {
+			go q.send()
+		}
+	}
+	return q.out
+}
+
+func (q *eventQueue) push(e Event) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.out == nil || q.done {
+		return
+	}
+	q.pending = append(q.pending, e)
+	q.cond.Signal()
+}
+
+// clear drops the events not yet received.
+func (q *eventQueue) clear() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.pending = nil
+	close(q.cleared)
+	q.cleared = make(chan struct{})
+}
+
+// close stops sending events and closes the channel, dropping the events not
+// yet received.
+func (q *eventQueue) close() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.done {
+		return
+	}
+	q.done = true
+	q.pending = nil
+	close(q.quit)
+	q.cond.Broadcast()
+}
+
+func (q *eventQueue) send() {
+	defer close(q.out)
+	for {
+		q.mutex.Lock()
+		for len(q.pending) == 0 && !q.done {
+			q.cond.Wait()
+		}
+		if q.done {
+			q.mutex.Unlock()
+			return
+		}
+		e := q.pending[0]
+		q.pending = q.pending[1:]
+		cleared := q.cleared
+		q.mutex.Unlock()
+		select {
+		case q.out <- e:
+		case <-cleared:
+		case <-q.quit:
+			return
+		}
+	}
+}
+
+// RequestHook is called with every request before the server handles it.
+// It may change the parameters of the request, in req.Form, or veto it by
+// returning an error, which is sent instead of handling the request.
+type RequestHook func(action string, req *http.Request) *elb.Error
+
+// ResponseHook is called with every request after the server has sent its
+// response.
+type ResponseHook func(op Operation)
+
+// AddRequestHook adds a hook called with every request before it is
+// handled. Hooks are called in the order they were added, until one of them
+// vetoes the request. They are called without holding the server lock, so
+// they may use the other methods of the server.
+func (srv *Server) AddRequestHook(hook RequestHook) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	srv.requestHooks = append(srv.requestHooks, hook)
+}
+
+// AddResponseHook adds a hook called with every request after its response
+// was sent. Hooks are called in the order they were added, without holding
+// the server lock.
+func (srv *Server) AddResponseHook(hook ResponseHook) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	srv.responseHooks = append(srv.responseHooks, hook)
+}
+
+// ClearHooks removes the hooks added with AddRequestHook and
+// AddResponseHook.
+func (srv *Server) ClearHooks() {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	srv.requestHooks = nil
+	srv.responseHooks = nil
+}
+
+func (srv *Server) hooks() ([]RequestHook, []ResponseHook) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	return srv.requestHooks, srv.responseHooks
+}
+
+// SetMalformedResponse makes the server answer every request of the given
+// action with a truncated XML document and the status 200, like a
+// misbehaving proxy would, instead of handling it. It takes precedence over
+// the errors prepared with PrepareError.
+func (srv *Server) SetMalformedResponse(action string) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	srv.malformed[action] = true
+}
+
+// ClearMalformedResponse makes the server handle the requests of the given
+// action as usual again, see SetMalformedResponse.
+func (srv *Server) ClearMalformedResponse(action string) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	delete(srv.malformed, action)
+}
+
+// SetStrict sets whether the server enforces the same validation rules as
+// ELB, or accepts anything it can make sense of, which is the default.
+//
+// In strict mode the server also checks that:
+//
+//   - subnets and security groups are registered with NewSubnet and
+//     NewSecurityGroup;
+//   - SSL certificates of listeners are registered with NewCertificate;
+//   - listeners forward HTTP and HTTPS to HTTP or HTTPS, and TCP and SSL to
+//     TCP or SSL;
+//   - requests only have the parameters documented for their action, with
+//     values of the documented type, and their lists are numbered from 1
+//     with no gaps, like Listeners.member.1, Listeners.member.2.
+//
+// Names of load balancers, protocols, ports and the syntax of SSL
+// certificates of listeners are always validated, and so are conflicting
+// listeners on the same load balancer port, the number of listeners set with
+// SetMaxListeners and the number of load balancers set with
+// SetMaxLoadBalancers.
+func (srv *Server) SetStrict(strict bool) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	srv.strict = strict
+}
+
+// defaultRegion is the region of the DNS names of Load Balancers whose region
+// can't be told from their availability zones, see SetRegion.
+const defaultRegion = "us-east-1"
+
+// SetRegion sets the region used in the DNS names of Load Balancers created
+// in subnets or with NewLoadBalancer. Load Balancers created in availability
+// zones get the region of their first zone instead, so a Load Balancer in
+// sa-east-1a is named like lbname-some-aws-stuff.sa-east-1.elb.amazonaws.com.
+// The default region is us-east-1.
+func (srv *Server) SetRegion(region string) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	srv.region = region
+}
+
+var zoneRegexp = regexp.MustCompile(`^([a-z]{2}(-gov)?-[a-z]+-\d)[a-z]$`)
+
+// dnsName returns the public DNS name of the given Load Balancer, in the
+// region of the first of its availability zones, or in the region of the
+// server if there are no zones.
+func (srv *Server) dnsName(lbName string, zones []string) string {
+	region := srv.region
+	if len(zones) > 0 {
+		if m := zoneRegexp.FindStringSubmatch(zones[0]); m != nil {
+			region = m[1]
+		}
+	}
+	return fmt.Sprintf("%s-some-aws-stuff.%s.elb.amazonaws.com", lbName, region)
+}
+
+// hostedZoneId is the id of the Route 53 hosted zone of the Load Balancers
+// in us-east-1.
+const hostedZoneId = "Z3DZXE0Q79N41H"
+
+// defaultMaxListeners is the number of listeners a Load Balancer can have in
+// AWS.
+const defaultMaxListeners = 100
+
+// SetMaxListeners sets the maximum number of listeners a Load Balancer can
+// have. Creating a Load Balancer or listeners beyond the limit fails with
+// InvalidConfigurationRequest. The default limit is 100, the same as AWS.
+func (srv *Server) SetMaxListeners(n int) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	srv.maxListeners = n
+}
+
+// SetMaxLoadBalancers sets the maximum number of Load Balancers. Creating a
+// Load Balancer beyond the limit fails with TooManyLoadBalancers. The default
+// limit is 20, the same as AWS.
+func (srv *Server) SetMaxLoadBalancers(n int) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	srv.limits["classic-load-balancers"] = n
+}
+
+// SetAccountLimit sets the maximum of a resource, as returned by
+// DescribeAccountLimits. Only the limits of classic-load-balancers and
+// classic-listeners are enforced, see SetMaxLoadBalancers and
+// SetMaxListeners. The defaults are the same as AWS: 20
+// classic-load-balancers, 100 classic-listeners and 1000
+// classic-registered-instances.
+func (srv *Server) SetAccountLimit(name string, max int) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	if name == "classic-listeners" {
+		srv.maxListeners = max
+		return
+	}
+	srv.limits[name] = max
 }
 
 // Quit closes down the server.
 func (srv *Server) Quit() {
 	srv.listener.Close()
+	srv.events.close()
 }
 
 // URL returns the URL of the server.
@@ -57,160 +865,1526 @@ func (srv *Server) URL() string {
 	return srv.url
 }
 
+// xmlErrors is the envelope of the errors returned by ELB.
 type xmlErrors struct {
-	XMLName string `xml:"ErrorResponse"`
-	Error   elb.Error
+	XMLName xml.Name `xml:"http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/ ErrorResponse"`
+	Error   struct {
+		Type    string
+		Code    string
+		Message string
+	}
+	RequestId string
 }
 
-func (srv *Server) error(w http.ResponseWriter, err *elb.Error) {
+func (srv *Server) error(w http.ResponseWriter, err *elb.Error, reqId string) {
 	w.WriteHeader(err.StatusCode)
-	xmlErr := xmlErrors{Error: *err}
+	xmlErr := xmlErrors{RequestId: reqId}
+	xmlErr.Error.Type = "Sender"
+	if err.StatusCode >= 500 {
+		xmlErr.Error.Type = "Receiver"
+	}
+	xmlErr.Error.Code = err.Code
+	xmlErr.Error.Message = err.Message
 	if e := xml.NewEncoder(w).Encode(xmlErr); e != nil {
 		panic(e)
 	}
 }
 
 func (srv *Server) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	// the body is kept for the signature of the request, see
+	// SetCredentials.
+	payload, _ := ioutil.ReadAll(req.Body)
+	req.Body = ioutil.NopCloser(bytes.NewReader(payload))
 	req.ParseForm()
-	srv.mutex.Lock()
-	defer srv.mutex.Unlock()
-	f := actions[req.Form.Get("Action")]
-	if f == nil {
-		srv.error(w, &elb.Error{
-			StatusCode: 400,
-			Code:       "InvalidParameterValue",
-			Message:    "Unrecognized Action",
-		})
+	if d := srv.latencyOf(req.Form.Get("Action")); d > 0 {
+		time.Sleep(d)
+	}
+	requestHooks, responseHooks := srv.hooks()
+	vetoed := srv.authenticate(req, payload)
+	for _, hook := range requestHooks {
+		if vetoed != nil {
+			break
+		}
+		vetoed = hook(req.Form.Get("Action"), req)
+	}
+	op := srv.handle(w, req, vetoed)
+	for _, hook := range responseHooks {
+		hook(op)
 	}
+}
+
+// handle handles a request, failing it with the given error when it wasn't
+// authenticated or was vetoed by a RequestHook, and returns the operation
+// recorded for it.
+func (srv *Server) handle(w http.ResponseWriter, req *http.Request, vetoed *elb.Error) Operation {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
 	reqId := fmt.Sprintf("req%0X", srv.reqId)
 	srv.reqId++
-	if resp, err := f(srv, w, req, reqId); err == nil {
-		if err := xml.NewEncoder(w).Encode(resp); err != nil {
+	if vetoed != nil {
+		srv.error(w, vetoed, reqId)
+		return srv.record(req, reqId, nil, vetoed)
+	}
+	f := actions[req.Form.Get("Action")]
+	if f == nil {
+		err := &elb.Error{
+			StatusCode: 400,
+			Code:       "InvalidParameterValue",
+			Message:    "Unrecognized Action",
+		}
+		srv.error(w, err, reqId)
+		return srv.record(req, reqId, nil, err)
+	}
+	if wait, throttled := srv.throttle(req.Form.Get("Action")); throttled {
+		err := &elb.Error{
+			StatusCode: 400,
+			Code:       "Throttling",
+			Message:    "Rate exceeded",
+		}
+		w.Header().Set("Retry-After", retryAfterSeconds(wait))
+		srv.error(w, err, reqId)
+		return srv.record(req, reqId, nil, err)
+	}
+	if action := req.Form.Get("Action"); srv.malformed[action] {
+		fmt.Fprintf(w, `<%sResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/">
+<%sResult>
+<`, action, action)
+		return srv.record(req, reqId, nil, nil)
+	}
+	if prepared, ok := srv.prepared[req.Form.Get("Action")]; ok {
+		if prepared.times--; prepared.times == 0 {
+			delete(srv.prepared, req.Form.Get("Action"))
+		} else {
+			srv.prepared[req.Form.Get("Action")] = prepared
+		}
+		for k, v := range prepared.header {
+			w.Header()[k] = v
+		}
+		srv.error(w, prepared.err, reqId)
+		return srv.record(req, reqId, nil, prepared.err)
+	}
+	if err := srv.validateParams(req); err != nil {
+		e := err.(*elb.Error)
+		srv.error(w, e, reqId)
+		return srv.record(req, reqId, nil, e)
+	}
+	resp, err := f(srv, w, req, reqId)
+	if err != nil {
+		e, ok := err.(*elb.Error)
+		if !ok {
 			panic(err)
 		}
+		srv.error(w, e, reqId)
+		return srv.record(req, reqId, nil, e)
+	}
+	if err := encodeResponse(w, req.Form.Get("Action"), reqId, resp); err != nil {
+		panic(err)
+	}
+	return srv.record(req, reqId, resp, nil)
+}
+
+// xmlns is the namespace of the responses of ELB.
+const xmlns = "http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/"
+
+// encodeResponse writes the given response in the envelope used by ELB:
+//
+//	<ActionResponse xmlns="...">
+//	    <ActionResult>...</ActionResult>
+//	    <ResponseMetadata><RequestId>...</RequestId></ResponseMetadata>
+//	</ActionResponse>
+//
+// Whatever the root element and the metadata of the encoded response are,
+// they are replaced, and an empty result is added to responses without one.
+func encodeResponse(w io.Writer, action, reqId string, resp interface{}) error {
+	b, err := xml.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	d := xml.NewDecoder(bytes.NewReader(b))
+	if _, err := d.Token(); err != nil {
+		return err
+	}
+	e := xml.NewEncoder(w)
+	result := action + "Result"
+	e.EncodeToken(xml.StartElement{Name: xml.Name{Space: xmlns, Local: action + "Response"}})
+	hasResult := false
+	for depth := 1; ; {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := t.(type) {
+		case xml.StartElement:
+			if depth == 1 && t.Name.Local == "ResponseMetadata" {
+				if err := d.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			if depth == 1 && t.Name.Local == result {
+				hasResult = true
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+		if depth == 0 {
+			break
+		}
+		if err := e.EncodeToken(xml.CopyToken(t)); err != nil {
+			return err
+		}
+	}
+	if !hasResult {
+		e.EncodeToken(xml.StartElement{Name: xml.Name{Local: result}})
+		e.EncodeToken(xml.EndElement{Name: xml.Name{Local: result}})
+	}
+	metadata := struct {
+		RequestId string `xml:"RequestId"`
+	}{reqId}
+	if err := e.EncodeElement(metadata, xml.StartElement{Name: xml.Name{Local: "ResponseMetadata"}}); err != nil {
+		return err
+	}
+	e.EncodeToken(xml.EndElement{Name: xml.Name{Space: xmlns, Local: action + "Response"}})
+	return e.Flush()
+}
+
+// Operation is a request handled by the server, see Operations.
+type Operation struct {
+	// Action is the name of the action, like CreateLoadBalancer.
+	Action string
+	// Params holds all the parameters of the request, including Action.
+	Params url.Values
+	// RequestId is the id of the request, as sent in the response.
+	RequestId string
+	// Response is the response sent by the server, or nil when the
+	// request failed or the response was malformed, see
+	// SetMalformedResponse.
+	Response interface{}
+	// Err is the error sent by the server, or nil when the request
+	// succeeded.
+	Err *elb.Error
+}
+
+func (srv *Server) record(req *http.Request, reqId string, resp interface{}, err *elb.Error) Operation {
+	params := make(url.Values, len(req.Form))
+	for k, v := range req.Form {
+		params[k] = append([]string(nil), v...)
+	}
+	op := Operation{
+		Action:    req.Form.Get("Action"),
+		Params:    params,
+		RequestId: reqId,
+		Response:  resp,
+		Err:       err,
+	}
+	srv.operations = append(srv.operations, op)
+	return op
+}
+
+// Operations returns the requests handled by the server, including the
+// failed ones, in the order they were handled, since the server was started
+// or ClearOperations was last called.
+func (srv *Server) Operations() []Operation {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	return append([]Operation(nil), srv.operations...)
+}
+
+// ClearOperations forgets the requests handled by the server so far, see
+// Operations.
+func (srv *Server) ClearOperations() {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	srv.operations = nil
+}
+
+func (srv *Server) createLoadBalancer(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
+	composition := map[string]string{
+		"AvailabilityZones.member.1": "Subnets.member.1",
+	}
+	if err := srv.validateComposition(req, composition); err != nil {
+		return nil, err
+	}
+	required := []string{
+		"Listeners.member.1.InstancePort",
+		"Listeners.member.1.InstanceProtocol",
+		"Listeners.member.1.Protocol",
+		"Listeners.member.1.LoadBalancerPort",
+		"LoadBalancerName",
+	}
+	if err := srv.validate(req, required); err != nil {
+		return nil, err
+	}
+	if err := srv.validateLoadBalancerName(req.FormValue("LoadBalancerName")); err != nil {
+		return nil, err
+	}
+	if err := srv.validateListeners(nil, srv.makeListenerDescriptions(req.Form)); err != nil {
+		return nil, err
+	}
+	if err := srv.validateSubnets(srv.getParameters("Subnets.member.", req.Form), "InvalidSubnet", "Invalid subnet: %s"); err != nil {
+		return nil, err
+	}
+	if err := srv.validateSecurityGroups(srv.getParameters("SecurityGroups.member.", req.Form)); err != nil {
+		return nil, err
+	}
+	if err := validateScheme(req); err != nil {
+		return nil, err
+	}
+	path := req.FormValue("Path")
+	if path == "" {
+		path = "/"
+	}
+	lbName := req.FormValue("LoadBalancerName")
+	desc := srv.makeLoadBalancerDescription(req.Form)
+	if lb, ok := srv.lbs[lbName]; ok {
+		// like in ELB, creating a load balancer again with the same
+		// definition succeeds and changes nothing.
+		if !sameDefinition(&lb.desc, desc) {
+			return nil, &elb.Error{
+				StatusCode: 400,
+				Code:       "DuplicateLoadBalancerName",
+				Message:    fmt.Sprintf("Load Balancer named %s already exists and it is configured with different parameters.", lbName),
+			}
+		}
+		return elb.CreateLoadBalancerResp{DNSName: lb.desc.DNSName}, nil
+	}
+	if max := srv.limits["classic-load-balancers"]; len(srv.lbs) >= max {
+		return nil, &elb.Error{
+			StatusCode: 400,
+			Code:       "TooManyLoadBalancers",
+			Message:    fmt.Sprintf("Exceeded quota of account: at most %d Load Balancers can be created", max),
+		}
+	}
+	desc.CreatedTime = srv.clock.Now().UTC()
+	desc.DNSName = srv.dnsName(lbName, desc.AvailZones)
+	if desc.Scheme == "internal" {
+		// like in ELB, internal load balancers only have a private DNS
+		// name, which is not in a public hosted zone.
+		desc.DNSName = "internal-" + desc.DNSName
 	} else {
-		switch err.(type) {
-		case *elb.Error:
-			srv.error(w, err.(*elb.Error))
-		default:
-			panic(err)
+		desc.CanonicalHostedZoneName = desc.DNSName
+	}
+	desc.CanonicalHostedZoneNameId = hostedZoneId
+	srv.lbs[lbName] = newLoadBalancer(*desc)
+	srv.emit(Event{Type: LBCreated, LoadBalancer: lbName})
+	return elb.CreateLoadBalancerResp{
+		DNSName: desc.DNSName,
+	}, nil
+}
+
+// sameDefinition returns whether the given load balancers have the same
+// definition in CreateLoadBalancer: listeners, zones, subnets, security
+// groups and scheme.
+func sameDefinition(lb, other *elb.LoadBalancerDescription) bool {
+	if len(lb.ListenerDescriptions) != len(other.ListenerDescriptions) {
+		return false
+	}
+	for i := range lb.ListenerDescriptions {
+		if lb.ListenerDescriptions[i].Listener != other.ListenerDescriptions[i].Listener {
+			return false
+		}
+	}
+	return sameStrings(lb.AvailZones, other.AvailZones) &&
+		sameStrings(lb.Subnets, other.Subnets) &&
+		sameStrings(lb.SecurityGroups, other.SecurityGroups) &&
+		lb.Scheme == other.Scheme
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// validateScheme checks the scheme of a CreateLoadBalancer request. Only load
+// balancers in a VPC can be internal.
+func validateScheme(req *http.Request) error {
+	switch req.FormValue("Scheme") {
+	case "", "internet-facing":
+		return nil
+	case "internal":
+		if req.FormValue("Subnets.member.1") == "" {
+			return &elb.Error{
+				StatusCode: 400,
+				Code:       "InvalidConfigurationRequest",
+				Message:    "The internal scheme is only available to load balancers in a VPC",
+			}
+		}
+		return nil
+	}
+	return &elb.Error{
+		StatusCode: 400,
+		Code:       "ValidationError",
+		Message:    fmt.Sprintf("Invalid value '%s' for Scheme", req.FormValue("Scheme")),
+	}
+}
+
+// deleteLoadBalancer deletes a Load Balancer and everything associated with
+// it. Like in ELB, deleting a Load Balancer that does not exist succeeds.
+func (srv *Server) deleteLoadBalancer(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
+	if err := srv.validate(req, []string{"LoadBalancerName"}); err != nil {
+		return nil, err
+	}
+	srv.RemoveLoadBalancer(req.FormValue("LoadBalancerName"))
+	return elb.SimpleResp{RequestId: reqId}, nil
+}
+
+func (srv *Server) registerInstancesWithLoadBalancer(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
+	required := []string{"LoadBalancerName", "Instances.member.1.InstanceId"}
+	if err := srv.validate(req, required); err != nil {
+		return nil, err
+	}
+	lbName := req.FormValue("LoadBalancerName")
+	if err := srv.lbExists(lbName); err != nil {
+		return nil, err
+	}
+	instIds := []string{}
+	i := 1
+	instId := req.FormValue(fmt.Sprintf("Instances.member.%d.InstanceId", i))
+	for instId != "" {
+		if err := srv.instanceExists(instId); err != nil {
+			return nil, err
+		}
+		instIds = append(instIds, instId)
+		i++
+		instId = req.FormValue(fmt.Sprintf("Instances.member.%d.InstanceId", i))
+	}
+	for _, id := range instIds {
+		srv.RegisterInstance(id, lbName)
+	}
+	// like ELB, respond with all the instances of the Load Balancer, not
+	// only the ones just registered.
+	registered := []string{}
+	for _, instance := range srv.lbs[lbName].desc.Instances {
+		registered = append(registered, instance.InstanceId)
+	}
+	return elb.RegisterInstancesResp{InstanceIds: registered}, nil
+}
+
+func (srv *Server) deregisterInstancesFromLoadBalancer(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
+	required := []string{"LoadBalancerName"}
+	if err := srv.validate(req, required); err != nil {
+		return nil, err
+	}
+	lbName := req.FormValue("LoadBalancerName")
+	if err := srv.lbExists(lbName); err != nil {
+		return nil, err
+	}
+	i := 1
+	lb := &srv.lbs[lbName].desc
+	instId := req.FormValue(fmt.Sprintf("Instances.member.%d.InstanceId", i))
+	for instId != "" {
+		if err := srv.instanceExists(instId); err != nil {
+			return nil, err
+		}
+		i++
+		if srv.isRegistered(lb, instId) {
+			removeInstanceFromLB(lb, instId)
+			srv.drainInstance(lbName, instId)
+			srv.emit(Event{Type: InstanceDeregistered, LoadBalancer: lbName, InstanceId: instId})
+		}
+		instId = req.FormValue(fmt.Sprintf("Instances.member.%d.InstanceId", i))
+	}
+	return elb.SimpleResp{RequestId: reqId}, nil
+}
+
+// describeLoadBalancers describes the given Load Balancers, failing with
+// LoadBalancerNotFound if any of them does not exist, or all of them when no
+// name is given, which is an empty list when there is no Load Balancer.
+func (srv *Server) describeLoadBalancers(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
+	var lbsDesc []elb.LoadBalancerDescription
+	names := srv.getParameters("LoadBalancerNames.member.", req.Form)
+	for _, lbName := range names {
+		if err := srv.lbExists(lbName); err != nil || !srv.visible(&srv.lbs[lbName].desc) {
+			return nil, &elb.Error{
+				StatusCode: 400,
+				Code:       "LoadBalancerNotFound",
+				Message:    fmt.Sprintf("Cannot find Load Balancer %s", lbName),
+			}
+		}
+		lbsDesc = append(lbsDesc, srv.lbs[lbName].desc)
+	}
+	if names == nil {
+		for _, lb := range srv.lbs {
+			if srv.visible(&lb.desc) {
+				lbsDesc = append(lbsDesc, lb.desc)
+			}
+		}
+		// like in ELB, all load balancers are described in a stable
+		// order, so they can be paginated.
+		sort.Slice(lbsDesc, func(i, j int) bool {
+			return lbsDesc[i].LoadBalancerName < lbsDesc[j].LoadBalancerName
+		})
+	}
+	lbsDesc, nextMarker, err := paginate(lbsDesc, req.Form.Get("Marker"), req.Form.Get("PageSize"))
+	if err != nil {
+		return nil, err
+	}
+	resp := describeLoadBalancersResp{RequestId: reqId}
+	resp.Result.LoadBalancerDescriptions.Members = lbsDesc
+	resp.Result.NextMarker = nextMarker
+	return resp, nil
+}
+
+// maxPageSize is the maximum, and default, number of load balancers in a page
+// of DescribeLoadBalancers.
+const maxPageSize = 400
+
+// paginate returns the page of the given descriptions that starts at marker,
+// along with the marker of the next page, which is empty in the last page.
+// Markers are opaque to clients; they hold the position of the first
+// description of the page.
+func paginate(lbsDesc []elb.LoadBalancerDescription, marker, pageSize string) ([]elb.LoadBalancerDescription, string, error) {
+	size := maxPageSize
+	if pageSize != "" {
+		var err error
+		size, err = strconv.Atoi(pageSize)
+		if err != nil || size < 1 || size > maxPageSize {
+			return nil, "", &elb.Error{
+				StatusCode: 400,
+				Code:       "ValidationError",
+				Message:    fmt.Sprintf("Invalid value '%s' for PageSize, it must be between 1 and %d", pageSize, maxPageSize),
+			}
+		}
+	}
+	start := 0
+	if marker != "" {
+		var err error
+		start, err = decodeMarker(marker)
+		if err != nil || start < 0 || start > len(lbsDesc) {
+			return nil, "", &elb.Error{
+				StatusCode: 400,
+				Code:       "ValidationError",
+				Message:    fmt.Sprintf("Invalid value '%s' for Marker", marker),
+			}
+		}
+	}
+	end := start + size
+	if end >= len(lbsDesc) {
+		return lbsDesc[start:], "", nil
+	}
+	return lbsDesc[start:end], encodeMarker(end), nil
+}
+
+func encodeMarker(position int) string {
+	return base64.StdEncoding.EncodeToString([]byte("position:" + strconv.Itoa(position)))
+}
+
+func decodeMarker(marker string) (int, error) {
+	b, err := base64.StdEncoding.DecodeString(marker)
+	if err != nil {
+		return 0, err
+	}
+	if !strings.HasPrefix(string(b), "position:") {
+		return 0, fmt.Errorf("invalid marker %q", marker)
+	}
+	return strconv.Atoi(strings.TrimPrefix(string(b), "position:"))
+}
+
+// visible reports whether a load balancer is visible to describe requests,
+// see SetCreateConsistencyDelay.
+func (srv *Server) visible(lb *elb.LoadBalancerDescription) bool {
+	return srv.createDelay == 0 || !lb.CreatedTime.Add(srv.createDelay).After(srv.clock.Now())
+}
+
+// describeLoadBalancersResp is the response to DescribeLoadBalancers. Unlike
+// elb.DescribeLoadBalancerResp, it is encoded with an empty
+// LoadBalancerDescriptions element when there are no load balancers.
+type describeLoadBalancersResp struct {
+	XMLName xml.Name `xml:"DescribeLoadBalancersResponse"`
+	Result  struct {
+		LoadBalancerDescriptions struct {
+			Members []elb.LoadBalancerDescription `xml:"member"`
+		}
+		NextMarker string `xml:",omitempty"`
+	} `xml:"DescribeLoadBalancersResult"`
+	RequestId string `xml:"ResponseMetadata>RequestId"`
+}
+
+func (srv *Server) createLoadBalancerListeners(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
+	required := []string{
+		"LoadBalancerName",
+		"Listeners.member.1.InstancePort",
+		"Listeners.member.1.InstanceProtocol",
+		"Listeners.member.1.Protocol",
+		"Listeners.member.1.LoadBalancerPort",
+	}
+	if err := srv.validate(req, required); err != nil {
+		return nil, err
+	}
+	lbName := req.FormValue("LoadBalancerName")
+	if err := srv.lbExists(lbName); err != nil {
+		return nil, err
+	}
+	lb := &srv.lbs[lbName].desc
+	lds := srv.makeListenerDescriptions(req.Form)
+	if err := srv.validateListeners(lb.ListenerDescriptions, lds); err != nil {
+		return nil, err
+	}
+	existing := make(map[int]bool, len(lb.ListenerDescriptions))
+	for _, ld := range lb.ListenerDescriptions {
+		existing[ld.Listener.LoadBalancerPort] = true
+	}
+	for _, ld := range lds {
+		if !existing[ld.Listener.LoadBalancerPort] {
+			lb.ListenerDescriptions = append(lb.ListenerDescriptions, ld)
 		}
 	}
+	return elb.SimpleResp{RequestId: reqId}, nil
+}
+
+// deleteLoadBalancerListeners removes the listeners on the given load
+// balancer ports. Ports without a listener are ignored, like in ELB.
+func (srv *Server) deleteLoadBalancerListeners(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
+	required := []string{"LoadBalancerName", "LoadBalancerPorts.member.1"}
+	if err := srv.validate(req, required); err != nil {
+		return nil, err
+	}
+	lbName := req.FormValue("LoadBalancerName")
+	if err := srv.lbExists(lbName); err != nil {
+		return nil, err
+	}
+	ports := make(map[int]bool)
+	for _, p := range srv.getParameters("LoadBalancerPorts.member.", req.Form) {
+		port, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, &elb.Error{
+				StatusCode: 400,
+				Code:       "ValidationError",
+				Message:    fmt.Sprintf("Invalid value '%s' for LoadBalancerPorts", p),
+			}
+		}
+		ports[port] = true
+	}
+	lb := &srv.lbs[lbName].desc
+	lds := []elb.ListenerDescription{}
+	for _, ld := range lb.ListenerDescriptions {
+		if !ports[ld.Listener.LoadBalancerPort] {
+			lds = append(lds, ld)
+		}
+	}
+	lb.ListenerDescriptions = lds
+	return elb.SimpleResp{RequestId: reqId}, nil
+}
+
+// setLoadBalancerListenerSSLCertificate replaces the certificate of a
+// listener. Like in ELB, only HTTPS and SSL listeners have certificates.
+func (srv *Server) setLoadBalancerListenerSSLCertificate(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
+	required := []string{"LoadBalancerName", "LoadBalancerPort", "SSLCertificateId"}
+	if err := srv.validate(req, required); err != nil {
+		return nil, err
+	}
+	lbName := req.FormValue("LoadBalancerName")
+	if err := srv.lbExists(lbName); err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(req.FormValue("LoadBalancerPort"))
+	if err != nil {
+		return nil, &elb.Error{
+			StatusCode: 400,
+			Code:       "ValidationError",
+			Message:    fmt.Sprintf("Invalid value '%s' for LoadBalancerPort", req.FormValue("LoadBalancerPort")),
+		}
+	}
+	lb := &srv.lbs[lbName].desc
+	for i := range lb.ListenerDescriptions {
+		l := &lb.ListenerDescriptions[i].Listener
+		if l.LoadBalancerPort != port {
+			continue
+		}
+		if l.Protocol != "HTTPS" && l.Protocol != "SSL" {
+			return nil, &elb.Error{
+				StatusCode: 400,
+				Code:       "InvalidConfigurationRequest",
+				Message:    fmt.Sprintf("The listener on port %d uses %s, which doesn't take a certificate", port, l.Protocol),
+			}
+		}
+		changed := *l
+		changed.SSLCertificateId = req.FormValue("SSLCertificateId")
+		if err := srv.validateCertificate(&changed, ""); err != nil {
+			return nil, err
+		}
+		*l = changed
+		return elb.SimpleResp{RequestId: reqId}, nil
+	}
+	return nil, &elb.Error{
+		StatusCode: 400,
+		Code:       "ListenerNotFound",
+		Message:    fmt.Sprintf("Load Balancer %s has no listener on port %d", lbName, port),
+	}
+}
+
+func (srv *Server) enableAvailabilityZonesForLoadBalancer(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
+	if err := srv.validate(req, []string{"LoadBalancerName", "AvailabilityZones.member.1"}); err != nil {
+		return nil, err
+	}
+	lbName := req.FormValue("LoadBalancerName")
+	if err := srv.lbExists(lbName); err != nil {
+		return nil, err
+	}
+	lb := &srv.lbs[lbName].desc
+	for _, zone := range srv.getParameters("AvailabilityZones.member.", req.Form) {
+		found := false
+		for _, z := range lb.AvailZones {
+			if z == zone {
+				found = true
+				break
+			}
+		}
+		if !found {
+			lb.AvailZones = append(lb.AvailZones, zone)
+		}
+	}
+	return elb.EnableAvailabilityZonesForLoadBalancerResp{
+		AvailabilityZones: copyStrings(lb.AvailZones),
+		RequestId:         reqId,
+	}, nil
+}
+
+// disableAvailabilityZonesForLoadBalancer removes availability zones from a
+// load balancer. Like ELB, it refuses to leave the load balancer without
+// availability zones, and ignores the zones the load balancer is not in.
+func (srv *Server) disableAvailabilityZonesForLoadBalancer(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
+	if err := srv.validate(req, []string{"LoadBalancerName", "AvailabilityZones.member.1"}); err != nil {
+		return nil, err
+	}
+	lbName := req.FormValue("LoadBalancerName")
+	if err := srv.lbExists(lbName); err != nil {
+		return nil, err
+	}
+	lb := &srv.lbs[lbName].desc
+	disabled := make(map[string]bool)
+	for _, zone := range srv.getParameters("AvailabilityZones.member.", req.Form) {
+		disabled[zone] = true
+	}
+	remaining := []string{}
+	for _, zone := range lb.AvailZones {
+		if !disabled[zone] {
+			remaining = append(remaining, zone)
+		}
+	}
+	if len(remaining) == 0 {
+		return nil, &elb.Error{
+			StatusCode: 400,
+			Code:       "ValidationError",
+			Message:    "Cannot remove all Availability Zones from a Load Balancer",
+		}
+	}
+	lb.AvailZones = remaining
+	return elb.DisableAvailabilityZonesForLoadBalancerResp{
+		AvailabilityZones: copyStrings(lb.AvailZones),
+		RequestId:         reqId,
+	}, nil
+}
+
+// attachLoadBalancerToSubnets adds subnets to a VPC load balancer. Subnets
+// the load balancer is already attached to are ignored.
+func (srv *Server) attachLoadBalancerToSubnets(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
+	if err := srv.validate(req, []string{"LoadBalancerName", "Subnets.member.1"}); err != nil {
+		return nil, err
+	}
+	lbName := req.FormValue("LoadBalancerName")
+	if err := srv.lbExists(lbName); err != nil {
+		return nil, err
+	}
+	if err := srv.inVPC(lbName, "Subnets"); err != nil {
+		return nil, err
+	}
+	subnets := srv.getParameters("Subnets.member.", req.Form)
+	if err := srv.validateSubnets(subnets, "SubnetNotFound", "One or more subnets were not found: %s"); err != nil {
+		return nil, err
+	}
+	lb := &srv.lbs[lbName].desc
+	for _, id := range subnets {
+		found := false
+		for _, subnet := range lb.Subnets {
+			if subnet == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			lb.Subnets = append(lb.Subnets, id)
+		}
+	}
+	return elb.AttachLoadBalancerToSubnetsResp{
+		Subnets:   copyStrings(lb.Subnets),
+		RequestId: reqId,
+	}, nil
+}
+
+// detachLoadBalancerFromSubnets removes subnets from a VPC load balancer.
+// Subnets the load balancer is not attached to are ignored, but the last
+// subnet of the load balancer can't be removed.
+func (srv *Server) detachLoadBalancerFromSubnets(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
+	if err := srv.validate(req, []string{"LoadBalancerName", "Subnets.member.1"}); err != nil {
+		return nil, err
+	}
+	lbName := req.FormValue("LoadBalancerName")
+	if err := srv.lbExists(lbName); err != nil {
+		return nil, err
+	}
+	if err := srv.inVPC(lbName, "Subnets"); err != nil {
+		return nil, err
+	}
+	detached := map[string]bool{}
+	for _, id := range srv.getParameters("Subnets.member.", req.Form) {
+		detached[id] = true
+	}
+	lb := &srv.lbs[lbName].desc
+	remaining := []string{}
+	for _, id := range lb.Subnets {
+		if !detached[id] {
+			remaining = append(remaining, id)
+		}
+	}
+	if len(remaining) == 0 {
+		return nil, &elb.Error{
+			StatusCode: 400,
+			Code:       "InvalidConfigurationRequest",
+			Message:    fmt.Sprintf("Load Balancer %s can't be detached from all its subnets", lbName),
+		}
+	}
+	lb.Subnets = remaining
+	return elb.DetachLoadBalancerFromSubnetsResp{
+		Subnets:   copyStrings(lb.Subnets),
+		RequestId: reqId,
+	}, nil
+}
+
+func (srv *Server) applySecurityGroupsToLoadBalancer(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
+	if err := srv.validate(req, []string{"LoadBalancerName", "SecurityGroups.member.1"}); err != nil {
+		return nil, err
+	}
+	lbName := req.FormValue("LoadBalancerName")
+	if err := srv.lbExists(lbName); err != nil {
+		return nil, err
+	}
+	if err := srv.inVPC(lbName, "Security groups"); err != nil {
+		return nil, err
+	}
+	groups := srv.getParameters("SecurityGroups.member.", req.Form)
+	if err := srv.validateSecurityGroups(groups); err != nil {
+		return nil, err
+	}
+	srv.lbs[lbName].desc.SecurityGroups = groups
+	return elb.ApplySecurityGroupsToLoadBalancerResp{
+		SecurityGroups: copyStrings(groups),
+		RequestId:      reqId,
+	}, nil
+}
+
+// inVPC fails with InvalidConfigurationRequest if the load balancer is not in
+// a VPC. what names the feature that needs one, for example "Subnets".
+func (srv *Server) inVPC(lbName, what string) error {
+	if len(srv.lbs[lbName].desc.Subnets) > 0 {
+		return nil
+	}
+	return &elb.Error{
+		StatusCode: 400,
+		Code:       "InvalidConfigurationRequest",
+		Message:    fmt.Sprintf("%s are only available to load balancers in a VPC, and %s is not in one", what, lbName),
+	}
+}
+
+// validateSubnets checks, in strict mode, that the given subnets are
+// registered, failing with the given code and message otherwise.
+func (srv *Server) validateSubnets(subnets []string, code, format string) error {
+	if !srv.strict {
+		return nil
+	}
+	for _, id := range subnets {
+		if !srv.subnets[id] {
+			return &elb.Error{
+				StatusCode: 400,
+				Code:       code,
+				Message:    fmt.Sprintf(format, id),
+			}
+		}
+	}
+	return nil
+}
+
+// validateSecurityGroups checks, in strict mode, that the given security
+// groups are registered.
+func (srv *Server) validateSecurityGroups(groups []string) error {
+	if !srv.strict {
+		return nil
+	}
+	for _, id := range groups {
+		if !srv.securityGroups[id] {
+			return &elb.Error{
+				StatusCode: 400,
+				Code:       "InvalidSecurityGroup",
+				Message:    fmt.Sprintf("Invalid security group: %s", id),
+			}
+		}
+	}
+	return nil
+}
+
+// backendPolicyTypes holds the types of the policies that can be applied to
+// backend servers.
+var backendPolicyTypes = map[string]bool{
+	"BackendServerAuthenticationPolicyType": true,
+	"ProxyProtocolPolicyType":               true,
+}
+
+// setLoadBalancerPoliciesForBackendServer replaces the policies of the given
+// instance port, which are returned in the BackendServerDescriptions of the
+// load balancer. Only existing ProxyProtocol and BackendServerAuthentication
+// policies can be applied.
+func (srv *Server) setLoadBalancerPoliciesForBackendServer(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
+	if err := srv.validate(req, []string{"LoadBalancerName", "InstancePort"}); err != nil {
+		return nil, err
+	}
+	lbName := req.FormValue("LoadBalancerName")
+	if err := srv.lbExists(lbName); err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(req.FormValue("InstancePort"))
+	if err != nil {
+		return nil, &elb.Error{
+			StatusCode: 400,
+			Code:       "ValidationError",
+			Message:    fmt.Sprintf("Invalid value '%s' for InstancePort", req.FormValue("InstancePort")),
+		}
+	}
+	policies := srv.getParameters("PolicyNames.member.", req.Form)
+	if err := srv.checkPolicies(lbName, policies); err != nil {
+		return nil, err
+	}
+	for _, name := range policies {
+		p := srv.findPolicy(lbName, name)
+		if !backendPolicyTypes[p.typeName] {
+			return nil, &elb.Error{
+				StatusCode: 400,
+				Code:       "InvalidConfigurationRequest",
+				Message:    fmt.Sprintf("Policy %s of type %s cannot be applied to backend servers", name, p.typeName),
+			}
+		}
+	}
+	lb := &srv.lbs[lbName].desc
+	descs := []elb.BackendServerDescription{}
+	for _, d := range lb.BackendServerDescriptions {
+		if d.InstancePort != port {
+			descs = append(descs, d)
+		}
+	}
+	if len(policies) > 0 {
+		descs = append(descs, elb.BackendServerDescription{InstancePort: port, PolicyNames: policies})
+	}
+	lb.BackendServerDescriptions = descs
+	return elb.SimpleResp{RequestId: reqId}, nil
+}
+
+// setLoadBalancerPoliciesOfListener replaces the policies of the listener on
+// the given port, which are returned as the PolicyNames of its
+// ListenerDescription.
+func (srv *Server) setLoadBalancerPoliciesOfListener(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
+	if err := srv.validate(req, []string{"LoadBalancerName", "LoadBalancerPort"}); err != nil {
+		return nil, err
+	}
+	lbName := req.FormValue("LoadBalancerName")
+	if err := srv.lbExists(lbName); err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(req.FormValue("LoadBalancerPort"))
+	if err != nil {
+		return nil, &elb.Error{
+			StatusCode: 400,
+			Code:       "ValidationError",
+			Message:    fmt.Sprintf("Invalid value '%s' for LoadBalancerPort", req.FormValue("LoadBalancerPort")),
+		}
+	}
+	policies := srv.getParameters("PolicyNames.member.", req.Form)
+	if err := srv.checkPolicies(lbName, policies); err != nil {
+		return nil, err
+	}
+	lb := &srv.lbs[lbName].desc
+	for i := range lb.ListenerDescriptions {
+		if lb.ListenerDescriptions[i].Listener.LoadBalancerPort == port {
+			if policies == nil {
+				policies = []string{}
+			}
+			lb.ListenerDescriptions[i].PolicyNames = policies
+			return elb.SimpleResp{RequestId: reqId}, nil
+		}
+	}
+	return nil, &elb.Error{
+		StatusCode: 400,
+		Code:       "ListenerNotFound",
+		Message:    fmt.Sprintf("Load Balancer %s has no listener on port %d", lbName, port),
+	}
+}
+
+// policy is a policy created in a load balancer.
+type policy struct {
+	name       string
+	typeName   string
+	attributes []policyAttribute
+}
+
+type policyAttribute struct {
+	name  string
+	value string
+}
+
+// attribute returns the value of the given attribute of the policy, or an
+// empty string if the policy does not have it.
+func (p *policy) attribute(name string) string {
+	for _, attr := range p.attributes {
+		if attr.name == name {
+			return attr.value
+		}
+	}
+	return ""
+}
+
+// findPolicy returns the policy of the load balancer with the given name, or
+// nil if there is none.
+func (srv *Server) findPolicy(lbName, policyName string) *policy {
+	for _, p := range srv.lbs[lbName].policies {
+		if p.name == policyName {
+			return p
+		}
+	}
+	return nil
+}
+
+// checkPolicies fails with PolicyNotFound if any of the given policies does
+// not exist in the load balancer.
+func (srv *Server) checkPolicies(lbName string, policyNames []string) error {
+	for _, name := range policyNames {
+		if srv.findPolicy(lbName, name) == nil {
+			return &elb.Error{
+				StatusCode: 400,
+				Code:       "PolicyNotFound",
+				Message:    fmt.Sprintf("There is no policy named %s", name),
+			}
+		}
+	}
+	return nil
+}
+
+// addPolicy adds a policy to a load balancer, failing with
+// DuplicatePolicyName if the load balancer already has a policy with the
+// same name, and updates the Policies of the description of the load
+// balancer.
+func (srv *Server) addPolicy(lbName string, p *policy) error {
+	if srv.findPolicy(lbName, p.name) != nil {
+		return &elb.Error{
+			StatusCode: 400,
+			Code:       "DuplicatePolicyName",
+			Message:    fmt.Sprintf("Policy %s already exists for Load Balancer %s", p.name, lbName),
+		}
+	}
+	lb := srv.lbs[lbName]
+	lb.policies = append(lb.policies, p)
+	srv.updatePolicies(lbName)
+	return nil
+}
+
+// updatePolicies makes the Policies of the description of a load balancer
+// match its policies.
+func (srv *Server) updatePolicies(lbName string) {
+	lb := srv.lbs[lbName]
+	var policies elb.Policies
+	for _, p := range lb.policies {
+		switch p.typeName {
+		case "AppCookieStickinessPolicyType":
+			policies.AppCookieStickinessPolicies = append(policies.AppCookieStickinessPolicies, elb.AppCookieStickinessPolicies{
+				CookieName: p.attribute("CookieName"),
+				PolicyName: p.name,
+			})
+		case "LBCookieStickinessPolicyType":
+			period, _ := strconv.Atoi(p.attribute("CookieExpirationPeriod"))
+			policies.LBCookieStickinessPolicies = append(policies.LBCookieStickinessPolicies, elb.LBCookieStickinessPolicies{
+				CookieExpirationPeriod: period,
+				PolicyName:             p.name,
+			})
+		default:
+			policies.OtherPolicies = append(policies.OtherPolicies, p.name)
+		}
+	}
+	lb.desc.Policies = policies
+}
+
+// makePolicies returns the policies described in the Policies of a load
+// balancer description. The other policies are only known by name, so they
+// are taken from the sample policies when they have the same name, and have
+// no type otherwise.
+func makePolicies(policies elb.Policies) []*policy {
+	var ps []*policy
+	for _, p := range policies.AppCookieStickinessPolicies {
+		ps = append(ps, &policy{
+			name:       p.PolicyName,
+			typeName:   "AppCookieStickinessPolicyType",
+			attributes: []policyAttribute{{name: "CookieName", value: p.CookieName}},
+		})
+	}
+	for _, p := range policies.LBCookieStickinessPolicies {
+		lbPolicy := &policy{name: p.PolicyName, typeName: "LBCookieStickinessPolicyType"}
+		if p.CookieExpirationPeriod > 0 {
+			lbPolicy.attributes = []policyAttribute{{name: "CookieExpirationPeriod", value: strconv.Itoa(p.CookieExpirationPeriod)}}
+		}
+		ps = append(ps, lbPolicy)
+	}
+	for _, name := range policies.OtherPolicies {
+		p := &policy{name: name}
+		for _, sample := range samplePolicies {
+			if sample.name == name {
+				p = makePolicy(sample.description())
+			}
+		}
+		ps = append(ps, p)
+	}
+	return ps
+}
+
+func (srv *Server) createLBCookieStickinessPolicy(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
+	if err := srv.validate(req, []string{"LoadBalancerName", "PolicyName"}); err != nil {
+		return nil, err
+	}
+	lbName := req.FormValue("LoadBalancerName")
+	if err := srv.lbExists(lbName); err != nil {
+		return nil, err
+	}
+	p := &policy{name: req.FormValue("PolicyName"), typeName: "LBCookieStickinessPolicyType"}
+	if period := req.FormValue("CookieExpirationPeriod"); period != "" {
+		if n, err := strconv.Atoi(period); err != nil || n < 0 {
+			return nil, &elb.Error{
+				StatusCode: 400,
+				Code:       "ValidationError",
+				Message:    fmt.Sprintf("Invalid value '%s' for CookieExpirationPeriod", period),
+			}
+		}
+		p.attributes = []policyAttribute{{name: "CookieExpirationPeriod", value: period}}
+	}
+	if err := srv.addPolicy(lbName, p); err != nil {
+		return nil, err
+	}
+	return elb.SimpleResp{RequestId: reqId}, nil
+}
+
+func (srv *Server) createAppCookieStickinessPolicy(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
+	if err := srv.validate(req, []string{"LoadBalancerName", "PolicyName", "CookieName"}); err != nil {
+		return nil, err
+	}
+	lbName := req.FormValue("LoadBalancerName")
+	if err := srv.lbExists(lbName); err != nil {
+		return nil, err
+	}
+	p := &policy{
+		name:       req.FormValue("PolicyName"),
+		typeName:   "AppCookieStickinessPolicyType",
+		attributes: []policyAttribute{{name: "CookieName", value: req.FormValue("CookieName")}},
+	}
+	if err := srv.addPolicy(lbName, p); err != nil {
+		return nil, err
+	}
+	return elb.SimpleResp{RequestId: reqId}, nil
+}
+
+// policyTypes is the catalog of the policy types that can be created with
+// CreateLoadBalancerPolicy, as described by DescribeLoadBalancerPolicyTypes.
+var policyTypes = []elb.PolicyTypeDescription{
+	{
+		PolicyTypeName: "AppCookieStickinessPolicyType",
+		Description:    "Stickiness policy with session lifetimes controlled by the lifetime of the application-generated cookie. This policy can be associated only with HTTP/HTTPS listeners.",
+		PolicyAttributeTypeDescriptions: []elb.PolicyAttributeTypeDescription{
+			{AttributeName: "CookieName", AttributeType: "String", Cardinality: "ONE"},
+		},
+	},
+	{
+		PolicyTypeName: "BackendServerAuthenticationPolicyType",
+		Description:    "Policy that controls authentication to back-end server(s) and contains one or more policies, such as an instance of a PublicKeyPolicyType. This policy can be associated only with back-end servers that are using HTTPS/SSL.",
+		PolicyAttributeTypeDescriptions: []elb.PolicyAttributeTypeDescription{
+			{AttributeName: "PublicKeyPolicyName", AttributeType: "PolicyName", Cardinality: "ONE_OR_MORE"},
+		},
+	},
+	{
+		PolicyTypeName: "LBCookieStickinessPolicyType",
+		Description:    "Stickiness policy with session lifetimes controlled by the browser (user-agent) or a specified expiration period. This policy can be associated only with HTTP/HTTPS listeners.",
+		PolicyAttributeTypeDescriptions: []elb.PolicyAttributeTypeDescription{
+			{AttributeName: "CookieExpirationPeriod", AttributeType: "Long", Cardinality: "ZERO_OR_ONE"},
+		},
+	},
+	{
+		PolicyTypeName: "ProxyProtocolPolicyType",
+		Description:    "Policy that controls whether to include the IP address and port of the originating request for TCP messages. This policy operates on TCP/SSL listeners only",
+		PolicyAttributeTypeDescriptions: []elb.PolicyAttributeTypeDescription{
+			{AttributeName: "ProxyProtocol", AttributeType: "Boolean", Cardinality: "ONE"},
+		},
+	},
+	{
+		PolicyTypeName: "PublicKeyPolicyType",
+		Description:    "Policy containing a list of public keys to accept when authenticating the back-end server(s). This policy cannot be applied directly to back-end servers or listeners but must be part of a BackendServerAuthenticationPolicyType.",
+		PolicyAttributeTypeDescriptions: []elb.PolicyAttributeTypeDescription{
+			{AttributeName: "PublicKey", AttributeType: "String", Cardinality: "ONE"},
+		},
+	},
+	{
+		PolicyTypeName: "SSLNegotiationPolicyType",
+		Description:    "Listener policy that defines the ciphers and protocols that will be accepted by the load balancer. This policy can be associated only with HTTPS/SSL listeners.",
+		PolicyAttributeTypeDescriptions: []elb.PolicyAttributeTypeDescription{
+			{AttributeName: "Protocol-TLSv1", AttributeType: "Boolean", Cardinality: "ZERO_OR_ONE"},
+			{AttributeName: "Protocol-TLSv1.1", AttributeType: "Boolean", Cardinality: "ZERO_OR_ONE"},
+			{AttributeName: "Protocol-TLSv1.2", AttributeType: "Boolean", Cardinality: "ZERO_OR_ONE"},
+			{AttributeName: "Server-Defined-Cipher-Order", AttributeType: "Boolean", Cardinality: "ZERO_OR_ONE"},
+			{AttributeName: "Reference-Security-Policy", AttributeType: "String", Cardinality: "ZERO_OR_ONE"},
+		},
+	},
+}
+
+// findPolicyType returns the description of a policy type, failing with
+// PolicyTypeNotFound when the type is not in the catalog.
+func findPolicyType(name string) (*elb.PolicyTypeDescription, error) {
+	for i := range policyTypes {
+		if policyTypes[i].PolicyTypeName == name {
+			return &policyTypes[i], nil
+		}
+	}
+	return nil, &elb.Error{
+		StatusCode: 400,
+		Code:       "PolicyTypeNotFound",
+		Message:    fmt.Sprintf("Policy type %s does not exist", name),
+	}
+}
+
+func (srv *Server) describeLoadBalancerPolicyTypes(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
+	resp := elb.DescribeLoadBalancerPolicyTypesResp{RequestId: reqId}
+	names := srv.getParameters("PolicyTypeNames.member.", req.Form)
+	if names == nil {
+		resp.PolicyTypeDescriptions = policyTypes
+		return resp, nil
+	}
+	for _, name := range names {
+		t, err := findPolicyType(name)
+		if err != nil {
+			return nil, err
+		}
+		resp.PolicyTypeDescriptions = append(resp.PolicyTypeDescriptions, *t)
+	}
+	return resp, nil
+}
+
+func (srv *Server) createLoadBalancerPolicy(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
+	if err := srv.validate(req, []string{"LoadBalancerName", "PolicyName", "PolicyTypeName"}); err != nil {
+		return nil, err
+	}
+	lbName := req.FormValue("LoadBalancerName")
+	if err := srv.lbExists(lbName); err != nil {
+		return nil, err
+	}
+	typeName := req.FormValue("PolicyTypeName")
+	if _, err := findPolicyType(typeName); err != nil {
+		return nil, err
+	}
+	p := &policy{name: req.FormValue("PolicyName"), typeName: typeName}
+	for i := 1; ; i++ {
+		key := fmt.Sprintf("PolicyAttributes.member.%d.", i)
+		name := req.FormValue(key + "AttributeName")
+		if name == "" {
+			break
+		}
+		p.attributes = append(p.attributes, policyAttribute{name: name, value: req.FormValue(key + "AttributeValue")})
+	}
+	if err := srv.addPolicy(lbName, p); err != nil {
+		return nil, err
+	}
+	return elb.SimpleResp{RequestId: reqId}, nil
+}
+
+// deleteLoadBalancerPolicy deletes a policy that is not in use by any
+// listener or backend server. Like in ELB, deleting a policy that does not
+// exist succeeds.
+func (srv *Server) deleteLoadBalancerPolicy(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
+	if err := srv.validate(req, []string{"LoadBalancerName", "PolicyName"}); err != nil {
+		return nil, err
+	}
+	lbName := req.FormValue("LoadBalancerName")
+	if err := srv.lbExists(lbName); err != nil {
+		return nil, err
+	}
+	name := req.FormValue("PolicyName")
+	lb := &srv.lbs[lbName].desc
+	inUse := func(where string, port int) error {
+		return &elb.Error{
+			StatusCode: 400,
+			Code:       "InvalidConfigurationRequest",
+			Message:    fmt.Sprintf("Policy %s is in use by the %s on port %d", name, where, port),
+		}
+	}
+	for _, ld := range lb.ListenerDescriptions {
+		for _, p := range ld.PolicyNames {
+			if p == name {
+				return nil, inUse("listener", ld.Listener.LoadBalancerPort)
+			}
+		}
+	}
+	for _, d := range lb.BackendServerDescriptions {
+		for _, p := range d.PolicyNames {
+			if p == name {
+				return nil, inUse("backend server", d.InstancePort)
+			}
+		}
+	}
+	policies := srv.lbs[lbName].policies
+	for i, p := range policies {
+		if p.name == name {
+			srv.lbs[lbName].policies = append(policies[:i], policies[i+1:]...)
+			break
+		}
+	}
+	srv.updatePolicies(lbName)
+	return elb.SimpleResp{RequestId: reqId}, nil
+}
+
+// samplePolicies are the policies described by DescribeLoadBalancerPolicies
+// when no load balancer is given, like the sample policies that AWS provides.
+var samplePolicies = []*policy{
+	{
+		name:     "ELBSecurityPolicy-2016-08",
+		typeName: "SSLNegotiationPolicyType",
+		attributes: []policyAttribute{
+			{name: "Protocol-TLSv1", value: "true"},
+			{name: "Protocol-TLSv1.1", value: "true"},
+			{name: "Protocol-TLSv1.2", value: "true"},
+			{name: "Server-Defined-Cipher-Order", value: "true"},
+			{name: "ECDHE-RSA-AES128-GCM-SHA256", value: "true"},
+		},
+	},
+	{
+		name:     "ELBSecurityPolicy-TLS-1-2-2017-01",
+		typeName: "SSLNegotiationPolicyType",
+		attributes: []policyAttribute{
+			{name: "Protocol-TLSv1", value: "false"},
+			{name: "Protocol-TLSv1.1", value: "false"},
+			{name: "Protocol-TLSv1.2", value: "true"},
+			{name: "Server-Defined-Cipher-Order", value: "true"},
+			{name: "ECDHE-RSA-AES128-GCM-SHA256", value: "true"},
+		},
+	},
+}
+
+// makePolicy returns the policy with the given description.
+func makePolicy(desc elb.PolicyDescription) *policy {
+	p := &policy{name: desc.PolicyName, typeName: desc.PolicyTypeName}
+	for _, attr := range desc.PolicyAttributeDescriptions {
+		p.attributes = append(p.attributes, policyAttribute{name: attr.AttributeName, value: attr.AttributeValue})
+	}
+	return p
 }
 
-func (srv *Server) createLoadBalancer(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
-	composition := map[string]string{
-		"AvailabilityZones.member.1": "Subnets.member.1",
+func (p *policy) description() elb.PolicyDescription {
+	desc := elb.PolicyDescription{
+		PolicyName:                  p.name,
+		PolicyTypeName:              p.typeName,
+		PolicyAttributeDescriptions: []elb.PolicyAttribute{},
 	}
-	if err := srv.validateComposition(req, composition); err != nil {
-		return nil, err
+	for _, attr := range p.attributes {
+		desc.PolicyAttributeDescriptions = append(desc.PolicyAttributeDescriptions, elb.PolicyAttribute{
+			AttributeName:  attr.name,
+			AttributeValue: attr.value,
+		})
 	}
-	required := []string{
-		"Listeners.member.1.InstancePort",
-		"Listeners.member.1.InstanceProtocol",
-		"Listeners.member.1.Protocol",
-		"Listeners.member.1.LoadBalancerPort",
-		"LoadBalancerName",
+	return desc
+}
+
+// describeLoadBalancerPolicies describes the policies of a load balancer, or
+// the sample policies when no load balancer is given. Describing a policy
+// that does not exist fails with PolicyNotFound.
+func (srv *Server) describeLoadBalancerPolicies(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
+	lbName := req.FormValue("LoadBalancerName")
+	policies := samplePolicies
+	if lbName != "" {
+		if err := srv.lbExists(lbName); err != nil {
+			return nil, err
+		}
+		policies = srv.lbs[lbName].policies
 	}
-	if err := srv.validate(req, required); err != nil {
-		return nil, err
+	resp := describeLoadBalancerPoliciesResp{RequestId: reqId}
+	members := []elb.PolicyDescription{}
+	names := srv.getParameters("PolicyNames.member.", req.Form)
+	if names == nil {
+		for _, p := range policies {
+			members = append(members, p.description())
+		}
 	}
-	path := req.FormValue("Path")
-	if path == "" {
-		path = "/"
+	for _, name := range names {
+		var found *policy
+		for _, p := range policies {
+			if p.name == name {
+				found = p
+				break
+			}
+		}
+		if found == nil {
+			return nil, &elb.Error{
+				StatusCode: 400,
+				Code:       "PolicyNotFound",
+				Message:    fmt.Sprintf("There is no policy named %s", name),
+			}
+		}
+		members = append(members, found.description())
 	}
-	lbName := req.FormValue("LoadBalancerName")
-	srv.lbs[lbName] = srv.makeLoadBalancerDescription(req.Form)
-	srv.lbs[lbName].DNSName = fmt.Sprintf("%s-some-aws-stuff.us-east-1.elb.amazonaws.com", lbName)
-	return elb.CreateLoadBalancerResp{
-		DNSName: srv.lbs[lbName].DNSName,
-	}, nil
+	resp.Result.PolicyDescriptions.Members = members
+	return resp, nil
 }
 
-func (srv *Server) deleteLoadBalancer(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
-	if err := srv.validate(req, []string{"LoadBalancerName"}); err != nil {
-		return nil, err
-	}
-	srv.RemoveLoadBalancer(req.FormValue("LoadBalancerName"))
-	return elb.SimpleResp{RequestId: reqId}, nil
+// describeLoadBalancerPoliciesResp is the response to
+// DescribeLoadBalancerPolicies, encoded with an empty PolicyDescriptions
+// element when there are no policies.
+type describeLoadBalancerPoliciesResp struct {
+	XMLName xml.Name `xml:"DescribeLoadBalancerPoliciesResponse"`
+	Result  struct {
+		PolicyDescriptions struct {
+			Members []elb.PolicyDescription `xml:"member"`
+		}
+	} `xml:"DescribeLoadBalancerPoliciesResult"`
+	RequestId string `xml:"ResponseMetadata>RequestId"`
 }
 
-func (srv *Server) registerInstancesWithLoadBalancer(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
-	required := []string{"LoadBalancerName", "Instances.member.1.InstanceId"}
-	if err := srv.validate(req, required); err != nil {
+// maxTags is the number of tags a load balancer can have in AWS.
+const maxTags = 10
+
+// addTags adds tags to load balancers, replacing the value of the tags they
+// already have. Nothing is changed if any of the load balancers would end up
+// with more than maxTags tags.
+func (srv *Server) addTags(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
+	if err := srv.validate(req, []string{"LoadBalancerNames.member.1", "Tags.member.1.Key"}); err != nil {
 		return nil, err
 	}
-	lbName := req.FormValue("LoadBalancerName")
-	if err := srv.lbExists(lbName); err != nil {
-		return nil, err
+	var tags []elb.Tag
+	given := map[string]bool{}
+	for i := 1; ; i++ {
+		key := fmt.Sprintf("Tags.member.%d.", i)
+		if req.FormValue(key+"Key") == "" {
+			break
+		}
+		tag := elb.Tag{Key: req.FormValue(key + "Key"), Value: req.FormValue(key + "Value")}
+		if given[tag.Key] {
+			return nil, &elb.Error{
+				StatusCode: 400,
+				Code:       "DuplicateTagKeys",
+				Message:    fmt.Sprintf("Tag key %s is given more than once", tag.Key),
+			}
+		}
+		given[tag.Key] = true
+		tags = append(tags, tag)
 	}
-	instIds := []string{}
-	instances := []elb.Instance{}
-	i := 1
-	instId := req.FormValue(fmt.Sprintf("Instances.member.%d.InstanceId", i))
-	for instId != "" {
-		if err := srv.instanceExists(instId); err != nil {
+	names := srv.getParameters("LoadBalancerNames.member.", req.Form)
+	merged := make(map[string][]elb.Tag, len(names))
+	for _, name := range names {
+		if err := srv.lbExists(name); err != nil {
 			return nil, err
 		}
-		instIds = append(instIds, instId)
-		instances = append(instances, elb.Instance{InstanceId: instId})
-		i++
-		instId = req.FormValue(fmt.Sprintf("Instances.member.%d.InstanceId", i))
+		current := append([]elb.Tag(nil), srv.lbs[name].tags...)
+		for _, tag := range tags {
+			replaced := false
+			for i := range current {
+				if current[i].Key == tag.Key {
+					current[i].Value = tag.Value
+					replaced = true
+				}
+			}
+			if !replaced {
+				current = append(current, tag)
+			}
+		}
+		if len(current) > maxTags {
+			return nil, &elb.Error{
+				StatusCode: 400,
+				Code:       "TooManyTags",
+				Message:    fmt.Sprintf("Load Balancer %s can't have more than %d tags", name, maxTags),
+			}
+		}
+		merged[name] = current
 	}
-	srv.instanceStates[lbName] = append(srv.instanceStates[lbName], srv.makeInstanceState(instId))
-	srv.lbs[lbName].Instances = append(srv.lbs[lbName].Instances, instances...)
-	return elb.RegisterInstancesResp{InstanceIds: instIds}, nil
+	for name, tags := range merged {
+		srv.lbs[name].tags = tags
+	}
+	return elb.SimpleResp{RequestId: reqId}, nil
 }
 
-func (srv *Server) deregisterInstancesFromLoadBalancer(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
-	required := []string{"LoadBalancerName"}
-	if err := srv.validate(req, required); err != nil {
-		return nil, err
-	}
-	lbName := req.FormValue("LoadBalancerName")
-	if err := srv.lbExists(lbName); err != nil {
+// removeTags removes the tags with the given keys from load balancers. Keys
+// that a load balancer doesn't have are ignored.
+func (srv *Server) removeTags(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
+	if err := srv.validate(req, []string{"LoadBalancerNames.member.1", "Tags.member.1.Key"}); err != nil {
 		return nil, err
 	}
-	i := 1
-	lb := srv.lbs[lbName]
-	instId := req.FormValue(fmt.Sprintf("Instances.member.%d.InstanceId", i))
-	for instId != "" {
-		if err := srv.instanceExists(instId); err != nil {
+	names := srv.getParameters("LoadBalancerNames.member.", req.Form)
+	for _, name := range names {
+		if err := srv.lbExists(name); err != nil {
 			return nil, err
 		}
-		i++
-		removeInstanceFromLB(lb, instId)
-		instId = req.FormValue(fmt.Sprintf("Instances.member.%d.InstanceId", i))
 	}
-	srv.lbs[lbName] = lb
-	srv.removeInstanceStatesFromLoadBalancer(lbName, instId)
+	removed := map[string]bool{}
+	for i := 1; req.FormValue(fmt.Sprintf("Tags.member.%d.Key", i)) != ""; i++ {
+		removed[req.FormValue(fmt.Sprintf("Tags.member.%d.Key", i))] = true
+	}
+	for _, name := range names {
+		var tags []elb.Tag
+		for _, tag := range srv.lbs[name].tags {
+			if !removed[tag.Key] {
+				tags = append(tags, tag)
+			}
+		}
+		srv.lbs[name].tags = tags
+	}
 	return elb.SimpleResp{RequestId: reqId}, nil
 }
 
-func (srv *Server) describeLoadBalancers(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
-	i := 1
-	var lbsDesc []elb.LoadBalancerDescription
-	lbName := req.FormValue(fmt.Sprintf("LoadBalancerNames.member.%d", i))
-	for lbName != "" {
-		key := fmt.Sprintf("LoadBalancerNames.member.%d", i)
-		if req.FormValue(key) != "" {
-			if err := srv.lbExists(req.FormValue(key)); err != nil {
-				return nil, err
-			}
-		}
-		if lbName != "" {
-			lbsDesc = append(lbsDesc, *srv.lbs[lbName])
+// maxDescribeTags is the number of load balancers whose tags can be
+// described at once.
+const maxDescribeTags = 20
+
+func (srv *Server) describeTags(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
+	if err := srv.validate(req, []string{"LoadBalancerNames.member.1"}); err != nil {
+		return nil, err
+	}
+	names := srv.getParameters("LoadBalancerNames.member.", req.Form)
+	if len(names) > maxDescribeTags {
+		return nil, &elb.Error{
+			StatusCode: 400,
+			Code:       "ValidationError",
+			Message:    fmt.Sprintf("The tags of at most %d Load Balancers can be described at once, got %d", maxDescribeTags, len(names)),
 		}
-		i++
-		lbName = req.FormValue(fmt.Sprintf("LoadBalancerNames.member.%d", i))
 	}
-	if lbsDesc == nil {
-		for _, lb := range srv.lbs {
-			lbsDesc = append(lbsDesc, *lb)
+	resp := elb.DescribeTagsResp{RequestId: reqId}
+	for _, name := range names {
+		if err := srv.lbExists(name); err != nil {
+			return nil, err
 		}
+		resp.TagDescriptions = append(resp.TagDescriptions, elb.TagDescription{
+			LoadBalancerName: name,
+			Tags:             srv.lbs[name].tags,
+		})
+	}
+	return resp, nil
+}
+
+func (srv *Server) describeAccountLimits(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
+	limits := map[string]int{"classic-listeners": srv.maxListeners}
+	for name, max := range srv.limits {
+		limits[name] = max
 	}
-	resp := elb.DescribeLoadBalancerResp{
-		LoadBalancerDescriptions: lbsDesc,
+	names := make([]string, 0, len(limits))
+	for name := range limits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	resp := elb.DescribeAccountLimitsResp{RequestId: reqId}
+	for _, name := range names {
+		resp.Limits = append(resp.Limits, elb.Limit{Name: name, Max: strconv.Itoa(limits[name])})
 	}
 	return resp, nil
 }
@@ -242,6 +2416,65 @@ func (srv *Server) makeInstanceState(id string) *elb.InstanceState {
 	}
 }
 
+func (srv *Server) isRegistered(lb *elb.LoadBalancerDescription, id string) bool {
+	for _, instance := range lb.Instances {
+		if instance.InstanceId == id {
+			return true
+		}
+	}
+	return false
+}
+
+// drainInstance removes the state of an instance deregistered from a load
+// balancer. When connection draining is enabled, the state is kept until the
+// draining timeout has passed, see SetConnectionDrainingUnit.
+func (srv *Server) drainInstance(lbName, id string) {
+	lb, ok := srv.lbs[lbName]
+	if !ok {
+		return
+	}
+	attrs := lb.attributes
+	if attrs.ConnectionDraining == nil || !attrs.ConnectionDraining.Enabled {
+		srv.removeInstanceStatesFromLoadBalancer(lbName, id)
+		return
+	}
+	for _, state := range lb.instanceStates {
+		if state.InstanceId == id && state.State == "InService" {
+			state.Description = "Instance deregistration currently in progress."
+		}
+	}
+	timeout := time.Duration(attrs.ConnectionDraining.Timeout) * srv.drainingUnit
+	lb.draining[id] = srv.clock.Now().Add(timeout)
+}
+
+// expireDraining removes the states of the instances of a load balancer
+// whose draining timeout has passed.
+func (srv *Server) expireDraining(lbName string) {
+	lb, ok := srv.lbs[lbName]
+	if !ok {
+		return
+	}
+	now := srv.clock.Now()
+	for id, end := range lb.draining {
+		if !end.After(now) {
+			srv.stopDraining(lbName, id)
+		}
+	}
+}
+
+// stopDraining removes the state of an instance that is draining from a load
+// balancer, if any.
+func (srv *Server) stopDraining(lbName, id string) {
+	lb, ok := srv.lbs[lbName]
+	if !ok {
+		return
+	}
+	if _, ok := lb.draining[id]; ok {
+		delete(lb.draining, id)
+		srv.removeInstanceStatesFromLoadBalancer(lbName, id)
+	}
+}
+
 func removeInstanceFromLB(lb *elb.LoadBalancerDescription, id string) {
 	index := -1
 	for i, instance := range lb.Instances {
@@ -256,18 +2489,24 @@ func removeInstanceFromLB(lb *elb.LoadBalancerDescription, id string) {
 	}
 }
 
-func (srv *Server) removeInstanceStatesFromLoadBalancer(lb, id string) {
-	for i, state := range srv.instanceStates[lb] {
+// removeInstanceStatesFromLoadBalancer removes the state of an instance from
+// a load balancer, keeping the other states in registration order.
+func (srv *Server) removeInstanceStatesFromLoadBalancer(lbName, id string) {
+	lb, ok := srv.lbs[lbName]
+	if !ok {
+		return
+	}
+	for i, state := range lb.instanceStates {
 		if state.InstanceId == id {
-			a := srv.instanceStates[lb]
-			a[i], a = a[len(a)-1], a[:len(a)-1]
-			srv.instanceStates[lb] = a
+			lb.instanceStates = append(lb.instanceStates[:i], lb.instanceStates[i+1:]...)
 			return
 		}
 	}
 }
 
-func (srv *Server) makeLoadBalancerDescription(value url.Values) *elb.LoadBalancerDescription {
+// makeListenerDescriptions returns the listeners in the
+// Listeners.member.N parameters of a request.
+func (srv *Server) makeListenerDescriptions(value url.Values) []elb.ListenerDescription {
 	lds := []elb.ListenerDescription{}
 	i := 1
 	protocol := value.Get(fmt.Sprintf("Listeners.member.%d.Protocol", i))
@@ -281,18 +2520,175 @@ func (srv *Server) makeLoadBalancerDescription(value url.Values) *elb.LoadBalanc
 				InstanceProtocol: strings.ToUpper(value.Get(key + "InstanceProtocol")),
 				LoadBalancerPort: lLBPort,
 				InstancePort:     lInstPort,
+				SSLCertificateId: value.Get(key + "SSLCertificateId"),
 			},
 		}
 		i++
 		protocol = value.Get(fmt.Sprintf("Listeners.member.%d.Protocol", i))
 		lds = append(lds, lDescription)
 	}
+	return lds
+}
+
+// validateListeners validates the listeners of a request, given the listeners
+// that already exist in the Load Balancer.
+//
+// The protocols of listeners must be HTTP, HTTPS, TCP or SSL, and their ports
+// must be in the ranges allowed by ELB, see validPort. HTTPS and SSL
+// listeners must have a certificate whose id is an IAM or ACM certificate
+// ARN. HTTP and HTTPS listeners must forward to HTTP or HTTPS
+// instance ports, and TCP and SSL listeners to TCP or SSL instance ports. Two
+// listeners can't share a Load Balancer port, unless the new one is identical
+// to the existing one, in which case it is ignored by the caller. A Load
+// Balancer can't have more listeners than the limit set with SetMaxListeners.
+//
+// Outside of strict mode, the protocols of instance ports aren't matched
+// against the protocols of their listeners, and certificates don't need to be
+// registered.
+func (srv *Server) validateListeners(existing, lds []elb.ListenerDescription) error {
+	ports := make(map[int]elb.Listener, len(existing)+len(lds))
+	for _, ld := range existing {
+		ports[ld.Listener.LoadBalancerPort] = ld.Listener
+	}
+	requested := make(map[int]bool, len(lds))
+	for i, ld := range lds {
+		l := ld.Listener
+		key := fmt.Sprintf("Listeners.member.%d.", i+1)
+		if !validProtocols[l.Protocol] {
+			return invalidProtocol(l.Protocol, key+"Protocol")
+		}
+		if l.InstanceProtocol != "" && !validProtocols[l.InstanceProtocol] {
+			return invalidProtocol(l.InstanceProtocol, key+"InstanceProtocol")
+		}
+		if !validPort(l.LoadBalancerPort, true) {
+			return &elb.Error{
+				StatusCode: 400,
+				Code:       "ValidationError",
+				Message:    fmt.Sprintf("Invalid value '%d' for %sLoadBalancerPort, it must be 25, 80, 443, 465, 587 or between 1024 and 65535", l.LoadBalancerPort, key),
+			}
+		}
+		if !validPort(l.InstancePort, false) {
+			return &elb.Error{
+				StatusCode: 400,
+				Code:       "ValidationError",
+				Message:    fmt.Sprintf("Invalid value '%d' for %sInstancePort, it must be between 1 and 65535", l.InstancePort, key),
+			}
+		}
+		if err := srv.validateCertificate(&l, key); err != nil {
+			return err
+		}
+		other, found := ports[l.LoadBalancerPort]
+		if requested[l.LoadBalancerPort] || (found && other != l) {
+			return &elb.Error{
+				StatusCode: 400,
+				Code:       "DuplicateListener",
+				Message:    fmt.Sprintf("A listener already exists for LoadBalancerPort %d, but with a different InstancePort, Protocol, or SSLCertificateId", l.LoadBalancerPort),
+			}
+		}
+		requested[l.LoadBalancerPort] = true
+		if srv.strict && l.InstanceProtocol != "" && protocolFamily(l.Protocol) != protocolFamily(l.InstanceProtocol) {
+			return &elb.Error{
+				StatusCode: 400,
+				Code:       "ValidationError",
+				Message:    fmt.Sprintf("Listener on port %d using %s can't forward to instance port %d using %s", l.LoadBalancerPort, l.Protocol, l.InstancePort, l.InstanceProtocol),
+			}
+		}
+	}
+	count := len(existing)
+	for port := range requested {
+		if _, found := ports[port]; !found {
+			count++
+		}
+	}
+	if count > srv.maxListeners {
+		return &elb.Error{
+			StatusCode: 400,
+			Code:       "InvalidConfigurationRequest",
+			Message:    fmt.Sprintf("A Load Balancer can have at most %d listeners", srv.maxListeners),
+		}
+	}
+	return nil
+}
+
+// validateCertificate checks the certificate of HTTPS and SSL listeners,
+// which is required and must be an IAM or ACM certificate ARN, see
+// elb.ValidateListener. In strict mode, the certificate must also be
+// registered with NewCertificate. key is the prefix of the parameters of
+// the listener in the request.
+func (srv *Server) validateCertificate(l *elb.Listener, key string) error {
+	if l.Protocol != "HTTPS" && l.Protocol != "SSL" {
+		return nil
+	}
+	if l.SSLCertificateId == "" {
+		return &elb.Error{
+			StatusCode: 400,
+			Code:       "ValidationError",
+			Message:    fmt.Sprintf("%sSSLCertificateId is required by %s listeners", key, l.Protocol),
+		}
+	}
+	if err := elb.ValidateListener(l); err != nil {
+		e := err.(*elb.Error)
+		e.StatusCode = 400
+		return e
+	}
+	if srv.strict && !srv.certificates[l.SSLCertificateId] {
+		return &elb.Error{
+			StatusCode: 400,
+			Code:       "CertificateNotFound",
+			Message:    fmt.Sprintf("Server certificate %s was not found", l.SSLCertificateId),
+		}
+	}
+	return nil
+}
+
+// validPort returns whether a port can be used by a listener. Instance ports
+// can be anything between 1 and 65535, but Load Balancer ports below 1024 are
+// restricted to 25, 80, 443, 465 and 587.
+func validPort(port int, front bool) bool {
+	if port < 1 || port > 65535 {
+		return false
+	}
+	if !front || port >= 1024 {
+		return true
+	}
+	switch port {
+	case 25, 80, 443, 465, 587:
+		return true
+	}
+	return false
+}
+
+// validProtocols holds the protocols of listeners and instance ports.
+var validProtocols = map[string]bool{"HTTP": true, "HTTPS": true, "TCP": true, "SSL": true}
+
+func invalidProtocol(protocol, key string) error {
+	return &elb.Error{
+		StatusCode: 400,
+		Code:       "ValidationError",
+		Message:    fmt.Sprintf("Invalid value '%s' for %s, it must be one of HTTP, HTTPS, TCP or SSL", protocol, key),
+	}
+}
+
+// protocolFamily returns the protocol that a listener protocol is a secure
+// or plain variant of, HTTP for HTTP and HTTPS, and TCP for TCP and SSL.
+func protocolFamily(protocol string) string {
+	switch strings.ToUpper(protocol) {
+	case "HTTP", "HTTPS":
+		return "HTTP"
+	case "TCP", "SSL":
+		return "TCP"
+	}
+	return protocol
+}
+
+func (srv *Server) makeLoadBalancerDescription(value url.Values) *elb.LoadBalancerDescription {
+	lds := srv.makeListenerDescriptions(value)
 	sourceSecGroup := srv.makeSourceSecGroup(value)
 	lbDesc := elb.LoadBalancerDescription{
 		AvailZones:           srv.getParameters("AvailabilityZones.member.", value),
 		Subnets:              srv.getParameters("Subnets.member.", value),
 		SecurityGroups:       srv.getParameters("SecurityGroups.member.", value),
-		HealthCheck:          srv.makeHealthCheck(value),
+		HealthCheck:          srv.makeHealthCheck(value, lds),
 		ListenerDescriptions: lds,
 		Scheme:               value.Get("Scheme"),
 		SourceSecurityGroup:  sourceSecGroup,
@@ -304,12 +2700,18 @@ func (srv *Server) makeLoadBalancerDescription(value url.Values) *elb.LoadBalanc
 	return &lbDesc
 }
 
-func (srv *Server) makeHealthCheck(value url.Values) elb.HealthCheck {
+// makeHealthCheck returns the health check of a new load balancer. Like in
+// AWS, the default health check targets the instance port of the first
+// listener. The defaults can be overridden by HealthCheck.* values.
+func (srv *Server) makeHealthCheck(value url.Values, lds []elb.ListenerDescription) elb.HealthCheck {
 	ht := 10
 	timeout := 5
 	ut := 2
 	interval := 30
 	target := "TCP:80"
+	if len(lds) > 0 {
+		target = fmt.Sprintf("TCP:%d", lds[0].Listener.InstancePort)
+	}
 	if v := value.Get("HealthCheck.HealthyThreshold"); v != "" {
 		ht, _ = strconv.Atoi(v)
 	}
@@ -349,35 +2751,48 @@ func (srv *Server) makeSourceSecGroup(value url.Values) elb.SourceSecurityGroup
 	}
 }
 
+// describeInstanceHealth returns the states of the instances registered with
+// the load balancer or, when instances are given, the states of those
+// instances only, in the order they were given. Instances that exist but are
+// not registered with the load balancer are reported as pending.
 func (srv *Server) describeInstanceHealth(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
-	if err := srv.lbExists(req.FormValue("LoadBalancerName")); err != nil {
+	lbName := req.FormValue("LoadBalancerName")
+	if err := srv.lbExists(lbName); err != nil {
 		return nil, err
 	}
+	srv.expireDraining(lbName)
 	resp := elb.DescribeInstanceHealthResp{
 		InstanceStates: []elb.InstanceState{},
 	}
-	for _, state := range srv.instanceStates[req.FormValue("LoadBalancerName")] {
-		resp.InstanceStates = append(resp.InstanceStates, *state)
-	}
-	i := 1
+	states := srv.lbs[lbName].instanceStates
 	instanceId := req.FormValue("Instances.member.1.InstanceId")
-	for instanceId != "" {
+	if instanceId == "" {
+		for _, state := range states {
+			resp.InstanceStates = append(resp.InstanceStates, *state)
+		}
+		return resp, nil
+	}
+	byId := make(map[string]*elb.InstanceState, len(states))
+	for _, state := range states {
+		byId[state.InstanceId] = state
+	}
+	for i := 2; instanceId != ""; i++ {
 		if err := srv.instanceExists(instanceId); err != nil {
 			return nil, err
 		}
-		is := elb.InstanceState{
-			Description: "Instance is in pending state.",
-			InstanceId:  instanceId,
-			State:       "OutOfService",
-			ReasonCode:  "Instance",
+		state, ok := byId[instanceId]
+		if !ok {
+			state = srv.makeInstanceState(instanceId)
 		}
-		resp.InstanceStates = append(resp.InstanceStates, is)
-		i++
+		resp.InstanceStates = append(resp.InstanceStates, *state)
 		instanceId = req.FormValue(fmt.Sprintf("Instances.member.%d.InstanceId", i))
 	}
 	return resp, nil
 }
 
+// HTTP and HTTPS targets must specify a path, TCP and SSL targets must not.
+var healthCheckTargetRegexp = regexp.MustCompile(`^((HTTP|HTTPS):\d+/.*|(TCP|SSL):\d+)$`)
+
 func (srv *Server) configureHealthCheck(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
 	required := []string{
 		"LoadBalancerName",
@@ -390,12 +2805,12 @@ func (srv *Server) configureHealthCheck(w http.ResponseWriter, req *http.Request
 	if err := srv.validate(req, required); err != nil {
 		return nil, err
 	}
-	target := req.FormValue("HealthCheck.Target")
-	r, err := regexp.Compile(`[\w]+:[\d]+\/+`)
-	if err != nil {
-		panic(err)
+	lbName := req.FormValue("LoadBalancerName")
+	if err := srv.lbExists(lbName); err != nil {
+		return nil, err
 	}
-	if m := r.FindStringSubmatch(target); m == nil {
+	target := req.FormValue("HealthCheck.Target")
+	if !healthCheckTargetRegexp.MatchString(target) {
 		return nil, &elb.Error{
 			StatusCode: 400,
 			Code:       "ValidationError",
@@ -406,15 +2821,15 @@ func (srv *Server) configureHealthCheck(w http.ResponseWriter, req *http.Request
 	interval, _ := strconv.Atoi(req.FormValue("HealthCheck.Interval"))
 	timeout, _ := strconv.Atoi(req.FormValue("HealthCheck.Timeout"))
 	ut, _ := strconv.Atoi(req.FormValue("HealthCheck.UnhealthyThreshold"))
-	return elb.HealthCheckResp{
-		HealthCheck: &elb.HealthCheck{
-			HealthyThreshold:   ht,
-			Interval:           interval,
-			Target:             target,
-			Timeout:            timeout,
-			UnhealthyThreshold: ut,
-		},
-	}, nil
+	hc := elb.HealthCheck{
+		HealthyThreshold:   ht,
+		Interval:           interval,
+		Target:             target,
+		Timeout:            timeout,
+		UnhealthyThreshold: ut,
+	}
+	srv.lbs[lbName].desc.HealthCheck = hc
+	return elb.HealthCheckResp{HealthCheck: &hc}, nil
 }
 
 func (srv *Server) instanceExists(id string) error {
@@ -441,6 +2856,253 @@ func (srv *Server) lbExists(name string) error {
 	return nil
 }
 
+// paramType is the type of the value of a request parameter.
+type paramType int
+
+const (
+	stringParam paramType = iota
+	intParam
+	boolParam
+)
+
+// commonParams are the parameters that every request may have, most of them
+// sent by the signature of the request.
+var commonParams = map[string]bool{
+	"Action":           true,
+	"Version":          true,
+	"Timestamp":        true,
+	"Expires":          true,
+	"AWSAccessKeyId":   true,
+	"Signature":        true,
+	"SignatureMethod":  true,
+	"SignatureVersion": true,
+	"SecurityToken":    true,
+}
+
+// actionParams holds the parameters documented for each action, keyed by
+// their name. The members of lists are numbered with N, like in
+// Listeners.member.N.Protocol.
+var actionParams = map[string]map[string]paramType{
+	"CreateLoadBalancer": {
+		"LoadBalancerName":                    stringParam,
+		"AvailabilityZones.member.N":          stringParam,
+		"Subnets.member.N":                    stringParam,
+		"SecurityGroups.member.N":             stringParam,
+		"Scheme":                              stringParam,
+		"Listeners.member.N.Protocol":         stringParam,
+		"Listeners.member.N.LoadBalancerPort": intParam,
+		"Listeners.member.N.InstanceProtocol": stringParam,
+		"Listeners.member.N.InstancePort":     intParam,
+		"Listeners.member.N.SSLCertificateId": stringParam,
+		"Tags.member.N.Key":                   stringParam,
+		"Tags.member.N.Value":                 stringParam,
+	},
+	"DeleteLoadBalancer": {"LoadBalancerName": stringParam},
+	"RegisterInstancesWithLoadBalancer": {
+		"LoadBalancerName":              stringParam,
+		"Instances.member.N.InstanceId": stringParam,
+	},
+	"DeregisterInstancesFromLoadBalancer": {
+		"LoadBalancerName":              stringParam,
+		"Instances.member.N.InstanceId": stringParam,
+	},
+	"DescribeLoadBalancers": {
+		"LoadBalancerNames.member.N": stringParam,
+		"Marker":                     stringParam,
+		"PageSize":                   intParam,
+	},
+	"DescribeInstanceHealth": {
+		"LoadBalancerName":              stringParam,
+		"Instances.member.N.InstanceId": stringParam,
+	},
+	"ConfigureHealthCheck": {
+		"LoadBalancerName":               stringParam,
+		"HealthCheck.Target":             stringParam,
+		"HealthCheck.Interval":           intParam,
+		"HealthCheck.Timeout":            intParam,
+		"HealthCheck.HealthyThreshold":   intParam,
+		"HealthCheck.UnhealthyThreshold": intParam,
+	},
+	"CreateLoadBalancerListeners": {
+		"LoadBalancerName":                    stringParam,
+		"Listeners.member.N.Protocol":         stringParam,
+		"Listeners.member.N.LoadBalancerPort": intParam,
+		"Listeners.member.N.InstanceProtocol": stringParam,
+		"Listeners.member.N.InstancePort":     intParam,
+		"Listeners.member.N.SSLCertificateId": stringParam,
+	},
+	"DeleteLoadBalancerListeners": {
+		"LoadBalancerName":           stringParam,
+		"LoadBalancerPorts.member.N": intParam,
+	},
+	"ModifyLoadBalancerAttributes": {
+		"LoadBalancerName":                                           stringParam,
+		"LoadBalancerAttributes.AccessLog.Enabled":                   boolParam,
+		"LoadBalancerAttributes.AccessLog.S3BucketName":              stringParam,
+		"LoadBalancerAttributes.AccessLog.S3BucketPrefix":            stringParam,
+		"LoadBalancerAttributes.AccessLog.EmitInterval":              intParam,
+		"LoadBalancerAttributes.ConnectionDraining.Enabled":          boolParam,
+		"LoadBalancerAttributes.ConnectionDraining.Timeout":          intParam,
+		"LoadBalancerAttributes.ConnectionSettings.IdleTimeout":      intParam,
+		"LoadBalancerAttributes.CrossZoneLoadBalancing.Enabled":      boolParam,
+		"LoadBalancerAttributes.AdditionalAttributes.member.N.Key":   stringParam,
+		"LoadBalancerAttributes.AdditionalAttributes.member.N.Value": stringParam,
+	},
+	"DescribeLoadBalancerAttributes": {"LoadBalancerName": stringParam},
+	"AttachLoadBalancerToSubnets": {
+		"LoadBalancerName": stringParam,
+		"Subnets.member.N": stringParam,
+	},
+	"DetachLoadBalancerFromSubnets": {
+		"LoadBalancerName": stringParam,
+		"Subnets.member.N": stringParam,
+	},
+	"ApplySecurityGroupsToLoadBalancer": {
+		"LoadBalancerName":        stringParam,
+		"SecurityGroups.member.N": stringParam,
+	},
+	"SetLoadBalancerPoliciesForBackendServer": {
+		"LoadBalancerName":     stringParam,
+		"InstancePort":         intParam,
+		"PolicyNames":          stringParam,
+		"PolicyNames.member.N": stringParam,
+	},
+	"EnableAvailabilityZonesForLoadBalancer": {
+		"LoadBalancerName":           stringParam,
+		"AvailabilityZones.member.N": stringParam,
+	},
+	"DisableAvailabilityZonesForLoadBalancer": {
+		"LoadBalancerName":           stringParam,
+		"AvailabilityZones.member.N": stringParam,
+	},
+	"SetLoadBalancerListenerSSLCertificate": {
+		"LoadBalancerName": stringParam,
+		"LoadBalancerPort": intParam,
+		"SSLCertificateId": stringParam,
+	},
+	"CreateLBCookieStickinessPolicy": {
+		"LoadBalancerName":       stringParam,
+		"PolicyName":             stringParam,
+		"CookieExpirationPeriod": intParam,
+	},
+	"CreateAppCookieStickinessPolicy": {
+		"LoadBalancerName": stringParam,
+		"PolicyName":       stringParam,
+		"CookieName":       stringParam,
+	},
+	"CreateLoadBalancerPolicy": {
+		"LoadBalancerName": stringParam,
+		"PolicyName":       stringParam,
+		"PolicyTypeName":   stringParam,
+		"PolicyAttributes.member.N.AttributeName":  stringParam,
+		"PolicyAttributes.member.N.AttributeValue": stringParam,
+	},
+	"DeleteLoadBalancerPolicy": {
+		"LoadBalancerName": stringParam,
+		"PolicyName":       stringParam,
+	},
+	"DescribeLoadBalancerPolicies": {
+		"LoadBalancerName":     stringParam,
+		"PolicyNames.member.N": stringParam,
+	},
+	"DescribeLoadBalancerPolicyTypes": {"PolicyTypeNames.member.N": stringParam},
+	"SetLoadBalancerPoliciesOfListener": {
+		"LoadBalancerName":     stringParam,
+		"LoadBalancerPort":     intParam,
+		"PolicyNames":          stringParam,
+		"PolicyNames.member.N": stringParam,
+	},
+	"AddTags": {
+		"LoadBalancerNames.member.N": stringParam,
+		"Tags.member.N.Key":          stringParam,
+		"Tags.member.N.Value":        stringParam,
+	},
+	"RemoveTags": {
+		"LoadBalancerNames.member.N": stringParam,
+		"Tags.member.N.Key":          stringParam,
+	},
+	"DescribeTags": {"LoadBalancerNames.member.N": stringParam},
+	"DescribeAccountLimits": {
+		"Marker":   stringParam,
+		"PageSize": intParam,
+	},
+}
+
+var memberRegexp = regexp.MustCompile(`\.member\.([^.]*)`)
+
+// validateParams checks, in strict mode, that the parameters of a request
+// are documented for its action, that their values have the documented
+// type, and that the members of its lists are numbered from 1 with no gaps.
+func (srv *Server) validateParams(req *http.Request) error {
+	if !srv.strict {
+		return nil
+	}
+	action := req.Form.Get("Action")
+	params := actionParams[action]
+	// the indexes given to the members of each list, keyed by the prefix of
+	// the list, like Listeners.member.
+	lists := make(map[string]map[int]bool)
+	for key, values := range req.Form {
+		if commonParams[key] {
+			continue
+		}
+		var invalidIndex bool
+		name := memberRegexp.ReplaceAllStringFunc(key, func(m string) string {
+			index := strings.TrimPrefix(m, ".member.")
+			n, err := strconv.Atoi(index)
+			if err != nil || n < 1 || strconv.Itoa(n) != index {
+				invalidIndex = true
+				return m
+			}
+			prefix := key[:strings.Index(key, m)+len(".member.")]
+			if lists[prefix] == nil {
+				lists[prefix] = make(map[int]bool)
+			}
+			lists[prefix][n] = true
+			return ".member.N"
+		})
+		typ, ok := params[name]
+		if invalidIndex || !ok {
+			return &elb.Error{
+				StatusCode: 400,
+				Code:       "ValidationError",
+				Message:    fmt.Sprintf("%s is not a valid parameter of %s", key, action),
+			}
+		}
+		value := values[0]
+		switch typ {
+		case intParam:
+			if _, err := strconv.Atoi(value); err != nil {
+				return &elb.Error{
+					StatusCode: 400,
+					Code:       "ValidationError",
+					Message:    fmt.Sprintf("Invalid value '%s' for %s, it must be an integer", value, key),
+				}
+			}
+		case boolParam:
+			if value != "true" && value != "false" {
+				return &elb.Error{
+					StatusCode: 400,
+					Code:       "ValidationError",
+					Message:    fmt.Sprintf("Invalid value '%s' for %s, it must be true or false", value, key),
+				}
+			}
+		}
+	}
+	for prefix, indexes := range lists {
+		for n := 1; n <= len(indexes); n++ {
+			if !indexes[n] {
+				return &elb.Error{
+					StatusCode: 400,
+					Code:       "ValidationError",
+					Message:    fmt.Sprintf("%s%d is missing, the members of a list must be numbered from 1 with no gaps", prefix, n),
+				}
+			}
+		}
+	}
+	return nil
+}
+
 func (srv *Server) validate(req *http.Request, required []string) error {
 	for _, field := range required {
 		if req.FormValue(field) == "" {
@@ -454,6 +3116,17 @@ func (srv *Server) validate(req *http.Request, required []string) error {
 	return nil
 }
 
+// Validates the name of a load balancer, using the same rules enforced by the
+// client.
+func (srv *Server) validateLoadBalancerName(name string) error {
+	if err := elb.ValidateLoadBalancerName(name); err != nil {
+		e := err.(*elb.Error)
+		e.StatusCode = 400
+		return e
+	}
+	return nil
+}
+
 // Validates the composition of the fields.
 //
 // Some fields cannot be together in the same request, such as AvailabilityZones and Subnets.
@@ -504,51 +3177,797 @@ func (srv *Server) RemoveInstance(instId string) {
 
 // Creates a fake load balancer in the fake server
 func (srv *Server) NewLoadBalancer(name string) {
-	srv.lbs[name] = &elb.LoadBalancerDescription{
+	srv.lbs[name] = newLoadBalancer(elb.LoadBalancerDescription{
 		LoadBalancerName: name,
-		DNSName:          fmt.Sprintf("%s-some-aws-stuff.sa-east-1.amazonaws.com", name),
+		DNSName:          srv.dnsName(name, nil),
+		HealthCheck:      srv.makeHealthCheck(nil, nil),
+	})
+	srv.emit(Event{Type: LBCreated, LoadBalancer: name})
+}
+
+// Registers a fake subnet, so it can be used in load balancers. In strict
+// mode, load balancers can't be created in or attached to subnets that are
+// not registered.
+func (srv *Server) NewSubnet(id string) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	srv.subnets[id] = true
+}
+
+// Removes a fake subnet. Load balancers already in the subnet are not
+// changed.
+func (srv *Server) RemoveSubnet(id string) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	delete(srv.subnets, id)
+}
+
+// Registers a fake SSL certificate, given its ARN. In strict mode, HTTPS and
+// SSL listeners can only use registered certificates.
+func (srv *Server) NewCertificate(arn string) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	srv.certificates[arn] = true
+}
+
+// Removes a fake SSL certificate. Listeners already using the certificate
+// are not changed.
+func (srv *Server) RemoveCertificate(arn string) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	delete(srv.certificates, arn)
+}
+
+// Registers a fake security group. In strict mode, load balancers can only
+// be created with or applied registered security groups.
+func (srv *Server) NewSecurityGroup(id string) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	srv.securityGroups[id] = true
+}
+
+// Removes a fake security group. Load balancers already using the security
+// group are not changed.
+func (srv *Server) RemoveSecurityGroup(id string) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	delete(srv.securityGroups, id)
+}
+
+// LoadBalancerOptions holds the state of a load balancer that isn't part of
+// its description, see AddLoadBalancer.
+type LoadBalancerOptions struct {
+	// Attributes holds the attributes of the load balancer. Attributes
+	// that are nil keep their defaults.
+	Attributes *elb.LoadBalancerAttributes
+	// Tags holds the tags of the load balancer.
+	Tags []elb.Tag
+	// Policies holds the full description of policies of the load
+	// balancer, like ProxyProtocol policies, which can't be told from the
+	// Policies of its description. They replace the policies of the
+	// description with the same name.
+	Policies []elb.PolicyDescription
+}
+
+// AddLoadBalancer adds a fully configured load balancer to the fake server,
+// as if it had been created and configured through the API. The instances of
+// the load balancer are added to the fake instances if needed, and reported
+// as InService. Load balancers added this way are visible right away, even
+// if a consistency delay is set, unless CreatedTime says otherwise.
+//
+// The description is validated minimally: it must have a name not used by
+// another load balancer, and its listeners can't share a load balancer port.
+// The health check is set to the default when it has no target. Attributes,
+// tags and policies of the load balancer are taken from opts, the attributes
+// not given there are set to the defaults.
+func (srv *Server) AddLoadBalancer(desc elb.LoadBalancerDescription, opts ...LoadBalancerOptions) error {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	name := desc.LoadBalancerName
+	if name == "" {
+		return fmt.Errorf("load balancer has no name")
+	}
+	if _, ok := srv.lbs[name]; ok {
+		return fmt.Errorf("load balancer %q already exists", name)
+	}
+	ports := make(map[int]bool, len(desc.ListenerDescriptions))
+	for _, ld := range desc.ListenerDescriptions {
+		if ports[ld.Listener.LoadBalancerPort] {
+			return fmt.Errorf("load balancer %q has more than one listener on port %d", name, ld.Listener.LoadBalancerPort)
+		}
+		ports[ld.Listener.LoadBalancerPort] = true
+	}
+	attrs := defaultAttributes()
+	var tags []elb.Tag
+	var policies []*policy
+	for _, o := range opts {
+		if o.Attributes != nil {
+			given := copyAttributes(o.Attributes)
+			if given.AccessLog != nil {
+				attrs.AccessLog = given.AccessLog
+			}
+			if given.ConnectionDraining != nil {
+				attrs.ConnectionDraining = given.ConnectionDraining
+			}
+			if given.ConnectionSettings != nil {
+				attrs.ConnectionSettings = given.ConnectionSettings
+			}
+			if given.CrossZoneLoadBalancing != nil {
+				attrs.CrossZoneLoadBalancing = given.CrossZoneLoadBalancing
+			}
+		}
+		tags = append(tags, o.Tags...)
+		for _, desc := range o.Policies {
+			if _, err := findPolicyType(desc.PolicyTypeName); err != nil {
+				return fmt.Errorf("policy %q of load balancer %q has unknown type %q", desc.PolicyName, name, desc.PolicyTypeName)
+			}
+			policies = append(policies, makePolicy(desc))
+		}
+	}
+	keys := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		if keys[tag.Key] {
+			return fmt.Errorf("load balancer %q has more than one tag with key %q", name, tag.Key)
+		}
+		keys[tag.Key] = true
+	}
+	lb := copyLoadBalancerDescription(&desc)
+	if lb.DNSName == "" {
+		lb.DNSName = srv.dnsName(name, lb.AvailZones)
+	}
+	if lb.CreatedTime.IsZero() {
+		lb.CreatedTime = srv.clock.Now().UTC().Add(-srv.createDelay)
+	}
+	if lb.HealthCheck.Target == "" {
+		lb.HealthCheck = srv.makeHealthCheck(nil, lb.ListenerDescriptions)
+	}
+	state := newLoadBalancer(lb)
+	state.attributes = attrs
+	state.tags = tags
+	for _, p := range makePolicies(lb.Policies) {
+		given := false
+		for _, other := range policies {
+			given = given || other.name == p.name
+		}
+		if !given {
+			state.policies = append(state.policies, p)
+		}
+	}
+	state.policies = append(state.policies, policies...)
+	for _, instance := range lb.Instances {
+		if srv.instanceExists(instance.InstanceId) != nil {
+			srv.instances = append(srv.instances, instance.InstanceId)
+		}
+		state.instanceStates = append(state.instanceStates, &elb.InstanceState{
+			Description: "N/A",
+			InstanceId:  instance.InstanceId,
+			ReasonCode:  "N/A",
+			State:       "InService",
+		})
 	}
+	srv.lbs[name] = state
+	srv.updatePolicies(name)
+	srv.emit(Event{Type: LBCreated, LoadBalancer: name})
+	return nil
 }
 
-// Removes a fake load balancer from the fake server
+// Removes a fake load balancer from the fake server, along with its
+// listeners, instances, health check, attributes, policies and tags, so a
+// load balancer created later with the same name starts from scratch.
 func (srv *Server) RemoveLoadBalancer(name string) {
+	if _, ok := srv.lbs[name]; ok {
+		srv.emit(Event{Type: LBDeleted, LoadBalancer: name})
+	}
 	delete(srv.lbs, name)
 }
 
 // Register a fake instance with a fake Load Balancer
 //
-// If the Load Balancer does not exists it does nothing
+// If the Load Balancer does not exists or the instance is already registered
+// with it, it does nothing
 func (srv *Server) RegisterInstance(instId, lbName string) {
 	lb, ok := srv.lbs[lbName]
 	if !ok {
 		fmt.Println("lb not found :/")
 		return
 	}
-	lb.Instances = append(lb.Instances, elb.Instance{InstanceId: instId})
-	srv.instanceStates[lbName] = append(srv.instanceStates[lbName], srv.makeInstanceState(instId))
+	for _, instance := range lb.desc.Instances {
+		if instance.InstanceId == instId {
+			return
+		}
+	}
+	srv.stopDraining(lbName, instId)
+	lb.desc.Instances = append(lb.desc.Instances, elb.Instance{InstanceId: instId})
+	lb.instanceStates = append(lb.instanceStates, srv.makeInstanceState(instId))
+	srv.emit(Event{Type: InstanceRegistered, LoadBalancer: lbName, InstanceId: instId})
 }
 
 func (srv *Server) DeregisterInstance(instId, lbName string) {
-	removeInstanceFromLB(srv.lbs[lbName], instId)
+	lb, ok := srv.lbs[lbName]
+	if !ok {
+		return
+	}
+	if srv.isRegistered(&lb.desc, instId) {
+		srv.emit(Event{Type: InstanceDeregistered, LoadBalancer: lbName, InstanceId: instId})
+	}
+	removeInstanceFromLB(&lb.desc, instId)
 	srv.removeInstanceStatesFromLoadBalancer(lbName, instId)
 }
 
-func (srv *Server) ChangeInstanceState(lb string, state elb.InstanceState) {
-	states := srv.instanceStates[lb]
-	for i, s := range states {
+func (srv *Server) ChangeInstanceState(lbName string, state elb.InstanceState) {
+	lb, ok := srv.lbs[lbName]
+	if !ok {
+		return
+	}
+	for i, s := range lb.instanceStates {
 		if s.InstanceId == state.InstanceId {
-			srv.instanceStates[lb][i] = &state
+			lb.instanceStates[i] = &state
 			return
 		}
 	}
 }
 
+// defaultEmitInterval is the interval, in minutes, at which an access log
+// enabled without an EmitInterval is published.
+const defaultEmitInterval = 60
+
+// defaultAttributes returns the attributes of a newly created Load Balancer.
+func defaultAttributes() *elb.LoadBalancerAttributes {
+	return &elb.LoadBalancerAttributes{
+		AccessLog:              &elb.AccessLog{Enabled: false},
+		ConnectionDraining:     &elb.ConnectionDraining{Enabled: false, Timeout: 300},
+		ConnectionSettings:     &elb.ConnectionSettings{IdleTimeout: 60},
+		CrossZoneLoadBalancing: &elb.CrossZoneLoadBalancing{Enabled: false},
+	}
+}
+
+func (srv *Server) modifyLoadBalancerAttributes(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
+	if err := srv.validate(req, []string{"LoadBalancerName"}); err != nil {
+		return nil, err
+	}
+	lbName := req.FormValue("LoadBalancerName")
+	if err := srv.lbExists(lbName); err != nil {
+		return nil, err
+	}
+	attrs, err := srv.makeAttributes(req.Form)
+	if err != nil {
+		return nil, err
+	}
+	if err := srv.validateAttributes(attrs); err != nil {
+		return nil, err
+	}
+	current := srv.lbs[lbName].attributes
+	if attrs.AccessLog != nil {
+		if attrs.AccessLog.Enabled && attrs.AccessLog.EmitInterval == 0 {
+			attrs.AccessLog.EmitInterval = defaultEmitInterval
+		}
+		current.AccessLog = attrs.AccessLog
+	}
+	if attrs.ConnectionDraining != nil {
+		if attrs.ConnectionDraining.Timeout == 0 {
+			attrs.ConnectionDraining.Timeout = current.ConnectionDraining.Timeout
+		}
+		current.ConnectionDraining = attrs.ConnectionDraining
+	}
+	if attrs.ConnectionSettings != nil {
+		current.ConnectionSettings = attrs.ConnectionSettings
+	}
+	if attrs.CrossZoneLoadBalancing != nil {
+		current.CrossZoneLoadBalancing = attrs.CrossZoneLoadBalancing
+	}
+	return elb.ModifyLoadBalancerAttributesResp{
+		LoadBalancerName:       lbName,
+		LoadBalancerAttributes: copyAttributes(attrs),
+		RequestId:              reqId,
+	}, nil
+}
+
+func (srv *Server) describeLoadBalancerAttributes(w http.ResponseWriter, req *http.Request, reqId string) (interface{}, error) {
+	if err := srv.validate(req, []string{"LoadBalancerName"}); err != nil {
+		return nil, err
+	}
+	lbName := req.FormValue("LoadBalancerName")
+	if err := srv.lbExists(lbName); err != nil {
+		return nil, err
+	}
+	return elb.DescribeLoadBalancerAttributesResp{
+		LoadBalancerAttributes: copyAttributes(srv.lbs[lbName].attributes),
+		RequestId:              reqId,
+	}, nil
+}
+
+// makeAttributes parses the attributes of a ModifyLoadBalancerAttributes
+// request. Attributes that are not in the request are left nil.
+func (srv *Server) makeAttributes(value url.Values) (*elb.LoadBalancerAttributes, error) {
+	var attrs elb.LoadBalancerAttributes
+	var err error
+	parseBool := func(key string) bool {
+		b, e := strconv.ParseBool(value.Get(key))
+		if e != nil && err == nil {
+			err = invalidAttribute(key, value.Get(key))
+		}
+		return b
+	}
+	parseInt := func(key string) int {
+		if value.Get(key) == "" {
+			return 0
+		}
+		n, e := strconv.Atoi(value.Get(key))
+		if e != nil && err == nil {
+			err = invalidAttribute(key, value.Get(key))
+		}
+		return n
+	}
+	key := "LoadBalancerAttributes.AccessLog."
+	if _, ok := value[key+"Enabled"]; ok {
+		attrs.AccessLog = &elb.AccessLog{
+			Enabled:        parseBool(key + "Enabled"),
+			S3BucketName:   value.Get(key + "S3BucketName"),
+			S3BucketPrefix: value.Get(key + "S3BucketPrefix"),
+			EmitInterval:   parseInt(key + "EmitInterval"),
+		}
+	}
+	key = "LoadBalancerAttributes.ConnectionDraining."
+	if _, ok := value[key+"Enabled"]; ok {
+		attrs.ConnectionDraining = &elb.ConnectionDraining{
+			Enabled: parseBool(key + "Enabled"),
+			Timeout: parseInt(key + "Timeout"),
+		}
+	}
+	key = "LoadBalancerAttributes.ConnectionSettings."
+	if _, ok := value[key+"IdleTimeout"]; ok {
+		attrs.ConnectionSettings = &elb.ConnectionSettings{
+			IdleTimeout: parseInt(key + "IdleTimeout"),
+		}
+	}
+	key = "LoadBalancerAttributes.CrossZoneLoadBalancing."
+	if _, ok := value[key+"Enabled"]; ok {
+		attrs.CrossZoneLoadBalancing = &elb.CrossZoneLoadBalancing{
+			Enabled: parseBool(key + "Enabled"),
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &attrs, nil
+}
+
+func invalidAttribute(key, value string) error {
+	return &elb.Error{
+		StatusCode: 400,
+		Code:       "ValidationError",
+		Message:    fmt.Sprintf("Invalid value '%s' for %s", value, key),
+	}
+}
+
+// validateAttributes validates the attributes of a
+// ModifyLoadBalancerAttributes request, the same way ELB does.
+//
+// An enabled access log requires a S3 bucket and an emit interval of 5 or 60
+// minutes, which defaults to 60. The connection draining timeout and the idle
+// timeout must be between 1 and 3600 seconds.
+func (srv *Server) validateAttributes(attrs *elb.LoadBalancerAttributes) error {
+	validationError := func(format string, a ...interface{}) error {
+		return &elb.Error{
+			StatusCode: 400,
+			Code:       "ValidationError",
+			Message:    fmt.Sprintf(format, a...),
+		}
+	}
+	if attrs.AccessLog == nil && attrs.ConnectionDraining == nil && attrs.ConnectionSettings == nil && attrs.CrossZoneLoadBalancing == nil {
+		return validationError("At least one attribute must be specified")
+	}
+	if l := attrs.AccessLog; l != nil && l.Enabled {
+		if l.S3BucketName == "" {
+			return validationError("S3BucketName is required when the access log is enabled")
+		}
+		if l.EmitInterval != 0 && l.EmitInterval != 5 && l.EmitInterval != 60 {
+			return validationError("EmitInterval must be 5 or 60 minutes, got %d", l.EmitInterval)
+		}
+	}
+	if d := attrs.ConnectionDraining; d != nil && d.Timeout != 0 && (d.Timeout < 1 || d.Timeout > 3600) {
+		return validationError("ConnectionDraining Timeout must be between 1 and 3600 seconds, got %d", d.Timeout)
+	}
+	if cs := attrs.ConnectionSettings; cs != nil && (cs.IdleTimeout < 1 || cs.IdleTimeout > 3600) {
+		return validationError("ConnectionSettings IdleTimeout must be between 1 and 3600 seconds, got %d", cs.IdleTimeout)
+	}
+	return nil
+}
+
+func copyAttributes(attrs *elb.LoadBalancerAttributes) elb.LoadBalancerAttributes {
+	var c elb.LoadBalancerAttributes
+	if attrs.AccessLog != nil {
+		l := *attrs.AccessLog
+		c.AccessLog = &l
+	}
+	if attrs.ConnectionDraining != nil {
+		d := *attrs.ConnectionDraining
+		c.ConnectionDraining = &d
+	}
+	if attrs.ConnectionSettings != nil {
+		cs := *attrs.ConnectionSettings
+		c.ConnectionSettings = &cs
+	}
+	if attrs.CrossZoneLoadBalancing != nil {
+		cz := *attrs.CrossZoneLoadBalancing
+		c.CrossZoneLoadBalancing = &cz
+	}
+	return c
+}
+
+// SetInstanceHealth sets the health of an instance registered with a load
+// balancer, as reported by DescribeInstanceHealth. The state is either
+// InService or OutOfService, see SetInstanceState for the reasons reported.
+func (srv *Server) SetInstanceHealth(lbName, instId, state string) error {
+	return srv.SetInstanceState(lbName, instId, state, "")
+}
+
+// instanceHealth holds the reason code and the default description reported
+// by DescribeInstanceHealth for each state of an instance.
+var instanceHealth = map[string]struct{ reasonCode, description string }{
+	"InService":    {"N/A", "N/A"},
+	"OutOfService": {"Instance", "Instance has failed at least the UnhealthyThreshold number of health checks consecutively."},
+	"Unknown":      {"ELB", "A transient error occurred. Please try again later."},
+}
+
+// SetInstanceState moves an instance registered with a load balancer to the
+// given state, which is InService, OutOfService or Unknown, as reported by
+// DescribeInstanceHealth. The reason is reported as the description of the
+// state, and when empty the one given by ELB is used. The reason code is
+// N/A for InService instances, Instance for OutOfService instances and ELB
+// for instances in the Unknown state.
+func (srv *Server) SetInstanceState(lbName, instId, state, reason string) error {
+	health, ok := instanceHealth[state]
+	if !ok {
+		return fmt.Errorf("invalid instance state %q, it must be InService, OutOfService or Unknown", state)
+	}
+	if reason == "" {
+		reason = health.description
+	}
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	srv.expireDraining(lbName)
+	lb, ok := srv.lbs[lbName]
+	if !ok {
+		return fmt.Errorf("instance %s is not registered with load balancer %s", instId, lbName)
+	}
+	for _, s := range lb.instanceStates {
+		if s.InstanceId == instId {
+			s.State = state
+			s.ReasonCode = health.reasonCode
+			s.Description = reason
+			srv.emit(Event{Type: InstanceStateChanged, LoadBalancer: lbName, InstanceId: instId, State: state})
+			return nil
+		}
+	}
+	return fmt.Errorf("instance %s is not registered with load balancer %s", instId, lbName)
+}
+
+// State is a copy of the state of the server at a given time, see Snapshot.
+type State struct {
+	// LoadBalancers holds the description of each load balancer, keyed by
+	// name.
+	LoadBalancers map[string]elb.LoadBalancerDescription
+	// InstanceStates holds the health of the instances registered with each
+	// load balancer, keyed by the name of the load balancer.
+	InstanceStates map[string][]elb.InstanceState
+	// Instances holds the ids of the fake instances.
+	Instances []string
+	// Attributes holds the attributes of each load balancer, keyed by name.
+	Attributes map[string]elb.LoadBalancerAttributes
+	// Tags holds the tags of each load balancer that has any, keyed by
+	// name.
+	Tags map[string][]elb.Tag
+
+	// the rest of the state is only used by Restore.
+	lbs            map[string]*loadBalancer
+	subnets        map[string]bool
+	securityGroups map[string]bool
+	certificates   map[string]bool
+	instCount      int
+}
+
+// Snapshot returns a deep copy of the state of the server, so tests can make
+// assertions on a consistent view of it while the server is still in use,
+// or bring the server back to it later with Restore.
+func (srv *Server) Snapshot() *State {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	state := &State{
+		LoadBalancers:  make(map[string]elb.LoadBalancerDescription, len(srv.lbs)),
+		InstanceStates: make(map[string][]elb.InstanceState, len(srv.lbs)),
+		Instances:      append([]string(nil), srv.instances...),
+		Attributes:     make(map[string]elb.LoadBalancerAttributes, len(srv.lbs)),
+		Tags:           make(map[string][]elb.Tag),
+		lbs:            copyLoadBalancers(srv.lbs),
+		subnets:        copySet(srv.subnets),
+		securityGroups: copySet(srv.securityGroups),
+		certificates:   copySet(srv.certificates),
+		instCount:      srv.instCount,
+	}
+	for name, lb := range srv.lbs {
+		state.LoadBalancers[name] = copyLoadBalancerDescription(&lb.desc)
+		states := make([]elb.InstanceState, len(lb.instanceStates))
+		for i, s := range lb.instanceStates {
+			states[i] = *s
+		}
+		state.InstanceStates[name] = states
+		state.Attributes[name] = copyAttributes(lb.attributes)
+		if len(lb.tags) > 0 {
+			state.Tags[name] = append([]elb.Tag(nil), lb.tags...)
+		}
+	}
+	return state
+}
+
+// LoadBalancers returns a copy of the description of every load balancer of
+// the server, sorted by name.
+func (srv *Server) LoadBalancers() []elb.LoadBalancerDescription {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	lbs := make([]elb.LoadBalancerDescription, 0, len(srv.lbs))
+	for _, lb := range srv.lbs {
+		lbs = append(lbs, copyLoadBalancerDescription(&lb.desc))
+	}
+	sort.Slice(lbs, func(i, j int) bool {
+		return lbs[i].LoadBalancerName < lbs[j].LoadBalancerName
+	})
+	return lbs
+}
+
+// LoadBalancer returns a copy of the description of the named load balancer,
+// and false if the server has no such load balancer.
+func (srv *Server) LoadBalancer(name string) (elb.LoadBalancerDescription, bool) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	lb, ok := srv.lbs[name]
+	if !ok {
+		return elb.LoadBalancerDescription{}, false
+	}
+	return copyLoadBalancerDescription(&lb.desc), true
+}
+
+// Listeners returns a copy of the listeners of the named load balancer, and
+// false if the server has no such load balancer.
+func (srv *Server) Listeners(lbName string) ([]elb.ListenerDescription, bool) {
+	lb, ok := srv.LoadBalancer(lbName)
+	return lb.ListenerDescriptions, ok
+}
+
+// Instances returns the ids of the fake instances of the server, in the order
+// they were created.
+func (srv *Server) Instances() []string {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	return append([]string(nil), srv.instances...)
+}
+
+// Restore brings the server back to the given snapshot, so tests can branch
+// from a known state without creating it again: the load balancers,
+// instances, subnets, security groups, certificates, policies and tags are
+// replaced by the ones of the snapshot. The settings of the server, like
+// strict mode and the injected errors, and the recorded operations are left
+// as they are. The snapshot isn't changed, so it can be restored again.
+func (srv *Server) Restore(state *State) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	srv.lbs = copyLoadBalancers(state.lbs)
+	srv.instances = append([]string(nil), state.Instances...)
+	srv.subnets = copySet(state.subnets)
+	srv.securityGroups = copySet(state.securityGroups)
+	srv.certificates = copySet(state.certificates)
+	srv.instCount = state.instCount
+}
+
+// savedState is the JSON document written by Save and read by Load.
+type savedState struct {
+	LoadBalancers  map[string]savedLoadBalancer
+	Instances      []string
+	InstanceCount  int
+	Subnets        []string
+	SecurityGroups []string
+	Certificates   []string
+}
+
+// savedLoadBalancer is the state of a load balancer in a savedState.
+type savedLoadBalancer struct {
+	Description    elb.LoadBalancerDescription
+	InstanceStates []elb.InstanceState
+	Attributes     elb.LoadBalancerAttributes
+	Tags           []elb.Tag
+	Policies       []elb.PolicyDescription
+	Draining       map[string]time.Time
+}
+
+// Save writes the state of the server as a JSON document, the same state
+// kept by Snapshot, so it can be loaded with Load by another server, like
+// one started again after a restart.
+func (srv *Server) Save(w io.Writer) error {
+	state := srv.Snapshot()
+	saved := savedState{
+		LoadBalancers:  make(map[string]savedLoadBalancer, len(state.lbs)),
+		Instances:      state.Instances,
+		InstanceCount:  state.instCount,
+		Subnets:        setKeys(state.subnets),
+		SecurityGroups: setKeys(state.securityGroups),
+		Certificates:   setKeys(state.certificates),
+	}
+	for name, lb := range state.lbs {
+		s := savedLoadBalancer{
+			Description:    lb.desc,
+			InstanceStates: state.InstanceStates[name],
+			Attributes:     *lb.attributes,
+			Tags:           lb.tags,
+			Draining:       lb.draining,
+		}
+		for _, p := range lb.policies {
+			s.Policies = append(s.Policies, p.description())
+		}
+		saved.LoadBalancers[name] = s
+	}
+	return json.NewEncoder(w).Encode(saved)
+}
+
+// Load replaces the state of the server with the one written by Save, like
+// Restore does with a snapshot.
+func (srv *Server) Load(r io.Reader) error {
+	var saved savedState
+	if err := json.NewDecoder(r).Decode(&saved); err != nil {
+		return fmt.Errorf("cannot load the state of the server: %v", err)
+	}
+	state := &State{
+		Instances:      saved.Instances,
+		lbs:            make(map[string]*loadBalancer, len(saved.LoadBalancers)),
+		subnets:        makeSet(saved.Subnets),
+		securityGroups: makeSet(saved.SecurityGroups),
+		certificates:   makeSet(saved.Certificates),
+		instCount:      saved.InstanceCount,
+	}
+	for name, s := range saved.LoadBalancers {
+		lb := newLoadBalancer(s.Description)
+		lb.attributes = &s.Attributes
+		for i := range s.InstanceStates {
+			lb.instanceStates = append(lb.instanceStates, &s.InstanceStates[i])
+		}
+		lb.tags = s.Tags
+		for _, desc := range s.Policies {
+			lb.policies = append(lb.policies, makePolicy(desc))
+		}
+		for id, end := range s.Draining {
+			lb.draining[id] = end
+		}
+		state.lbs[name] = lb
+	}
+	srv.Restore(state)
+	return nil
+}
+
+func setKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func makeSet(keys []string) map[string]bool {
+	set := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		set[k] = true
+	}
+	return set
+}
+
+// copy returns a deep copy of the state of a load balancer.
+func (lb *loadBalancer) copy() *loadBalancer {
+	attrs := copyAttributes(lb.attributes)
+	c := &loadBalancer{
+		desc:           copyLoadBalancerDescription(&lb.desc),
+		instanceStates: make([]*elb.InstanceState, len(lb.instanceStates)),
+		attributes:     &attrs,
+		policies:       make([]*policy, len(lb.policies)),
+		draining:       make(map[string]time.Time, len(lb.draining)),
+		tags:           append([]elb.Tag(nil), lb.tags...),
+	}
+	for i, s := range lb.instanceStates {
+		cs := *s
+		c.instanceStates[i] = &cs
+	}
+	for i, p := range lb.policies {
+		cp := *p
+		cp.attributes = append([]policyAttribute(nil), p.attributes...)
+		c.policies[i] = &cp
+	}
+	for id, end := range lb.draining {
+		c.draining[id] = end
+	}
+	return c
+}
+
+func copyLoadBalancers(lbs map[string]*loadBalancer) map[string]*loadBalancer {
+	c := make(map[string]*loadBalancer, len(lbs))
+	for name, lb := range lbs {
+		c[name] = lb.copy()
+	}
+	return c
+}
+
+func copySet(set map[string]bool) map[string]bool {
+	c := make(map[string]bool, len(set))
+	for k, v := range set {
+		c[k] = v
+	}
+	return c
+}
+
+func copyLoadBalancerDescription(lb *elb.LoadBalancerDescription) elb.LoadBalancerDescription {
+	c := *lb
+	c.AvailZones = copyStrings(lb.AvailZones)
+	c.SecurityGroups = copyStrings(lb.SecurityGroups)
+	c.Subnets = copyStrings(lb.Subnets)
+	if lb.Instances != nil {
+		c.Instances = append([]elb.Instance{}, lb.Instances...)
+	}
+	if lb.BackendServerDescriptions != nil {
+		c.BackendServerDescriptions = make([]elb.BackendServerDescription, len(lb.BackendServerDescriptions))
+		for i, d := range lb.BackendServerDescriptions {
+			d.PolicyNames = copyStrings(d.PolicyNames)
+			c.BackendServerDescriptions[i] = d
+		}
+	}
+	if lb.ListenerDescriptions != nil {
+		c.ListenerDescriptions = make([]elb.ListenerDescription, len(lb.ListenerDescriptions))
+		for i, d := range lb.ListenerDescriptions {
+			d.PolicyNames = copyStrings(d.PolicyNames)
+			c.ListenerDescriptions[i] = d
+		}
+	}
+	if lb.Policies.AppCookieStickinessPolicies != nil {
+		c.Policies.AppCookieStickinessPolicies = append([]elb.AppCookieStickinessPolicies{}, lb.Policies.AppCookieStickinessPolicies...)
+	}
+	if lb.Policies.LBCookieStickinessPolicies != nil {
+		c.Policies.LBCookieStickinessPolicies = append([]elb.LBCookieStickinessPolicies{}, lb.Policies.LBCookieStickinessPolicies...)
+	}
+	c.Policies.OtherPolicies = copyStrings(lb.Policies.OtherPolicies)
+	return c
+}
+
+func copyStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string{}, s...)
+}
+
 var actions = map[string]func(*Server, http.ResponseWriter, *http.Request, string) (interface{}, error){
-	"CreateLoadBalancer":                  (*Server).createLoadBalancer,
-	"DeleteLoadBalancer":                  (*Server).deleteLoadBalancer,
-	"RegisterInstancesWithLoadBalancer":   (*Server).registerInstancesWithLoadBalancer,
-	"DeregisterInstancesFromLoadBalancer": (*Server).deregisterInstancesFromLoadBalancer,
-	"DescribeLoadBalancers":               (*Server).describeLoadBalancers,
-	"DescribeInstanceHealth":              (*Server).describeInstanceHealth,
-	"ConfigureHealthCheck":                (*Server).configureHealthCheck,
+	"CreateLoadBalancer":                      (*Server).createLoadBalancer,
+	"DeleteLoadBalancer":                      (*Server).deleteLoadBalancer,
+	"RegisterInstancesWithLoadBalancer":       (*Server).registerInstancesWithLoadBalancer,
+	"DeregisterInstancesFromLoadBalancer":     (*Server).deregisterInstancesFromLoadBalancer,
+	"DescribeLoadBalancers":                   (*Server).describeLoadBalancers,
+	"DescribeInstanceHealth":                  (*Server).describeInstanceHealth,
+	"ConfigureHealthCheck":                    (*Server).configureHealthCheck,
+	"CreateLoadBalancerListeners":             (*Server).createLoadBalancerListeners,
+	"DeleteLoadBalancerListeners":             (*Server).deleteLoadBalancerListeners,
+	"ModifyLoadBalancerAttributes":            (*Server).modifyLoadBalancerAttributes,
+	"DescribeLoadBalancerAttributes":          (*Server).describeLoadBalancerAttributes,
+	"AttachLoadBalancerToSubnets":             (*Server).attachLoadBalancerToSubnets,
+	"ApplySecurityGroupsToLoadBalancer":       (*Server).applySecurityGroupsToLoadBalancer,
+	"SetLoadBalancerPoliciesForBackendServer": (*Server).setLoadBalancerPoliciesForBackendServer,
+	"EnableAvailabilityZonesForLoadBalancer":  (*Server).enableAvailabilityZonesForLoadBalancer,
+	"DisableAvailabilityZonesForLoadBalancer": (*Server).disableAvailabilityZonesForLoadBalancer,
+	"SetLoadBalancerListenerSSLCertificate":   (*Server).setLoadBalancerListenerSSLCertificate,
+	"CreateLBCookieStickinessPolicy":          (*Server).createLBCookieStickinessPolicy,
+	"CreateAppCookieStickinessPolicy":         (*Server).createAppCookieStickinessPolicy,
+	"CreateLoadBalancerPolicy":                (*Server).createLoadBalancerPolicy,
+	"DeleteLoadBalancerPolicy":                (*Server).deleteLoadBalancerPolicy,
+	"DescribeLoadBalancerPolicies":            (*Server).describeLoadBalancerPolicies,
+	"DescribeLoadBalancerPolicyTypes":         (*Server).describeLoadBalancerPolicyTypes,
+	"SetLoadBalancerPoliciesOfListener":       (*Server).setLoadBalancerPoliciesOfListener,
+	"DetachLoadBalancerFromSubnets":           (*Server).detachLoadBalancerFromSubnets,
+	"AddTags":                                 (*Server).addTags,
+	"RemoveTags":                              (*Server).removeTags,
+	"DescribeTags":                            (*Server).describeTags,
+	"DescribeAccountLimits":                   (*Server).describeAccountLimits,
 }
@@ -0,0 +1,852 @@
+package elb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxConcurrentRequests is the maximum number of requests sent at the same
+// time by the helpers that operate on several Load Balancers, in order to
+// avoid being throttled by AWS.
+const maxConcurrentRequests = 10
+
+// describeAfterCreateTimeout is how long CreateAndDescribe waits for a newly
+// created Load Balancer to show up in DescribeLoadBalancers.
+const describeAfterCreateTimeout = 10 * time.Second
+
+// Bounds of the interval between requests of the helpers that poll ELB.
+const (
+	minPollInterval = 50 * time.Millisecond
+	maxPollInterval = 5 * time.Second
+)
+
+// poll calls f until it reports that it is done or fails, doubling the
+// interval between calls up to maxPollInterval. It returns false if f is not
+// done before the timeout expires.
+func poll(timeout time.Duration, f func() (bool, error)) (bool, error) {
+	deadline := time.Now().Add(timeout)
+	interval := minPollInterval
+	for {
+		done, err := f()
+		if done || err != nil {
+			return done, err
+		}
+		remaining := deadline.Sub(time.Now())
+		if remaining <= 0 {
+			return false, nil
+		}
+		if interval > remaining {
+			interval = remaining
+		}
+		time.Sleep(interval)
+		if interval *= 2; interval > maxPollInterval {
+			interval = maxPollInterval
+		}
+	}
+}
+
+// BatchError holds the errors of an operation carried out on several Load
+// Balancers, keyed by the name of the Load Balancer.
+type BatchError map[string]error
+
+func (e BatchError) Error() string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	msgs := make([]string, len(names))
+	for i, name := range names {
+		msgs[i] = fmt.Sprintf("%s: %s", name, e[name])
+	}
+	if len(msgs) == 1 {
+		return msgs[0]
+	}
+	return fmt.Sprintf("%d errors: %s", len(msgs), strings.Join(msgs, "; "))
+}
+
+// describeLoadBalancer returns the description of a single Load Balancer.
+func (elb *ELB) describeLoadBalancer(name string) (*LoadBalancerDescription, error) {
+	resp, err := elb.DescribeLoadBalancers(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.LoadBalancerDescriptions) == 0 {
+		return nil, &Error{
+			Code:    "LoadBalancerNotFound",
+			Message: fmt.Sprintf("Cannot find Load Balancer %s", name),
+		}
+	}
+	return &resp.LoadBalancerDescriptions[0], nil
+}
+
+func isLoadBalancerNotFound(err error) bool {
+	e, ok := err.(*Error)
+	return ok && e.Code == "LoadBalancerNotFound"
+}
+
+// SetInstances makes the given list of instances the only ones registered with
+// the given Load Balancer, registering the missing instances and deregistering
+// the ones that are not in the list.
+//
+// Instances are registered before any instance is deregistered, so the Load
+// Balancer is never left without the instances that are meant to stay in it.
+// It returns the instances registered with the Load Balancer at the end.
+func (elb *ELB) SetInstances(lbName string, desired []string) ([]string, error) {
+	lb, err := elb.describeLoadBalancer(lbName)
+	if err != nil {
+		return nil, err
+	}
+	instances := lb.Instances
+	current := make(map[string]bool, len(instances))
+	for _, instance := range instances {
+		current[instance.InstanceId] = true
+	}
+	wanted := make(map[string]bool, len(desired))
+	final := make([]string, 0, len(desired))
+	var register, deregister []string
+	for _, id := range desired {
+		if wanted[id] {
+			continue
+		}
+		wanted[id] = true
+		final = append(final, id)
+		if !current[id] {
+			register = append(register, id)
+		}
+	}
+	for _, instance := range instances {
+		if !wanted[instance.InstanceId] {
+			deregister = append(deregister, instance.InstanceId)
+		}
+	}
+	if len(register) > 0 {
+		if _, err := elb.RegisterInstancesWithLoadBalancer(register, lbName); err != nil {
+			return nil, err
+		}
+	}
+	if len(deregister) > 0 {
+		if _, err := elb.DeregisterInstancesFromLoadBalancer(deregister, lbName); err != nil {
+			return nil, err
+		}
+	}
+	return final, nil
+}
+
+// forEach calls f with each of the given names, running at most
+// maxConcurrentRequests calls at the same time. It returns the errors of the
+// calls that failed, keyed by name, or nil if all of them succeeded.
+func forEach(names []string, f func(name string) error) error {
+	return forEachN(names, maxConcurrentRequests, f)
+}
+
+// forEachN works like forEach, running at most n calls at the same time.
+func forEachN(names []string, n int, f func(name string) error) error {
+	return forEachNContext(context.Background(), names, n, f)
+}
+
+// forEachNContext works like forEachN, but stops calling f once ctx is done.
+// The names f was not called for fail with the error of ctx.
+func forEachNContext(ctx context.Context, names []string, n int, f func(name string) error) error {
+	var mutex sync.Mutex
+	errs := make(BatchError)
+	ch := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < n && i < len(names); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range ch {
+				if err := f(name); err != nil {
+					mutex.Lock()
+					errs[name] = err
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+	for i, name := range names {
+		select {
+		case ch <- name:
+			continue
+		case <-ctx.Done():
+		}
+		mutex.Lock()
+		for _, name := range names[i:] {
+			errs[name] = ctx.Err()
+		}
+		mutex.Unlock()
+		break
+	}
+	close(ch)
+	wg.Wait()
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// InstanceHealthForAll describes the health of the instances registered with
+// each of the given Load Balancers, sending at most maxConcurrentRequests
+// DescribeInstanceHealth requests at the same time.
+//
+// The states of the instances are keyed by the name of the Load Balancer. If
+// any request fails, the states of the other Load Balancers are still
+// returned, along with a BatchError holding the failures.
+func (elb *ELB) InstanceHealthForAll(lbNames []string) (map[string][]InstanceState, error) {
+	return elb.InstanceHealthForAllContext(context.Background(), lbNames)
+}
+
+// InstanceHealthForAllContext works like InstanceHealthForAll, but gives up
+// once ctx is done: the requests in flight are aborted and no other request
+// is sent. The Load Balancers whose health wasn't described fail with the
+// error of ctx in the returned BatchError.
+func (elb *ELB) InstanceHealthForAllContext(ctx context.Context, lbNames []string) (map[string][]InstanceState, error) {
+	var mutex sync.Mutex
+	states := make(map[string][]InstanceState, len(lbNames))
+	err := forEachNContext(ctx, lbNames, maxConcurrentRequests, func(name string) error {
+		resp, err := elb.describeInstanceHealth(ctx, name)
+		if err != nil {
+			return err
+		}
+		mutex.Lock()
+		states[name] = resp.InstanceStates
+		mutex.Unlock()
+		return nil
+	})
+	return states, err
+}
+
+// DeleteLoadBalancers deletes the given Load Balancers, sending at most
+// maxConcurrentRequests DeleteLoadBalancer requests at the same time.
+//
+// A failure to delete a Load Balancer does not stop the others from being
+// deleted, the failures are returned in a BatchError. Load Balancers that do
+// not exist are not failures, like in DeleteLoadBalancer.
+func (elb *ELB) DeleteLoadBalancers(names []string) error {
+	return forEach(names, func(name string) error {
+		_, err := elb.DeleteLoadBalancer(name)
+		if isLoadBalancerNotFound(err) {
+			return nil
+		}
+		return err
+	})
+}
+
+// RecreateWithName creates a new Load Balancer named newName with the same
+// configuration of the Load Balancer named oldName, and registers with it the
+// instances registered with the old one. When deleteOld is true, the old Load
+// Balancer is deleted at the end.
+//
+// The configuration includes the listeners, the health check, the attributes,
+// the tags and the policies of the old Load Balancer, along with the policies
+// of its listeners and backend servers.
+//
+// Load Balancers can't be renamed, this is the closest ELB gets to it. The
+// migration can be resumed by calling RecreateWithName again after a failure:
+// the new Load Balancer is reused if it exists, and if the old one is already
+// gone the new one is returned as is.
+//
+// It returns the description of the new Load Balancer.
+func (elb *ELB) RecreateWithName(oldName, newName string, deleteOld bool) (*LoadBalancerDescription, error) {
+	old, err := elb.describeLoadBalancer(oldName)
+	if isLoadBalancerNotFound(err) {
+		if lb, newErr := elb.describeLoadBalancer(newName); newErr == nil {
+			return lb, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := elb.describeLoadBalancer(newName); isLoadBalancerNotFound(err) {
+		options := CreateLoadBalancer{
+			Name:           newName,
+			Scheme:         old.Scheme,
+			SecurityGroups: old.SecurityGroups,
+			Subnets:        old.Subnets,
+		}
+		if len(old.Subnets) == 0 {
+			options.AvailZones = old.AvailZones
+		}
+		for _, ld := range old.ListenerDescriptions {
+			options.Listeners = append(options.Listeners, ld.Listener)
+		}
+		if _, err := elb.CreateLoadBalancer(&options); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	if old.HealthCheck.Target != "" {
+		if _, err := elb.ConfigureHealthCheck(newName, &old.HealthCheck); err != nil {
+			return nil, err
+		}
+	}
+	attrs, err := elb.DescribeLoadBalancerAttributes(oldName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := elb.ModifyLoadBalancerAttributes(newName, &attrs.LoadBalancerAttributes); err != nil {
+		return nil, err
+	}
+	tags, err := elb.DescribeTags(oldName)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags.TagDescriptions) > 0 && len(tags.TagDescriptions[0].Tags) > 0 {
+		if _, err := elb.AddTags([]string{newName}, tags.TagDescriptions[0].Tags); err != nil {
+			return nil, err
+		}
+	}
+	if err := elb.copyPolicies(old, newName); err != nil {
+		return nil, err
+	}
+	if len(old.Instances) > 0 {
+		instIds := make([]string, len(old.Instances))
+		for i, instance := range old.Instances {
+			instIds[i] = instance.InstanceId
+		}
+		if _, err := elb.RegisterInstancesWithLoadBalancer(instIds, newName); err != nil {
+			return nil, err
+		}
+	}
+	if deleteOld {
+		if _, err := elb.DeleteLoadBalancer(oldName); err != nil {
+			return nil, err
+		}
+	}
+	return elb.describeLoadBalancer(newName)
+}
+
+// copyPolicies creates in the Load Balancer named newName the policies of the
+// old Load Balancer it doesn't have yet, and sets them to the same listeners
+// and backend servers.
+func (elb *ELB) copyPolicies(old *LoadBalancerDescription, newName string) error {
+	policies, err := elb.DescribeLoadBalancerPolicies(old.LoadBalancerName)
+	if err != nil {
+		return err
+	}
+	current, err := elb.DescribeLoadBalancerPolicies(newName)
+	if err != nil {
+		return err
+	}
+	exists := make(map[string]bool, len(current.PolicyDescriptions))
+	for _, p := range current.PolicyDescriptions {
+		exists[p.PolicyName] = true
+	}
+	for _, p := range policies.PolicyDescriptions {
+		if exists[p.PolicyName] {
+			continue
+		}
+		if _, err := elb.CreateLoadBalancerPolicy(newName, p.PolicyName, p.PolicyTypeName, p.PolicyAttributeDescriptions); err != nil {
+			return err
+		}
+	}
+	for _, ld := range old.ListenerDescriptions {
+		if len(ld.PolicyNames) == 0 {
+			continue
+		}
+		if _, err := elb.SetLoadBalancerPoliciesOfListener(newName, ld.Listener.LoadBalancerPort, ld.PolicyNames); err != nil {
+			return err
+		}
+	}
+	for _, bd := range old.BackendServerDescriptions {
+		if _, err := elb.SetLoadBalancerPoliciesForBackendServer(newName, bd.InstancePort, bd.PolicyNames); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WaitUntilLoadBalancerExists waits until the given Load Balancer shows up in
+// DescribeLoadBalancers, which may take a while after it is created. It polls
+// ELB with an increasing interval between requests, and fails if the Load
+// Balancer does not show up before the timeout expires.
+func (elb *ELB) WaitUntilLoadBalancerExists(lbName string, timeout time.Duration) error {
+	_, err := elb.waitForLoadBalancer(lbName, timeout)
+	return err
+}
+
+// waitForLoadBalancer polls ELB until the given Load Balancer shows up in
+// DescribeLoadBalancers, and returns its description.
+func (elb *ELB) waitForLoadBalancer(lbName string, timeout time.Duration) (*LoadBalancerDescription, error) {
+	var lb *LoadBalancerDescription
+	done, err := poll(timeout, func() (bool, error) {
+		var err error
+		lb, err = elb.describeLoadBalancer(lbName)
+		if isLoadBalancerNotFound(err) {
+			return false, nil
+		}
+		return err == nil, err
+	})
+	if err == nil && !done {
+		err = fmt.Errorf("timed out waiting for Load Balancer %s to exist", lbName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return lb, nil
+}
+
+// UpdateListenerInstanceProtocol changes the instance protocol and port of the
+// listener on the given Load Balancer port.
+//
+// ELB does not allow changing a listener in place, so the listener is deleted
+// and created again, keeping its protocol and SSL certificate. If the new
+// listener can't be created, the old one is restored, so the port is not left
+// without a listener. Policies of the listener are not preserved.
+func (elb *ELB) UpdateListenerInstanceProtocol(lbName string, lbPort int, instanceProtocol string, instancePort int) error {
+	lb, err := elb.describeLoadBalancer(lbName)
+	if err != nil {
+		return err
+	}
+	var old *Listener
+	for i := range lb.ListenerDescriptions {
+		if lb.ListenerDescriptions[i].Listener.LoadBalancerPort == lbPort {
+			old = &lb.ListenerDescriptions[i].Listener
+			break
+		}
+	}
+	if old == nil {
+		return &Error{
+			Code:    "ListenerNotFound",
+			Message: fmt.Sprintf("Load Balancer %s has no listener on port %d", lbName, lbPort),
+		}
+	}
+	listener := *old
+	listener.InstanceProtocol = instanceProtocol
+	listener.InstancePort = instancePort
+	if err := ValidateListener(&listener); err != nil {
+		return err
+	}
+	if _, err := elb.DeleteLoadBalancerListeners(lbName, lbPort); err != nil {
+		return err
+	}
+	if _, err := elb.CreateLoadBalancerListeners(lbName, []Listener{listener}); err != nil {
+		if _, restoreErr := elb.CreateLoadBalancerListeners(lbName, []Listener{*old}); restoreErr != nil {
+			return fmt.Errorf("%s; restoring the old listener on port %d also failed: %s", err, lbPort, restoreErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// DNSName returns the DNS name of the given Load Balancer.
+func (elb *ELB) DNSName(lbName string) (string, error) {
+	lb, err := elb.describeLoadBalancer(lbName)
+	if err != nil {
+		return "", err
+	}
+	return lb.DNSName, nil
+}
+
+// RegisterResult tells apart the instances that a registration added to a
+// Load Balancer from the ones that were already registered with it.
+type RegisterResult struct {
+	Added             []string
+	AlreadyRegistered []string
+	// InstanceIds holds all the instances registered with the Load
+	// Balancer after the registration.
+	InstanceIds []string
+}
+
+// RegisterInstancesWithResult registers the given instances with the Load
+// Balancer and reports which of them were newly added. The Load Balancer is
+// described before the registration to find its current instances, so the
+// result is only accurate if nothing else changes the instances of the Load
+// Balancer in the meantime. Instances given more than once are only reported
+// once.
+func (elb *ELB) RegisterInstancesWithResult(lbName string, instanceIds ...string) (*RegisterResult, error) {
+	lb, err := elb.describeLoadBalancer(lbName)
+	if err != nil {
+		return nil, err
+	}
+	registered := make(map[string]bool, len(lb.Instances))
+	for _, instance := range lb.Instances {
+		registered[instance.InstanceId] = true
+	}
+	resp, err := elb.RegisterInstancesWithLoadBalancer(instanceIds, lbName)
+	if err != nil {
+		return nil, err
+	}
+	result := RegisterResult{
+		Added:             []string{},
+		AlreadyRegistered: []string{},
+		InstanceIds:       resp.InstanceIds,
+	}
+	seen := make(map[string]bool, len(instanceIds))
+	for _, id := range instanceIds {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if registered[id] {
+			result.AlreadyRegistered = append(result.AlreadyRegistered, id)
+		} else {
+			result.Added = append(result.Added, id)
+		}
+	}
+	return &result, nil
+}
+
+// CreateAndDescribe creates a Load Balancer and returns its full description,
+// including the fields that CreateLoadBalancer does not return, like the
+// canonical hosted zone.
+//
+// A newly created Load Balancer may take a while to show up in
+// DescribeLoadBalancers, so the describe is retried for a few seconds.
+func (elb *ELB) CreateAndDescribe(options *CreateLoadBalancer) (*LoadBalancerDescription, error) {
+	if _, err := elb.CreateLoadBalancer(options); err != nil {
+		return nil, err
+	}
+	return elb.waitForLoadBalancer(options.Name, describeAfterCreateTimeout)
+}
+
+// Drift holds the differences between the desired configuration of a Load
+// Balancer and its current configuration, see DetectDrift.
+type Drift struct {
+	// Listeners that are desired but don't exist.
+	MissingListeners []Listener
+	// Listeners that exist but are not desired.
+	ExtraListeners []Listener
+	// Listeners on a desired Load Balancer port that differ from the
+	// desired ones.
+	ChangedListeners []ListenerDrift
+	// Instances that are desired but not registered.
+	MissingInstances []string
+	// Instances that are registered but not desired.
+	ExtraInstances []string
+	// The health check, if it differs from the desired one.
+	HealthCheck *HealthCheckDrift
+	// The attributes that differ from the desired ones.
+	Attributes *AttributesDrift
+	// Tags that are desired but don't exist.
+	MissingTags []Tag
+	// Tags that exist but are not desired.
+	ExtraTags []Tag
+	// Tags with a desired key whose value differs from the desired one.
+	ChangedTags []TagDrift
+}
+
+// ListenerDrift holds a listener that differs from the desired one.
+type ListenerDrift struct {
+	Desired Listener
+	Current Listener
+}
+
+// HealthCheckDrift holds a health check that differs from the desired one.
+type HealthCheckDrift struct {
+	Desired HealthCheck
+	Current HealthCheck
+}
+
+// AttributesDrift holds the attributes that differ from the desired ones.
+// Only the attributes that differ are set, the others are nil.
+type AttributesDrift struct {
+	Desired LoadBalancerAttributes
+	Current LoadBalancerAttributes
+}
+
+// TagDrift holds a tag whose value differs from the desired one.
+type TagDrift struct {
+	Desired Tag
+	Current Tag
+}
+
+// Empty returns true if there is no difference.
+func (d Drift) Empty() bool {
+	return len(d.MissingListeners) == 0 && len(d.ExtraListeners) == 0 &&
+		len(d.ChangedListeners) == 0 && len(d.MissingInstances) == 0 &&
+		len(d.ExtraInstances) == 0 && d.HealthCheck == nil &&
+		d.Attributes == nil && len(d.MissingTags) == 0 &&
+		len(d.ExtraTags) == 0 && len(d.ChangedTags) == 0
+}
+
+// DriftOptions holds the desired state of a Load Balancer that is not part
+// of a LoadBalancerDescription, see DetectDrift.
+type DriftOptions struct {
+	// Attributes holds the desired attributes. Only the attributes that
+	// are not nil are compared, and none when Attributes is nil.
+	Attributes *LoadBalancerAttributes
+	// Tags holds the desired tags. Tags are only compared when Tags is not
+	// nil, so an empty slice means that the Load Balancer should have no
+	// tags.
+	Tags []Tag
+}
+
+// DetectDrift describes the given Load Balancer and compares its listeners,
+// instances and health check with the desired ones, without changing
+// anything. Attributes and tags are not part of a LoadBalancerDescription,
+// they are compared when given in opts.
+//
+// Listeners are matched by Load Balancer port, and their protocols are
+// compared regardless of case. The health check is only compared when the
+// desired one has a target. Tags are matched by key.
+func (elb *ELB) DetectDrift(lbName string, desired LoadBalancerDescription, opts ...DriftOptions) (Drift, error) {
+	var drift Drift
+	lb, err := elb.describeLoadBalancer(lbName)
+	if err != nil {
+		return drift, err
+	}
+	for _, o := range opts {
+		if o.Attributes != nil {
+			if err := elb.detectAttributesDrift(lbName, o.Attributes, &drift); err != nil {
+				return drift, err
+			}
+		}
+		if o.Tags != nil {
+			if err := elb.detectTagsDrift(lbName, o.Tags, &drift); err != nil {
+				return drift, err
+			}
+		}
+	}
+	current := make(map[int]Listener, len(lb.ListenerDescriptions))
+	for _, ld := range lb.ListenerDescriptions {
+		current[ld.Listener.LoadBalancerPort] = ld.Listener
+	}
+	wanted := make(map[int]bool, len(desired.ListenerDescriptions))
+	for _, ld := range desired.ListenerDescriptions {
+		l := ld.Listener
+		wanted[l.LoadBalancerPort] = true
+		if c, ok := current[l.LoadBalancerPort]; !ok {
+			drift.MissingListeners = append(drift.MissingListeners, l)
+		} else if !sameListener(l, c) {
+			drift.ChangedListeners = append(drift.ChangedListeners, ListenerDrift{Desired: l, Current: c})
+		}
+	}
+	for _, ld := range lb.ListenerDescriptions {
+		if !wanted[ld.Listener.LoadBalancerPort] {
+			drift.ExtraListeners = append(drift.ExtraListeners, ld.Listener)
+		}
+	}
+	registered := make(map[string]bool, len(lb.Instances))
+	for _, instance := range lb.Instances {
+		registered[instance.InstanceId] = true
+	}
+	wantedInstances := make(map[string]bool, len(desired.Instances))
+	for _, instance := range desired.Instances {
+		if wantedInstances[instance.InstanceId] {
+			continue
+		}
+		wantedInstances[instance.InstanceId] = true
+		if !registered[instance.InstanceId] {
+			drift.MissingInstances = append(drift.MissingInstances, instance.InstanceId)
+		}
+	}
+	for _, instance := range lb.Instances {
+		if !wantedInstances[instance.InstanceId] {
+			drift.ExtraInstances = append(drift.ExtraInstances, instance.InstanceId)
+		}
+	}
+	if desired.HealthCheck.Target != "" && desired.HealthCheck != lb.HealthCheck {
+		drift.HealthCheck = &HealthCheckDrift{Desired: desired.HealthCheck, Current: lb.HealthCheck}
+	}
+	return drift, nil
+}
+
+func (elb *ELB) detectAttributesDrift(lbName string, desired *LoadBalancerAttributes, drift *Drift) error {
+	resp, err := elb.DescribeLoadBalancerAttributes(lbName)
+	if err != nil {
+		return err
+	}
+	current := resp.LoadBalancerAttributes
+	var d AttributesDrift
+	changed := false
+	if desired.AccessLog != nil && (current.AccessLog == nil || *desired.AccessLog != *current.AccessLog) {
+		d.Desired.AccessLog, d.Current.AccessLog = desired.AccessLog, current.AccessLog
+		changed = true
+	}
+	if desired.ConnectionDraining != nil && (current.ConnectionDraining == nil || *desired.ConnectionDraining != *current.ConnectionDraining) {
+		d.Desired.ConnectionDraining, d.Current.ConnectionDraining = desired.ConnectionDraining, current.ConnectionDraining
+		changed = true
+	}
+	if desired.ConnectionSettings != nil && (current.ConnectionSettings == nil || *desired.ConnectionSettings != *current.ConnectionSettings) {
+		d.Desired.ConnectionSettings, d.Current.ConnectionSettings = desired.ConnectionSettings, current.ConnectionSettings
+		changed = true
+	}
+	if desired.CrossZoneLoadBalancing != nil && (current.CrossZoneLoadBalancing == nil || *desired.CrossZoneLoadBalancing != *current.CrossZoneLoadBalancing) {
+		d.Desired.CrossZoneLoadBalancing, d.Current.CrossZoneLoadBalancing = desired.CrossZoneLoadBalancing, current.CrossZoneLoadBalancing
+		changed = true
+	}
+	if changed {
+		drift.Attributes = &d
+	}
+	return nil
+}
+
+func (elb *ELB) detectTagsDrift(lbName string, desired []Tag, drift *Drift) error {
+	resp, err := elb.DescribeTags(lbName)
+	if err != nil {
+		return err
+	}
+	current := make(map[string]Tag)
+	var tags []Tag
+	for _, td := range resp.TagDescriptions {
+		if td.LoadBalancerName == lbName {
+			tags = td.Tags
+		}
+	}
+	for _, tag := range tags {
+		current[tag.Key] = tag
+	}
+	wanted := make(map[string]bool, len(desired))
+	for _, tag := range desired {
+		wanted[tag.Key] = true
+		if c, ok := current[tag.Key]; !ok {
+			drift.MissingTags = append(drift.MissingTags, tag)
+		} else if c.Value != tag.Value {
+			drift.ChangedTags = append(drift.ChangedTags, TagDrift{Desired: tag, Current: c})
+		}
+	}
+	for _, tag := range tags {
+		if !wanted[tag.Key] {
+			drift.ExtraTags = append(drift.ExtraTags, tag)
+		}
+	}
+	return nil
+}
+
+func sameListener(a, b Listener) bool {
+	return a.LoadBalancerPort == b.LoadBalancerPort &&
+		a.InstancePort == b.InstancePort &&
+		strings.EqualFold(a.Protocol, b.Protocol) &&
+		strings.EqualFold(a.InstanceProtocol, b.InstanceProtocol) &&
+		a.SSLCertificateId == b.SSLCertificateId
+}
+
+// ReconcileListeners makes the given listeners the only ones of the Load
+// Balancer. Listeners are matched by Load Balancer port, like in DetectDrift:
+// the listeners on ports that are not in the list are deleted, and the ones
+// that differ from the desired ones are replaced, which briefly leaves their
+// ports without a listener. If the new listeners can't be created, the
+// deleted ones are restored, like in UpdateListenerInstanceProtocol.
+func (elb *ELB) ReconcileListeners(lbName string, desired []Listener) error {
+	lb, err := elb.describeLoadBalancer(lbName)
+	if err != nil {
+		return err
+	}
+	current := make(map[int]Listener, len(lb.ListenerDescriptions))
+	for _, ld := range lb.ListenerDescriptions {
+		current[ld.Listener.LoadBalancerPort] = ld.Listener
+	}
+	wanted := make(map[int]bool, len(desired))
+	var stale []int
+	var missing []Listener
+	for _, l := range desired {
+		wanted[l.LoadBalancerPort] = true
+		c, ok := current[l.LoadBalancerPort]
+		if ok && sameListener(l, c) {
+			continue
+		}
+		if ok {
+			stale = append(stale, l.LoadBalancerPort)
+		}
+		missing = append(missing, l)
+	}
+	for _, ld := range lb.ListenerDescriptions {
+		if !wanted[ld.Listener.LoadBalancerPort] {
+			stale = append(stale, ld.Listener.LoadBalancerPort)
+		}
+	}
+	if len(stale) > 0 {
+		if _, err := elb.DeleteLoadBalancerListeners(lbName, stale...); err != nil {
+			return err
+		}
+	}
+	if len(missing) > 0 {
+		if _, err := elb.CreateLoadBalancerListeners(lbName, missing); err != nil {
+			if len(stale) == 0 {
+				return err
+			}
+			deleted := make([]Listener, len(stale))
+			for i, port := range stale {
+				deleted[i] = current[port]
+			}
+			if _, restoreErr := elb.CreateLoadBalancerListeners(lbName, deleted); restoreErr != nil {
+				return fmt.Errorf("%s; restoring the old listeners on ports %v also failed: %s", err, stale, restoreErr)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// ReconcileAll makes the listeners and the instances of each of the given
+// Load Balancers match their descriptions, with ReconcileListeners and
+// SetInstances. The Load Balancers are identified by LoadBalancerName and
+// must already exist; the other fields of the descriptions are ignored.
+//
+// At most concurrency Load Balancers are reconciled at the same time, or
+// maxConcurrentRequests when concurrency is not positive. A failure to
+// reconcile a Load Balancer does not stop the others from being reconciled,
+// the failures are returned in a BatchError.
+func (elb *ELB) ReconcileAll(desired []LoadBalancerDescription, concurrency int) error {
+	if concurrency <= 0 {
+		concurrency = maxConcurrentRequests
+	}
+	byName := make(map[string]*LoadBalancerDescription, len(desired))
+	names := make([]string, 0, len(desired))
+	for i := range desired {
+		name := desired[i].LoadBalancerName
+		if _, ok := byName[name]; ok {
+			return fmt.Errorf("Load Balancer %s is given more than once", name)
+		}
+		byName[name] = &desired[i]
+		names = append(names, name)
+	}
+	return forEachN(names, concurrency, func(name string) error {
+		lb := byName[name]
+		listeners := make([]Listener, len(lb.ListenerDescriptions))
+		for i, ld := range lb.ListenerDescriptions {
+			listeners[i] = ld.Listener
+		}
+		if err := elb.ReconcileListeners(name, listeners); err != nil {
+			return err
+		}
+		instanceIds := make([]string, len(lb.Instances))
+		for i, instance := range lb.Instances {
+			instanceIds[i] = instance.InstanceId
+		}
+		_, err := elb.SetInstances(name, instanceIds)
+		return err
+	})
+}
+
+// DeregisterAndWait deregisters the given instances from the Load Balancer,
+// and waits until ELB stops routing requests to them, that is, until they are
+// reported as OutOfService or not reported at all by DescribeInstanceHealth.
+// When connection draining is enabled, this gives the requests in flight the
+// chance to complete before the instances are terminated.
+//
+// It fails if the instances are still in service when the timeout expires.
+func (elb *ELB) DeregisterAndWait(lbName string, instanceIds []string, timeout time.Duration) error {
+	if _, err := elb.DeregisterInstancesFromLoadBalancer(instanceIds, lbName); err != nil {
+		return err
+	}
+	var inService []string
+	done, err := poll(timeout, func() (bool, error) {
+		resp, err := elb.DescribeInstanceHealth(lbName)
+		if err != nil {
+			return false, err
+		}
+		states := make(map[string]string, len(resp.InstanceStates))
+		for _, state := range resp.InstanceStates {
+			states[state.InstanceId] = state.State
+		}
+		inService = nil
+		for _, id := range instanceIds {
+			if state, ok := states[id]; ok && state != "OutOfService" {
+				inService = append(inService, id)
+			}
+		}
+		return len(inService) == 0, nil
+	})
+	if err == nil && !done {
+		err = fmt.Errorf("timed out waiting for instances %s to leave Load Balancer %s", strings.Join(inService, ", "), lbName)
+	}
+	return err
+}
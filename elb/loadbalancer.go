@@ -0,0 +1,67 @@
+package elb
+
+// LoadBalancer is a handle to a single Load Balancer, offering the operations
+// of ELB scoped to its name. It holds no state besides the name, every method
+// sends a request to ELB through the flat API.
+type LoadBalancer struct {
+	Name string
+	elb  *ELB
+}
+
+// LoadBalancer returns a handle to the Load Balancer with the given name. The
+// Load Balancer is not required to exist, it may be created through the
+// handle.
+func (elb *ELB) LoadBalancer(name string) *LoadBalancer {
+	return &LoadBalancer{Name: name, elb: elb}
+}
+
+// Create creates the Load Balancer with the given options, ignoring the
+// name in the options in favor of the name of the handle.
+func (lb *LoadBalancer) Create(options CreateLoadBalancer) (*CreateLoadBalancerResp, error) {
+	options.Name = lb.Name
+	return lb.elb.CreateLoadBalancer(&options)
+}
+
+// Describe returns the description of the Load Balancer.
+func (lb *LoadBalancer) Describe() (*LoadBalancerDescription, error) {
+	return lb.elb.describeLoadBalancer(lb.Name)
+}
+
+// Delete deletes the Load Balancer.
+func (lb *LoadBalancer) Delete() (*SimpleResp, error) {
+	return lb.elb.DeleteLoadBalancer(lb.Name)
+}
+
+// RegisterInstances registers the given instances with the Load Balancer.
+func (lb *LoadBalancer) RegisterInstances(instanceIds ...string) (*RegisterInstancesResp, error) {
+	return lb.elb.RegisterInstancesWithLoadBalancer(instanceIds, lb.Name)
+}
+
+// DeregisterInstances deregisters the given instances from the Load
+// Balancer.
+func (lb *LoadBalancer) DeregisterInstances(instanceIds ...string) (*SimpleResp, error) {
+	return lb.elb.DeregisterInstancesFromLoadBalancer(instanceIds, lb.Name)
+}
+
+// InstanceHealth describes the health of the given instances, or of all the
+// instances registered with the Load Balancer if none is given.
+func (lb *LoadBalancer) InstanceHealth(instanceIds ...string) (*DescribeInstanceHealthResp, error) {
+	return lb.elb.DescribeInstanceHealth(lb.Name, instanceIds...)
+}
+
+// ConfigureHealthCheck configures the health check of the Load Balancer.
+func (lb *LoadBalancer) ConfigureHealthCheck(healthCheck *HealthCheck) (*HealthCheckResp, error) {
+	return lb.elb.ConfigureHealthCheck(lb.Name, healthCheck)
+}
+
+// SetAttributes modifies the attributes of the Load Balancer that are set in
+// attrs.
+func (lb *LoadBalancer) SetAttributes(attrs *LoadBalancerAttributes) (*ModifyLoadBalancerAttributesResp, error) {
+	return lb.elb.ModifyLoadBalancerAttributes(lb.Name, attrs)
+}
+
+// SetInstances makes the given instances the only ones registered with the
+// Load Balancer, see ELB.SetInstances.
+func (lb *LoadBalancer) SetInstances(instanceIds ...string) ([]string, error) {
+	return lb.elb.SetInstances(lb.Name, instanceIds)
+}
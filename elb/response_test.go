@@ -136,6 +136,17 @@ var DescribeLoadBalancers = `
 </DescribeLoadBalancersResponse>
 `
 
+var DescribeLoadBalancersEmpty = `
+<DescribeLoadBalancersResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/">
+    <DescribeLoadBalancersResult>
+        <LoadBalancerDescriptions/>
+    </DescribeLoadBalancersResult>
+    <ResponseMetadata>
+    <RequestId>e2e81963-5055-11e2-99c7-434205631d9b</RequestId>
+    </ResponseMetadata>
+</DescribeLoadBalancersResponse>
+`
+
 var DescribeLoadBalancersBadRequest = `
 <ErrorResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/">
     <Error>
@@ -203,3 +214,455 @@ var ConfigureHealthCheckBadRequest = `
     <RequestId>2d9fe4a5-5697-11e2-9415-e325c02171d7</RequestId>
 </ErrorResponse>
 `
+
+var CreateLoadBalancerListeners = `
+<CreateLoadBalancerListenersResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/">
+    <CreateLoadBalancerListenersResult/>
+    <ResponseMetadata>
+        <RequestId>1549581b-12b7-11e3-895e-1334aEXAMPLE</RequestId>
+    </ResponseMetadata>
+</CreateLoadBalancerListenersResponse>
+`
+
+var DeleteLoadBalancerListeners = `
+<DeleteLoadBalancerListenersResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/">
+    <DeleteLoadBalancerListenersResult/>
+    <ResponseMetadata>
+        <RequestId>83c88b9d-12b7-11e3-8b82-87b12EXAMPLE</RequestId>
+    </ResponseMetadata>
+</DeleteLoadBalancerListenersResponse>
+`
+
+var Throttling = `
+<ErrorResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/">
+    <Error>
+        <Type>Sender</Type>
+        <Code>Throttling</Code>
+        <Message>Rate exceeded</Message>
+    </Error>
+    <RequestId>4bd7a2f0-12b8-11e3-8c3d-a1b2cEXAMPLE</RequestId>
+</ErrorResponse>
+`
+
+var ModifyLoadBalancerAttributes = `
+<ModifyLoadBalancerAttributesResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/">
+    <ModifyLoadBalancerAttributesResult>
+        <LoadBalancerName>testlb</LoadBalancerName>
+        <LoadBalancerAttributes>
+            <AccessLog>
+                <Enabled>true</Enabled>
+                <S3BucketName>my-loadbalancer-logs</S3BucketName>
+                <S3BucketPrefix>my-app/prod</S3BucketPrefix>
+                <EmitInterval>60</EmitInterval>
+            </AccessLog>
+            <ConnectionSettings>
+                <IdleTimeout>120</IdleTimeout>
+            </ConnectionSettings>
+        </LoadBalancerAttributes>
+    </ModifyLoadBalancerAttributesResult>
+    <ResponseMetadata>
+        <RequestId>83c88b9d-12b7-11e3-8b82-87b12EXAMPLE</RequestId>
+    </ResponseMetadata>
+</ModifyLoadBalancerAttributesResponse>
+`
+
+var DescribeLoadBalancerAttributes = `
+<DescribeLoadBalancerAttributesResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/">
+    <DescribeLoadBalancerAttributesResult>
+        <LoadBalancerAttributes>
+            <AccessLog>
+                <Enabled>false</Enabled>
+            </AccessLog>
+            <ConnectionDraining>
+                <Enabled>true</Enabled>
+                <Timeout>300</Timeout>
+            </ConnectionDraining>
+            <ConnectionSettings>
+                <IdleTimeout>60</IdleTimeout>
+            </ConnectionSettings>
+            <CrossZoneLoadBalancing>
+                <Enabled>true</Enabled>
+            </CrossZoneLoadBalancing>
+        </LoadBalancerAttributes>
+    </DescribeLoadBalancerAttributesResult>
+    <ResponseMetadata>
+        <RequestId>83c88b9d-12b7-11e3-8b82-87b12EXAMPLE</RequestId>
+    </ResponseMetadata>
+</DescribeLoadBalancerAttributesResponse>
+`
+
+var EnableAvailabilityZonesForLoadBalancer = `
+<EnableAvailabilityZonesForLoadBalancerResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/">
+    <EnableAvailabilityZonesForLoadBalancerResult>
+        <AvailabilityZones>
+            <member>us-east-1a</member>
+            <member>us-east-1b</member>
+        </AvailabilityZones>
+    </EnableAvailabilityZonesForLoadBalancerResult>
+    <ResponseMetadata>
+        <RequestId>83c88b9d-12b7-11e3-8b82-87b12EXAMPLE</RequestId>
+    </ResponseMetadata>
+</EnableAvailabilityZonesForLoadBalancerResponse>
+`
+
+var DisableAvailabilityZonesForLoadBalancer = `
+<DisableAvailabilityZonesForLoadBalancerResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/">
+    <DisableAvailabilityZonesForLoadBalancerResult>
+        <AvailabilityZones>
+            <member>us-east-1b</member>
+        </AvailabilityZones>
+    </DisableAvailabilityZonesForLoadBalancerResult>
+    <ResponseMetadata>
+        <RequestId>ba6267d5-2566-11e3-9c6d-eb728EXAMPLE</RequestId>
+    </ResponseMetadata>
+</DisableAvailabilityZonesForLoadBalancerResponse>
+`
+
+var AttachLoadBalancerToSubnets = `
+<AttachLoadBalancerToSubnetsResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/">
+    <AttachLoadBalancerToSubnetsResult>
+        <Subnets>
+            <member>subnet-119f0078</member>
+            <member>subnet-3561b05e</member>
+        </Subnets>
+    </AttachLoadBalancerToSubnetsResult>
+    <ResponseMetadata>
+        <RequestId>07b1ecbc-1100-11e3-acaf-dd7edEXAMPLE</RequestId>
+    </ResponseMetadata>
+</AttachLoadBalancerToSubnetsResponse>
+`
+
+var DetachLoadBalancerFromSubnets = `
+<DetachLoadBalancerFromSubnetsResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/">
+    <DetachLoadBalancerFromSubnetsResult>
+        <Subnets>
+            <member>subnet-159f007c</member>
+        </Subnets>
+    </DetachLoadBalancerFromSubnetsResult>
+    <ResponseMetadata>
+        <RequestId>07b1ecbc-1100-11e3-acaf-dd7edEXAMPLE</RequestId>
+    </ResponseMetadata>
+</DetachLoadBalancerFromSubnetsResponse>
+`
+
+var ApplySecurityGroupsToLoadBalancer = `
+<ApplySecurityGroupsToLoadBalancerResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/">
+    <ApplySecurityGroupsToLoadBalancerResult>
+        <SecurityGroups>
+            <member>sg-fc448899</member>
+        </SecurityGroups>
+    </ApplySecurityGroupsToLoadBalancerResult>
+    <ResponseMetadata>
+        <RequestId>06b5decc-102a-11e3-9ad6-bf3e4EXAMPLE</RequestId>
+    </ResponseMetadata>
+</ApplySecurityGroupsToLoadBalancerResponse>
+`
+
+var DescribeLoadBalancersWithBackendServerDescriptions = `
+<DescribeLoadBalancersResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/">
+    <DescribeLoadBalancersResult>
+        <LoadBalancerDescriptions>
+            <member>
+                <LoadBalancerName>testlb</LoadBalancerName>
+                <BackendServerDescriptions>
+                    <member>
+                        <InstancePort>80</InstancePort>
+                        <PolicyNames>
+                            <member>EnableProxyProtocol</member>
+                        </PolicyNames>
+                    </member>
+                    <member>
+                        <InstancePort>443</InstancePort>
+                        <PolicyNames>
+                            <member>EnableProxyProtocol</member>
+                            <member>BackendAuth</member>
+                        </PolicyNames>
+                    </member>
+                </BackendServerDescriptions>
+            </member>
+        </LoadBalancerDescriptions>
+    </DescribeLoadBalancersResult>
+    <ResponseMetadata>
+        <RequestId>83c88b9d-12b7-11e3-8b82-87b12EXAMPLE</RequestId>
+    </ResponseMetadata>
+</DescribeLoadBalancersResponse>
+`
+
+var DescribeLoadBalancersWithNextMarker = `
+<DescribeLoadBalancersResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/">
+    <DescribeLoadBalancersResult>
+        <LoadBalancerDescriptions>
+            <member>
+                <LoadBalancerName>testlb</LoadBalancerName>
+            </member>
+        </LoadBalancerDescriptions>
+        <NextMarker>cG9zaXRpb246MQ==</NextMarker>
+    </DescribeLoadBalancersResult>
+    <ResponseMetadata>
+        <RequestId>83c88b9d-12b7-11e3-8b82-87b12EXAMPLE</RequestId>
+    </ResponseMetadata>
+</DescribeLoadBalancersResponse>
+`
+
+var SetLoadBalancerPoliciesForBackendServer = `
+<SetLoadBalancerPoliciesForBackendServerResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/">
+    <SetLoadBalancerPoliciesForBackendServerResult/>
+    <ResponseMetadata>
+        <RequestId>0eb9b381-dde0-11e2-8d78-6ddbaEXAMPLE</RequestId>
+    </ResponseMetadata>
+</SetLoadBalancerPoliciesForBackendServerResponse>
+`
+
+var SetLoadBalancerPoliciesOfListener = `
+<SetLoadBalancerPoliciesOfListenerResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/">
+    <SetLoadBalancerPoliciesOfListenerResult/>
+    <ResponseMetadata>
+        <RequestId>83c88b9d-12b7-11e3-8b82-87b12EXAMPLE</RequestId>
+    </ResponseMetadata>
+</SetLoadBalancerPoliciesOfListenerResponse>
+`
+
+var SetLoadBalancerListenerSSLCertificate = `
+<SetLoadBalancerListenerSSLCertificateResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/">
+    <SetLoadBalancerListenerSSLCertificateResult/>
+    <ResponseMetadata>
+        <RequestId>83c88b9d-12b7-11e3-8b82-87b12EXAMPLE</RequestId>
+    </ResponseMetadata>
+</SetLoadBalancerListenerSSLCertificateResponse>
+`
+
+var CreateLBCookieStickinessPolicy = `
+<CreateLBCookieStickinessPolicyResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/">
+    <CreateLBCookieStickinessPolicyResult/>
+    <ResponseMetadata>
+        <RequestId>99a693e9-12b8-11e3-9ad6-bf3e4EXAMPLE</RequestId>
+    </ResponseMetadata>
+</CreateLBCookieStickinessPolicyResponse>
+`
+
+var CreateAppCookieStickinessPolicy = `
+<CreateAppCookieStickinessPolicyResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/">
+    <CreateAppCookieStickinessPolicyResult/>
+    <ResponseMetadata>
+        <RequestId>99a693e9-12b8-11e3-9ad6-bf3e4EXAMPLE</RequestId>
+    </ResponseMetadata>
+</CreateAppCookieStickinessPolicyResponse>
+`
+
+var CreateLoadBalancerPolicy = `
+<CreateLoadBalancerPolicyResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/">
+    <CreateLoadBalancerPolicyResult/>
+    <ResponseMetadata>
+        <RequestId>83c88b9d-12b7-11e3-8b82-87b12EXAMPLE</RequestId>
+    </ResponseMetadata>
+</CreateLoadBalancerPolicyResponse>
+`
+
+var DeleteLoadBalancerPolicy = `
+<DeleteLoadBalancerPolicyResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/">
+    <DeleteLoadBalancerPolicyResult/>
+    <ResponseMetadata>
+        <RequestId>83c88b9d-12b7-11e3-8b82-87b12EXAMPLE</RequestId>
+    </ResponseMetadata>
+</DeleteLoadBalancerPolicyResponse>
+`
+
+var DescribeLoadBalancerPolicies = `
+<DescribeLoadBalancerPoliciesResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/">
+    <DescribeLoadBalancerPoliciesResult>
+        <PolicyDescriptions>
+            <member>
+                <PolicyName>MyDurationStickyPolicy</PolicyName>
+                <PolicyTypeName>LBCookieStickinessPolicyType</PolicyTypeName>
+                <PolicyAttributeDescriptions>
+                    <member>
+                        <AttributeName>CookieExpirationPeriod</AttributeName>
+                        <AttributeValue>60</AttributeValue>
+                    </member>
+                </PolicyAttributeDescriptions>
+            </member>
+            <member>
+                <PolicyName>EnableProxyProtocol</PolicyName>
+                <PolicyTypeName>ProxyProtocolPolicyType</PolicyTypeName>
+                <PolicyAttributeDescriptions>
+                    <member>
+                        <AttributeName>ProxyProtocol</AttributeName>
+                        <AttributeValue>true</AttributeValue>
+                    </member>
+                </PolicyAttributeDescriptions>
+            </member>
+        </PolicyDescriptions>
+    </DescribeLoadBalancerPoliciesResult>
+    <ResponseMetadata>
+        <RequestId>83c88b9d-12b7-11e3-8b82-87b12EXAMPLE</RequestId>
+    </ResponseMetadata>
+</DescribeLoadBalancerPoliciesResponse>
+`
+
+var DescribeLoadBalancerPoliciesEmpty = `
+<DescribeLoadBalancerPoliciesResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/">
+    <DescribeLoadBalancerPoliciesResult>
+        <PolicyDescriptions/>
+    </DescribeLoadBalancerPoliciesResult>
+    <ResponseMetadata>
+        <RequestId>83c88b9d-12b7-11e3-8b82-87b12EXAMPLE</RequestId>
+    </ResponseMetadata>
+</DescribeLoadBalancerPoliciesResponse>
+`
+
+var DescribeLoadBalancerPolicyTypes = `
+<DescribeLoadBalancerPolicyTypesResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/">
+    <DescribeLoadBalancerPolicyTypesResult>
+        <PolicyTypeDescriptions>
+            <member>
+                <PolicyAttributeTypeDescriptions>
+                    <member>
+                        <AttributeName>ProxyProtocol</AttributeName>
+                        <AttributeType>Boolean</AttributeType>
+                        <Cardinality>ONE</Cardinality>
+                    </member>
+                </PolicyAttributeTypeDescriptions>
+                <PolicyTypeName>ProxyProtocolPolicyType</PolicyTypeName>
+                <Description>Policy that controls whether to include the IP address and port of the originating request for TCP messages. This policy operates on TCP/SSL listeners only</Description>
+            </member>
+        </PolicyTypeDescriptions>
+    </DescribeLoadBalancerPolicyTypesResult>
+    <ResponseMetadata>
+        <RequestId>83c88b9d-12b7-11e3-8b82-87b12EXAMPLE</RequestId>
+    </ResponseMetadata>
+</DescribeLoadBalancerPolicyTypesResponse>
+`
+
+var AddTags = `
+<AddTagsResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/">
+    <AddTagsResult/>
+    <ResponseMetadata>
+        <RequestId>360e81f7-1100-11e4-b6ed-0f30EXAMPLE</RequestId>
+    </ResponseMetadata>
+</AddTagsResponse>
+`
+
+var RemoveTags = `
+<RemoveTagsResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/">
+    <RemoveTagsResult/>
+    <ResponseMetadata>
+        <RequestId>83c88b9d-12b7-11e3-8b82-87b12EXAMPLE</RequestId>
+    </ResponseMetadata>
+</RemoveTagsResponse>
+`
+
+var DescribeTags = `
+<DescribeTagsResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/">
+    <DescribeTagsResult>
+        <TagDescriptions>
+            <member>
+                <Tags>
+                    <member>
+                        <Value>lima</Value>
+                        <Key>project</Key>
+                    </member>
+                    <member>
+                        <Value>digital-media</Value>
+                        <Key>department</Key>
+                    </member>
+                </Tags>
+                <LoadBalancerName>testlb</LoadBalancerName>
+            </member>
+            <member>
+                <Tags/>
+                <LoadBalancerName>otherlb</LoadBalancerName>
+            </member>
+        </TagDescriptions>
+    </DescribeTagsResult>
+    <ResponseMetadata>
+        <RequestId>07b1ecbc-1100-11e3-acaf-dd7edEXAMPLE</RequestId>
+    </ResponseMetadata>
+</DescribeTagsResponse>
+`
+
+var DescribeAccountLimits = `
+<DescribeAccountLimitsResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/">
+    <DescribeAccountLimitsResult>
+        <Limits>
+            <member>
+                <Name>classic-load-balancers</Name>
+                <Max>20</Max>
+            </member>
+            <member>
+                <Name>classic-listeners</Name>
+                <Max>100</Max>
+            </member>
+            <member>
+                <Name>classic-registered-instances</Name>
+                <Max>1000</Max>
+            </member>
+        </Limits>
+    </DescribeAccountLimitsResult>
+    <ResponseMetadata>
+        <RequestId>83c88b9d-12b7-11e3-8b82-87b12EXAMPLE</RequestId>
+    </ResponseMetadata>
+</DescribeAccountLimitsResponse>
+`
+
+// DescribeLoadBalancersWithFutureFields is DescribeLoadBalancers with
+// elements that the package does not know about, as if AWS had added them to
+// the API.
+var DescribeLoadBalancersWithFutureFields = `
+<DescribeLoadBalancersResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/">
+    <DescribeLoadBalancersResult>
+        <LoadBalancerDescriptions>
+            <member>
+                <LoadBalancerName>testlb</LoadBalancerName>
+                <IpAddressType>dualstack</IpAddressType>
+                <HealthCheck>
+                    <Interval>30</Interval>
+                    <Target>TCP:80</Target>
+                    <Matcher>
+                        <HttpCode>200-299</HttpCode>
+                    </Matcher>
+                    <HealthyThreshold>10</HealthyThreshold>
+                    <Timeout>5</Timeout>
+                    <UnhealthyThreshold>2</UnhealthyThreshold>
+                </HealthCheck>
+                <ListenerDescriptions>
+                    <member>
+                        <PolicyNames/>
+                        <Listener>
+                            <Protocol>HTTP</Protocol>
+                            <LoadBalancerPort>80</LoadBalancerPort>
+                            <InstanceProtocol>HTTP</InstanceProtocol>
+                            <InstancePort>80</InstancePort>
+                            <AlpnPolicy>HTTP2Preferred</AlpnPolicy>
+                        </Listener>
+                    </member>
+                </ListenerDescriptions>
+                <Instances>
+                    <member>
+                        <InstanceId>i-b44db8ca</InstanceId>
+                        <Weight>10</Weight>
+                    </member>
+                </Instances>
+                <DNSName>testlb-2087227216.us-east-1.elb.amazonaws.com</DNSName>
+            </member>
+        </LoadBalancerDescriptions>
+        <NextMarker>abc</NextMarker>
+        <Tags><member><Key>env</Key><Value>prod</Value></member></Tags>
+    </DescribeLoadBalancersResult>
+    <ResponseMetadata>
+        <RequestId>e2e81963-5055-11e2-99c7-434205631d9b</RequestId>
+        <Region>us-east-1</Region>
+    </ResponseMetadata>
+</DescribeLoadBalancersResponse>
+`
+
+var ThrottlingWithFutureFields = `
+<ErrorResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/">
+    <Error>
+        <Type>Sender</Type>
+        <Code>Throttling</Code>
+        <Message>Rate exceeded</Message>
+        <Detail><RetryHint>5</RetryHint></Detail>
+    </Error>
+    <RequestId>4bd7a2f0-12b8-11e3-8c3d-a1b2cEXAMPLE</RequestId>
+</ErrorResponse>
+`